@@ -0,0 +1,15 @@
+package ordersv1
+
+import (
+	_ "embed"
+)
+
+// AvroSchema is the Avro counterpart of the Order message, used by binary Kafka producers.
+//
+//go:embed order.avsc
+var AvroSchema string
+
+// ProtoSchema is the source of orders.proto as registered in the schema registry.
+//
+//go:embed orders.proto
+var ProtoSchema string
@@ -2,43 +2,191 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 
+	"wbts/internal/auth"
+	"wbts/internal/cache"
+	"wbts/internal/keyring"
 	"wbts/internal/pkg"
+	"wbts/internal/registry"
+	"wbts/internal/schema"
 	"wbts/internal/service"
 	"wbts/internal/storage"
 	"wbts/internal/transport/kafka"
 	"wbts/internal/transport/rest"
+	"wbts/internal/transport/rpc"
 )
 
 func main() {
-	pgPool := storage.Setup(context.Background())
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	pgPool := storage.Setup(ctx)
 	defer pgPool.Close()
-	orderRepo := storage.NewOrderRepo(pgPool)
-	orderConverter := &pkg.OrderConverter{}
-	orderService := service.NewOrderService(orderRepo, orderConverter)
+	orderConverter := &pkg.OrderConverter{
+		Keyring:           keyring.Setup(),
+		EncryptCustomerID: pkg.GetEnvBool("ENCRYPT_CUSTOMER_ID", false),
+	}
+	orderCache := cache.Setup(ctx, orderConverter.Keyring != nil)
+	orderRepo := storage.NewOrderRepo(pgPool, orderCache, pkg.GetEnvBool("ORDER_CACHE_ENCODED", false))
+	orderRepo.ListenInvalidations(ctx)
+	orderService := service.NewOrderService(orderRepo, orderConverter, os.Getenv("KAFKA_OUTBOX_TOPIC") != "")
 	validator := validator.New()
+	schemaValidator, err := schema.NewOrderValidator()
+	if err != nil {
+		log.Fatalf("Error loading order schemas: %v", err)
+	}
 
+	workerPool := kafka.WorkerPoolConfig{
+		Workers:   pkg.GetEnvInt("KAFKA_WORKERS", 4),
+		QueueSize: pkg.GetEnvInt("KAFKA_WORKER_QUEUE_SIZE", 16),
+	}
+	if err := workerPool.Validate(); err != nil {
+		log.Fatalf("Invalid Kafka worker pool configuration: %v", err)
+	}
+
+	kafkaConfig := kafka.Setup()
 	c := kafka.NewConsumer(
 		[]string{os.Getenv("KAFKA_BROKER")},
-		os.Getenv("KAFKA_ORDERS_TOPIC"),
+		kafka.SetupTopics(),
 		os.Getenv("KAFKA_GROUP_ID"),
 		orderService,
 		validator,
+		schemaValidator,
+		kafka.NewDecoder(registry.Setup()),
+		workerPool,
+		kafkaConfig,
+	)
+	go c.Run(ctx)
+
+	go storage.NewLedgerRepo(pgPool).PurgeExpired(
+		ctx,
+		pkg.GetEnvDuration("PROCESSED_MESSAGES_RETENTION", 7*24*time.Hour),
+		pkg.GetEnvDuration("PROCESSED_MESSAGES_PURGE_INTERVAL", time.Hour),
 	)
-	go c.Run(context.Background())
+
+	if outboxTopic := os.Getenv("KAFKA_OUTBOX_TOPIC"); outboxTopic != "" {
+		relay := kafka.NewOutboxRelay(
+			[]string{os.Getenv("KAFKA_BROKER")},
+			outboxTopic,
+			storage.NewOutboxRepo(pgPool),
+			pkg.GetEnvDuration("OUTBOX_POLL_INTERVAL", 5*time.Second),
+			pkg.GetEnvInt("OUTBOX_BATCH_SIZE", 100),
+			pkg.GetEnvDuration("OUTBOX_RETENTION", 24*time.Hour),
+			kafkaConfig,
+		)
+		go relay.Run(ctx)
+	}
+
+	grpcAddr := pkg.GetEnv("GRPC_ADDR", ":9090")
+	lis, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		log.Fatalf("Error listening on %s: %v", grpcAddr, err)
+	}
+	authenticator := auth.Setup()
+	grpcServer := rpc.NewServer(rpc.NewOrderServer(orderService), rpc.AuthInterceptors(authenticator)...)
+	go func() {
+		log.Printf("Started gRPC server on %s", grpcAddr)
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatalf("Error starting the gRPC server: %v", err)
+		}
+	}()
 
 	orderHandler := rest.NewOrderHandler(orderService)
+	streamHandler := rest.NewStreamHandler(orderService)
+	adminHandler := rest.NewAdminHandler(orderService)
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/order/{order_uid}", orderHandler.GetOrderHandler)
+	mux.HandleFunc("GET /order/{order_uid}", rest.RequireScope(auth.ScopeOrdersRead, orderHandler.GetOrderHandler))
+	mux.HandleFunc("GET /orders", rest.RequireScope(auth.ScopeOrdersRead, orderHandler.SearchOrdersHandler))
+	mux.HandleFunc("GET /orders/stream", rest.RequireScope(auth.ScopeOrdersRead, streamHandler.StreamOrdersHandler))
+	mux.HandleFunc(
+		"GET /admin/customers/{customer_id}/export",
+		rest.RequireScope(auth.ScopeOrdersAdmin, adminHandler.ExportCustomerHandler),
+	)
+	mux.HandleFunc(
+		"POST /admin/customers/{customer_id}/anonymize",
+		rest.RequireScope(auth.ScopeOrdersAdmin, adminHandler.AnonymizeCustomerHandler),
+	)
+
+	healthHandler := rest.NewHealthHandler(
+		rest.HealthCheck{Name: "postgres", Critical: true, Probe: pgPool.Ping},
+		rest.HealthCheck{Name: "kafka", Probe: c.Healthy},
+	)
+	mux.HandleFunc("GET /healthz", healthHandler.LivenessHandler)
+	mux.HandleFunc("GET /readyz", healthHandler.ReadinessHandler)
+	go grpcServer.WatchHealth(ctx, healthHandler.Ready, pkg.GetEnvDuration("GRPC_HEALTH_INTERVAL", 5*time.Second))
+
+	mux.HandleFunc("GET /debug/vars", rest.RequireScope(auth.ScopeOrdersAdmin, expvar.Handler().ServeHTTP))
+	mux.HandleFunc("GET /openapi.json", rest.OpenAPIHandler())
+	mux.HandleFunc("GET /docs", rest.SwaggerUIHandler)
+
+	if err := rest.CheckRoutes(mux); err != nil {
+		log.Fatalf("OpenAPI document is out of sync with the routes: %v", err)
+	}
+
+	ipLimits, err := rest.ParseRateLimits(pkg.GetEnv("RATE_LIMITS_IP", "*=10:20"))
+	if err != nil {
+		log.Fatalf("Error parsing RATE_LIMITS_IP: %v", err)
+	}
+	keyLimits, err := rest.ParseRateLimits(pkg.GetEnv("RATE_LIMITS_KEY", "*=50:100"))
+	if err != nil {
+		log.Fatalf("Error parsing RATE_LIMITS_KEY: %v", err)
+	}
+	trustedProxies, err := rest.ParseTrustedProxies(os.Getenv("RATE_LIMIT_TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("Error parsing RATE_LIMIT_TRUSTED_PROXIES: %v", err)
+	}
+	rateLimiter := rest.NewRateLimiter(
+		mux,
+		ipLimits,
+		keyLimits,
+		pkg.GetEnvInt("RATE_LIMIT_NOT_FOUND_PENALTY", 5),
+		trustedProxies,
+	)
+
+	server, err := rest.NewServer(
+		rest.ServerConfig{
+			Addr:              pkg.GetEnv("HTTP_ADDR", ":8081"),
+			ReadHeaderTimeout: pkg.GetEnvDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+			ReadTimeout:       pkg.GetEnvDuration("HTTP_READ_TIMEOUT", 15*time.Second),
+			WriteTimeout:      pkg.GetEnvDuration("HTTP_WRITE_TIMEOUT", 30*time.Second),
+			IdleTimeout:       pkg.GetEnvDuration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+			MaxHeaderBytes:    pkg.GetEnvInt("HTTP_MAX_HEADER_BYTES", 16<<10),
+			MaxBodyBytes:      int64(pkg.GetEnvInt("HTTP_MAX_BODY_BYTES", 1<<20)),
+			TLSCertFile:       os.Getenv("TLS_CERT_FILE"),
+			TLSKeyFile:        os.Getenv("TLS_KEY_FILE"),
+		},
+		rateLimiter.Limit(rest.Authenticate(authenticator, rateLimiter.LimitPrincipal(rest.Negotiate(mux)))),
+	)
+	if err != nil {
+		log.Fatalf("Error configuring the server: %v", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), pkg.GetEnvDuration("HTTP_SHUTDOWN_TIMEOUT", 10*time.Second))
+		defer cancel()
+		// Open streams would keep Shutdown waiting until the timeout.
+		orderService.CloseSubscriptions()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error shutting down the server: %v", err)
+		}
+		grpcServer.GracefulStop()
+	}()
 
-	log.Println("Started server on 8081 port")
-	if err := http.ListenAndServe(":8081", mux); err != nil {
+	log.Printf("Started server on %s (TLS: %t)", pkg.GetEnv("HTTP_ADDR", ":8081"), server.TLSEnabled())
+	if err := server.ListenAndServe(); err != nil {
 		log.Fatalf("Error starting the server: %v", err)
 	}
 }
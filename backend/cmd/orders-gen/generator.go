@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"wbts/internal/domain/dto"
+)
+
+const (
+	kindValid     = "valid"
+	kindInvalid   = "invalid"
+	kindDuplicate = "duplicate"
+
+	duplicateWindow = 100
+	alphanumeric    = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+	// maxItemsPerOrder bounds the items of one order, so chrt_id can be derived from the
+	// order sequence: items.chrt_id is a global key and a repeated id overwrites another
+	// order's item.
+	maxItemsPerOrder = 5
+)
+
+var (
+	cities     = []string{"Moscow", "Saint Petersburg", "Kazan", "Novosibirsk", "Yekaterinburg", "Kiryat Mozkin"}
+	regions    = []string{"Moscow", "Leningrad Oblast", "Tatarstan", "Novosibirsk Oblast", "Sverdlovsk Oblast", "Kraiot"}
+	firstNames = []string{"Ivan", "Anna", "Petr", "Maria", "Test", "Olga", "Sergey"}
+	lastNames  = []string{"Ivanov", "Petrova", "Sidorov", "Smirnova", "Testov", "Kuznetsova"}
+	streets    = []string{"Lenina", "Ploshad Mira", "Tverskaya", "Sadovaya", "Nevsky"}
+	brands     = []string{"Vivienne Sabo", "Nike", "Adidas", "Samsung", "Xiaomi", "Lego"}
+	products   = []string{"Mascaras", "Sneakers", "T-shirt", "Phone case", "Headphones", "Backpack"}
+	services   = []string{"meest", "cdek", "boxberry", "wb-courier"}
+	providers  = []string{"wbpay", "sbp", "card"}
+	banks      = []string{"alpha", "sber", "tinkoff", "vtb"}
+	currencies = []string{"RUB", "USD", "KZT"}
+	locales    = []string{"en", "ru"}
+	statuses   = []int{202, 203, 204, 205}
+)
+
+type message struct {
+	key   string
+	value []byte
+	kind  string
+}
+
+// invalidations each break exactly one rule enforced by the consumer.
+var invalidations = []func(order *dto.OrderDTO){
+	func(order *dto.OrderDTO) { order.OrderUID = "" },
+	func(order *dto.OrderDTO) { order.SmID = 0 },
+	func(order *dto.OrderDTO) { order.Delivery.Email = "not-an-email" },
+	func(order *dto.OrderDTO) { order.Delivery.Phone = "1" },
+	func(order *dto.OrderDTO) { order.Items[0].Sale = 101 },
+	func(order *dto.OrderDTO) { order.Items[0].Status = 0 },
+	func(order *dto.OrderDTO) { order.Items[0].TotalPrice = 0 },
+	func(order *dto.OrderDTO) { order.Payment.Amount = -1 },
+	func(order *dto.OrderDTO) { order.Locale = "" },
+}
+
+// Generator produces a deterministic sequence of orders for a given seed and start time.
+// Item chrt_ids are unique within a run; the seed selects the id range, so runs with
+// different seeds do not collide either.
+type Generator struct {
+	rnd       *rand.Rand
+	chrtBase  int64
+	start     time.Time
+	invalid   float64
+	duplicate float64
+	seq       int
+	recent    []message
+}
+
+func NewGenerator(seed uint64, start time.Time, invalid float64, duplicate float64) *Generator {
+	return &Generator{
+		rnd:       rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)),
+		chrtBase:  int64(seed%(1<<20)) << 32,
+		start:     start,
+		invalid:   invalid,
+		duplicate: duplicate,
+	}
+}
+
+func (g *Generator) Next() (message, error) {
+	g.seq++
+	roll := g.rnd.Float64()
+
+	if roll < g.duplicate && len(g.recent) > 0 {
+		msg := g.recent[g.rnd.IntN(len(g.recent))]
+		msg.kind = kindDuplicate
+		return msg, nil
+	}
+
+	order := g.order()
+	kind := kindValid
+	if roll < g.duplicate+g.invalid {
+		kind = kindInvalid
+		if i := g.rnd.IntN(len(invalidations) + 1); i < len(invalidations) {
+			invalidations[i](&order)
+		} else {
+			value, err := json.Marshal(order)
+			if err != nil {
+				return message{}, err
+			}
+			return message{order.OrderUID, value[:len(value)/2], kind}, nil
+		}
+	}
+
+	value, err := json.Marshal(order)
+	if err != nil {
+		return message{}, err
+	}
+	msg := message{order.OrderUID, value, kind}
+	if kind == kindValid {
+		g.remember(msg)
+	}
+	return msg, nil
+}
+
+func (g *Generator) remember(msg message) {
+	if len(g.recent) < duplicateWindow {
+		g.recent = append(g.recent, msg)
+		return
+	}
+	g.recent[g.rnd.IntN(duplicateWindow)] = msg
+}
+
+func (g *Generator) order() dto.OrderDTO {
+	orderUID := g.randomString(19)
+	trackNumber := "WBILM" + strings.ToUpper(g.randomString(8)) + "TRACK"
+	createdAt := g.start.Add(time.Duration(g.seq) * time.Second).Add(time.Duration(g.rnd.IntN(1000)) * time.Millisecond)
+
+	items := make([]dto.ItemDTO, 1+g.rnd.IntN(maxItemsPerOrder))
+	var goodsTotal int64
+	for i := range items {
+		price := int64(100 + g.rnd.IntN(9900))
+		sale := int8(g.rnd.IntN(51))
+		totalPrice := price * int64(100-int(sale)) / 100
+		goodsTotal += totalPrice
+
+		items[i] = dto.ItemDTO{
+			ChrtID:      g.chrtBase + int64(g.seq*maxItemsPerOrder+i),
+			TrackNumber: trackNumber,
+			Price:       price,
+			Rid:         g.randomString(21),
+			Name:        pick(g, products),
+			Sale:        sale,
+			Size:        fmt.Sprint(g.rnd.IntN(60)),
+			TotalPrice:  totalPrice,
+			NmID:        int64(1_000_000 + g.rnd.IntN(9_000_000)),
+			Brand:       pick(g, brands),
+			Status:      pick(g, statuses),
+		}
+	}
+
+	deliveryCost := int64(g.rnd.IntN(20)) * 100
+	customFee := int64(g.rnd.IntN(5)) * 10
+	first, last := pick(g, firstNames), pick(g, lastNames)
+	city := g.rnd.IntN(len(cities))
+
+	return dto.OrderDTO{
+		OrderUID:    orderUID,
+		TrackNumber: trackNumber,
+		Entry:       "WBIL",
+		Delivery: dto.DeliveryDTO{
+			Name:    first + " " + last,
+			Phone:   fmt.Sprintf("+7%010d", g.rnd.Int64N(10_000_000_000)),
+			Zip:     fmt.Sprintf("%06d", g.rnd.IntN(1_000_000)),
+			City:    cities[city],
+			Address: fmt.Sprintf("%s %d", pick(g, streets), 1+g.rnd.IntN(120)),
+			Region:  regions[city],
+			Email:   fmt.Sprintf("%s.%s%d@example.com", first, last, g.rnd.IntN(1000)),
+		},
+		Payment: dto.PaymentDTO{
+			Transaction:  orderUID,
+			Currency:     pick(g, currencies),
+			Provider:     pick(g, providers),
+			Amount:       goodsTotal + deliveryCost + customFee,
+			PaymentDt:    createdAt.Unix(),
+			Bank:         pick(g, banks),
+			DeliveryCost: deliveryCost,
+			GoodsTotal:   goodsTotal,
+			CustomFee:    customFee,
+		},
+		Items:             items,
+		Locale:            pick(g, locales),
+		InternalSignature: g.randomString(12),
+		CustomerID:        "customer-" + fmt.Sprint(g.rnd.IntN(1000)),
+		DeliveryService:   pick(g, services),
+		Shardkey:          fmt.Sprint(g.rnd.IntN(10)),
+		SmID:              int64(1 + g.rnd.IntN(10_000)),
+		DateCreated:       createdAt.UTC(),
+		OofShard:          fmt.Sprint(1 + g.rnd.IntN(10)),
+	}
+}
+
+func (g *Generator) randomString(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphanumeric[g.rnd.IntN(len(alphanumeric))]
+	}
+	return string(b)
+}
+
+func pick[T any](g *Generator, values []T) T {
+	return values[g.rnd.IntN(len(values))]
+}
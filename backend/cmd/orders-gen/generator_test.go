@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+
+	"wbts/internal/domain/dto"
+	"wbts/internal/schema"
+)
+
+var testStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// consumerValidate applies the checks the consumer runs on a message: the schema with
+// upcasting, then struct validation.
+func consumerValidate(t *testing.T, value []byte) error {
+	t.Helper()
+	schemaValidator, err := schema.NewOrderValidator()
+	if err != nil {
+		t.Fatalf("NewOrderValidator: %v", err)
+	}
+	payload, _, err := schemaValidator.Validate(value, "")
+	if err != nil {
+		return err
+	}
+	var order dto.OrderDTO
+	if err := json.Unmarshal(payload, &order); err != nil {
+		return err
+	}
+	return validator.New().Struct(order)
+}
+
+func TestGeneratorIsDeterministic(t *testing.T) {
+	generate := func(seed uint64) []message {
+		g := NewGenerator(seed, testStart, 0.2, 0.1)
+		msgs := make([]message, 200)
+		for i := range msgs {
+			msg, err := g.Next()
+			if err != nil {
+				t.Fatalf("Next: %v", err)
+			}
+			msgs[i] = msg
+		}
+		return msgs
+	}
+
+	first := generate(42)
+	if !reflect.DeepEqual(first, generate(42)) {
+		t.Error("runs with the same seed and start differ")
+	}
+	if reflect.DeepEqual(first, generate(43)) {
+		t.Error("runs with different seeds are the same")
+	}
+
+	kinds := make(map[string]int)
+	for _, msg := range first {
+		kinds[msg.kind]++
+	}
+	for _, kind := range []string{kindValid, kindInvalid, kindDuplicate} {
+		if kinds[kind] == 0 {
+			t.Errorf("no %s messages in %v", kind, kinds)
+		}
+	}
+}
+
+func TestGeneratorOrders(t *testing.T) {
+	g := NewGenerator(7, testStart, 0, 0)
+	chrtIDs := make(map[int64]bool)
+	for range 100 {
+		msg, err := g.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if err := consumerValidate(t, msg.value); err != nil {
+			t.Fatalf("valid order is rejected: %v", err)
+		}
+
+		var order dto.OrderDTO
+		if err := json.Unmarshal(msg.value, &order); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if msg.key != order.OrderUID {
+			t.Errorf("key %s, want the order_uid %s", msg.key, order.OrderUID)
+		}
+		payment := order.Payment
+		if payment.Amount != payment.GoodsTotal+payment.DeliveryCost+payment.CustomFee {
+			t.Errorf("amount %d != goods_total %d + delivery_cost %d + custom_fee %d",
+				payment.Amount, payment.GoodsTotal, payment.DeliveryCost, payment.CustomFee)
+		}
+		var goodsTotal int64
+		for _, item := range order.Items {
+			goodsTotal += item.TotalPrice
+			if chrtIDs[item.ChrtID] {
+				t.Errorf("chrt_id %d is repeated", item.ChrtID)
+			}
+			chrtIDs[item.ChrtID] = true
+		}
+		if goodsTotal != payment.GoodsTotal {
+			t.Errorf("goods_total %d, want the sum of items %d", payment.GoodsTotal, goodsTotal)
+		}
+	}
+}
+
+func TestInvalidationsAreRejected(t *testing.T) {
+	g := NewGenerator(7, testStart, 0, 0)
+	for i, invalidate := range invalidations {
+		order := g.order()
+		invalidate(&order)
+		value, err := json.Marshal(order)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		if consumerValidate(t, value) == nil {
+			t.Errorf("invalidation %d is accepted by the consumer", i)
+		}
+	}
+
+	// Invalid messages include truncated JSON as well.
+	g = NewGenerator(7, testStart, 1, 0)
+	for range 100 {
+		msg, err := g.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if msg.kind != kindInvalid {
+			t.Fatalf("kind %s, want invalid", msg.kind)
+		}
+		if consumerValidate(t, msg.value) == nil {
+			t.Errorf("invalid message is accepted: %s", msg.value)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"wbts/internal/pkg"
+)
+
+func main() {
+	brokers := flag.String("brokers", pkg.GetEnv("KAFKA_BROKER", "localhost:20092"), "comma-separated list of Kafka brokers")
+	topic := flag.String("topic", pkg.GetEnv("KAFKA_ORDERS_TOPIC", "orders"), "Kafka topic")
+	output := flag.String("output", "kafka", "where to send orders: kafka, stdout or file")
+	file := flag.String("file", "orders.jsonl", "output file for -output file")
+	ratePerSecond := flag.Float64("rate", 1, "messages per second, 0 for unlimited")
+	concurrency := flag.Int("concurrency", 1, "number of concurrent senders")
+	duration := flag.Duration("duration", 0, "stop after this duration, 0 to run until interrupted")
+	count := flag.Int("count", 0, "stop after this many messages, 0 for no limit")
+	seed := flag.Uint64("seed", 0, "random seed, 0 picks one at random")
+	start := flag.String("start", "", "RFC 3339 timestamp of the first order, defaults to now")
+	invalid := flag.Float64("invalid", 0, "fraction of messages that fail validation")
+	duplicate := flag.Float64("duplicate", 0, "fraction of messages that repeat an earlier order")
+	flag.Parse()
+
+	if *invalid < 0 || *duplicate < 0 || *invalid+*duplicate > 1 {
+		log.Fatalf("-invalid and -duplicate must be non-negative and sum to at most 1")
+	}
+	if *concurrency < 1 {
+		log.Fatalf("-concurrency must be at least 1")
+	}
+	if *seed == 0 {
+		*seed = rand.Uint64()
+	}
+	startAt := time.Now()
+	if *start != "" {
+		parsed, err := time.Parse(time.RFC3339, *start)
+		if err != nil {
+			log.Fatalf("Invalid -start: %v", err)
+		}
+		startAt = parsed
+	}
+	log.Printf("Generating orders with -seed %d -start %s", *seed, startAt.Format(time.RFC3339))
+
+	var out sink
+	var err error
+	switch *output {
+	case "kafka":
+		out, err = newKafkaSink(strings.Split(*brokers, ","), *topic)
+	case "stdout":
+		out = newStdoutSink()
+	case "file":
+		out, err = newFileSink(*file)
+	default:
+		log.Fatalf("Unknown -output %q", *output)
+	}
+	if err != nil {
+		log.Fatalf("Error opening %s output: %v", *output, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	limit := rate.Inf
+	if *ratePerSecond > 0 {
+		limit = rate.Limit(*ratePerSecond)
+	}
+	limiter := rate.NewLimiter(limit, 1)
+	generator := NewGenerator(*seed, startAt, *invalid, *duplicate)
+
+	messages := make(chan message)
+	go func() {
+		defer close(messages)
+		for i := 0; *count == 0 || i < *count; i++ {
+			if err := limiter.Wait(ctx); err != nil {
+				return
+			}
+			msg, err := generator.Next()
+			if err != nil {
+				log.Printf("Error generating order: %v", err)
+				return
+			}
+			select {
+			case messages <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var sent sync.Map
+	var failed atomic.Int64
+	var wg sync.WaitGroup
+	for range *concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range messages {
+				if err := out.Send(ctx, msg); err != nil {
+					failed.Add(1)
+					log.Printf("Error sending order with uid=%s: %v", msg.key, err)
+					continue
+				}
+				counter, _ := sent.LoadOrStore(msg.kind, new(atomic.Int64))
+				counter.(*atomic.Int64).Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := out.Close(); err != nil {
+		log.Printf("Error closing %s output: %v", *output, err)
+	}
+
+	total := func(kind string) int64 {
+		if counter, ok := sent.Load(kind); ok {
+			return counter.(*atomic.Int64).Load()
+		}
+		return 0
+	}
+	log.Printf(
+		"Sent %d valid, %d invalid and %d duplicate orders, %d failed",
+		total(kindValid),
+		total(kindInvalid),
+		total(kindDuplicate),
+		failed.Load(),
+	)
+	if failed.Load() > 0 {
+		os.Exit(1)
+	}
+}
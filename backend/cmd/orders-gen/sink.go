@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/IBM/sarama"
+
+	"wbts/internal/transport/kafka"
+)
+
+type sink interface {
+	Send(ctx context.Context, msg message) error
+	Close() error
+}
+
+type kafkaSink struct {
+	producer sarama.SyncProducer
+	topic    string
+}
+
+func newKafkaSink(brokers []string, topic string) (*kafkaSink, error) {
+	config, err := kafka.Setup().Build()
+	if err != nil {
+		return nil, err
+	}
+	config.ClientID = "orders-gen"
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Return.Successes = true
+
+	producer, err := sarama.NewSyncProducer(brokers, config)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaSink{producer, topic}, nil
+}
+
+func (s *kafkaSink) Send(ctx context.Context, msg message) error {
+	_, _, err := s.producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   s.topic,
+		Key:     sarama.StringEncoder(msg.key),
+		Value:   sarama.ByteEncoder(msg.value),
+		Headers: []sarama.RecordHeader{{Key: []byte("content-type"), Value: []byte("application/json")}},
+	})
+	return err
+}
+
+func (s *kafkaSink) Close() error {
+	return s.producer.Close()
+}
+
+// lineSink writes one message per line, which is the JSONL format accepted by schema-check.
+type lineSink struct {
+	w      *bufio.Writer
+	closer io.Closer
+	mtx    sync.Mutex
+}
+
+func newStdoutSink() *lineSink {
+	return &lineSink{w: bufio.NewWriter(os.Stdout)}
+}
+
+func newFileSink(path string) (*lineSink, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &lineSink{w: bufio.NewWriter(file), closer: file}, nil
+}
+
+func (s *lineSink) Send(ctx context.Context, msg message) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, err := s.w.Write(msg.value); err != nil {
+		return err
+	}
+	return s.w.WriteByte('\n')
+}
+
+func (s *lineSink) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	err := s.w.Flush()
+	if s.closer != nil {
+		if closeErr := s.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+
+	"wbts/internal/cache"
+	"wbts/internal/domain/entity"
+	"wbts/internal/keyring"
+	"wbts/internal/pkg"
+	"wbts/internal/storage"
+)
+
+func main() {
+	batchSize := flag.Int("batch", 500, "number of orders processed per batch")
+	dryRun := flag.Bool("dry-run", false, "only report orders that need re-encryption")
+	flag.Parse()
+
+	kr := keyring.Setup()
+	if kr == nil {
+		log.Fatalf("ENCRYPTION_KEYRING_FILE is required for re-encryption")
+	}
+
+	ctx := context.Background()
+	pgPool := storage.Setup(ctx)
+	defer pgPool.Close()
+	orderRepo := storage.NewOrderRepo(pgPool, cache.NewMemory(0), false)
+	orderConverter := &pkg.OrderConverter{
+		Keyring:           kr,
+		EncryptCustomerID: pkg.GetEnvBool("ENCRYPT_CUSTOMER_ID", false),
+	}
+
+	var scanned, updated, failed int
+	after := ""
+	for {
+		orders, err := orderRepo.ListOrders(ctx, after, *batchSize)
+		if err != nil {
+			log.Fatalf("Error listing orders: %v", err)
+		}
+		if len(orders) == 0 {
+			break
+		}
+
+		for _, order := range orders {
+			scanned++
+			changed, err := reencrypt(ctx, orderRepo, orderConverter, order, *dryRun)
+			if err != nil {
+				failed++
+				log.Printf("Error re-encrypting order with uid=%s: %v", order.OrderUID, err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+
+			updated++
+			if *dryRun {
+				log.Printf("Order with uid=%s needs re-encryption", order.OrderUID)
+			}
+		}
+		after = orders[len(orders)-1].OrderUID
+	}
+
+	log.Printf(
+		"Re-encryption finished with active key id=%s: scanned=%d, updated=%d, failed=%d, dry-run=%t",
+		kr.ActiveKeyID(), scanned, updated, failed, *dryRun,
+	)
+}
+
+// maxConflictRetries bounds how often an order changed by a concurrent write is re-read and re-encrypted.
+const maxConflictRetries = 3
+
+// reencrypt saves the re-encrypted order only if nobody wrote it since it was read. On a conflict
+// the fresh row is loaded and re-encrypted again, so a concurrent upsert is never overwritten.
+func reencrypt(
+	ctx context.Context,
+	orderRepo *storage.OrderRepo,
+	orderConverter *pkg.OrderConverter,
+	order entity.Order,
+	dryRun bool,
+) (bool, error) {
+	for attempt := 0; ; attempt++ {
+		reencrypted, changed, err := orderConverter.ReencryptOrder(order)
+		if err != nil || !changed || dryRun {
+			return changed, err
+		}
+
+		err = orderRepo.UpdateProtectedFields(ctx, reencrypted)
+		if !errors.Is(err, entity.ErrOrderChanged) || attempt == maxConflictRetries {
+			return err == nil, err
+		}
+
+		orderInfo, err := orderRepo.LoadByUID(ctx, order.OrderUID)
+		if err != nil {
+			return false, err
+		}
+		order = orderInfo.Order
+	}
+}
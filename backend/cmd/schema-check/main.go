@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"wbts/internal/schema"
+)
+
+func main() {
+	version := flag.String("version", "", "schema version to validate against, as if sent in the schema_version header")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-version N] file...\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Files hold a JSON object, a JSON array of objects, or one object per line (.jsonl).")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	validator, err := schema.NewOrderValidator()
+	if err != nil {
+		log.Fatalf("Error loading order schemas: %v", err)
+	}
+
+	var checked, failed int
+	for _, path := range flag.Args() {
+		documents, err := readDocuments(path)
+		if err != nil {
+			log.Fatalf("Error reading %s: %v", path, err)
+		}
+
+		for i, document := range documents {
+			checked++
+			_, detected, err := validator.Validate(document, *version)
+			if err == nil {
+				continue
+			}
+
+			failed++
+			var validationErr *schema.ValidationError
+			if !errors.As(err, &validationErr) {
+				fmt.Printf("%s#%d: %v\n", path, i, err)
+				continue
+			}
+			schemaVersion := fmt.Sprintf("v%d", detected)
+			if validationErr.Version != detected {
+				schemaVersion += fmt.Sprintf(" upcast to v%d", validationErr.Version)
+			}
+			for _, cause := range validationErr.Causes() {
+				fmt.Printf("%s#%d (%s): %s\n", path, i, schemaVersion, cause)
+			}
+		}
+	}
+
+	fmt.Printf("Checked %d documents, %d invalid\n", checked, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func readDocuments(path string) ([]json.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(path, ".jsonl") {
+		var documents []json.RawMessage
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, 16<<20)
+		for scanner.Scan() {
+			if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+				documents = append(documents, json.RawMessage(bytes.Clone(line)))
+			}
+		}
+		return documents, scanner.Err()
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var documents []json.RawMessage
+		if err := json.Unmarshal(trimmed, &documents); err != nil {
+			return nil, err
+		}
+		return documents, nil
+	}
+	return []json.RawMessage{data}, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{"get", "get <order_uid> - print an order read from the database, bypassing the cache", runGet},
+	{"republish", "republish [-topic T] <order_uid>... - send orders from the database to Kafka", runRepublish},
+	{"replay", "replay [-dry-run] <file.jsonl> - validate and save each order of the file", runReplay},
+	{"reprocess", "reprocess [-from POS] [-to POS] [-group G] [-dry-run] - replay Kafka messages under a separate group", runReprocess},
+	{"lag", "lag [-group G] [-topic T] - print committed offsets and lag per partition", runLag},
+	{"migrations", "migrations [-path DIR] - print migration version and check the schema", runMigrations},
+	{"check", "check [-samples N] - run integrity checks across orders, payments and items", runCheck},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	for _, cmd := range commands {
+		if cmd.name != os.Args[1] {
+			continue
+		}
+		if err := cmd.run(ctx, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %s\n", cmd.usage)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/IBM/sarama"
+
+	"wbts/internal/pkg"
+	"wbts/internal/storage"
+	"wbts/internal/transport/kafka"
+)
+
+var expectedTables = []string{"orders", "payments", "items", "orders_items", "order_outbox", "audit_log", "processed_messages"}
+
+func runLag(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("lag", flag.ExitOnError)
+	brokers := flags.String("brokers", pkg.GetEnv("KAFKA_BROKER", "localhost:20092"), "comma-separated list of Kafka brokers")
+	topic := flags.String("topic", pkg.GetEnv("KAFKA_ORDERS_TOPIC", "orders"), "Kafka topic")
+	group := flags.String("group", pkg.GetEnv("KAFKA_GROUP_ID", "WBTS"), "consumer group")
+	flags.Parse(args)
+
+	config, err := kafka.Setup().Build()
+	if err != nil {
+		return err
+	}
+	config.ClientID = "wbts-admin"
+	client, err := sarama.NewClient(strings.Split(*brokers, ","), config)
+	if err != nil {
+		return fmt.Errorf("connect to Kafka: %w", err)
+	}
+	defer client.Close()
+
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	partitions, err := client.Partitions(*topic)
+	if err != nil {
+		return fmt.Errorf("list partitions of %s: %w", *topic, err)
+	}
+	committed, err := admin.ListConsumerGroupOffsets(*group, map[string][]int32{*topic: partitions})
+	if err != nil {
+		return fmt.Errorf("list offsets of group %s: %w", *group, err)
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "PARTITION\tCOMMITTED\tNEWEST\tLAG")
+	var total int64
+	for _, partition := range partitions {
+		newest, err := client.GetOffset(*topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return fmt.Errorf("get newest offset of partition %d: %w", partition, err)
+		}
+
+		block := committed.GetBlock(*topic, partition)
+		if block == nil || block.Offset < 0 {
+			fmt.Fprintf(out, "%d\t-\t%d\t-\n", partition, newest)
+			continue
+		}
+		lag := newest - block.Offset
+		total += lag
+		fmt.Fprintf(out, "%d\t%d\t%d\t%d\n", partition, block.Offset, newest, lag)
+	}
+	fmt.Fprintf(out, "total\t\t\t%d\n", total)
+	return out.Flush()
+}
+
+func runMigrations(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("migrations", flag.ExitOnError)
+	path := flags.String("path", pkg.GetEnv("MIGRATIONS_PATH", "../migrations/postgres"), "directory with migration files")
+	flags.Parse(args)
+
+	available, err := migrationVersions(*path)
+	if err != nil {
+		return err
+	}
+
+	pgPool := storage.Setup(ctx)
+	defer pgPool.Close()
+	adminRepo := storage.NewAdminRepo(pgPool)
+
+	status, err := adminRepo.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+	var problems []string
+	switch {
+	case !status.Applied:
+		fmt.Println("current version: none")
+	case status.Dirty:
+		fmt.Printf("current version: %d (dirty)\n", status.Version)
+		problems = append(problems, fmt.Sprintf("migration %d failed halfway and needs manual repair", status.Version))
+	default:
+		fmt.Printf("current version: %d\n", status.Version)
+	}
+
+	if len(available) > 0 {
+		fmt.Printf("latest available: %d\n", available[len(available)-1])
+	}
+	for _, version := range available {
+		if version > status.Version {
+			problems = append(problems, fmt.Sprintf("migration %d is pending", version))
+		}
+	}
+	if status.Applied && !slices.Contains(available, status.Version) {
+		problems = append(problems, fmt.Sprintf("database version %d has no migration file in %s", status.Version, *path))
+	}
+
+	missing, err := adminRepo.MissingTables(ctx, expectedTables)
+	if err != nil {
+		return err
+	}
+	for _, table := range missing {
+		problems = append(problems, "table "+table+" is missing")
+	}
+
+	for _, problem := range problems {
+		fmt.Println("problem:", problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found", len(problems))
+	}
+	fmt.Println("schema is up to date")
+	return nil
+}
+
+func migrationVersions(dir string) ([]int64, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no migration files found in " + dir)
+	}
+
+	versions := make([]int64, 0, len(files))
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(filepath.Base(file), "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected migration file name %s", file)
+		}
+		versions = append(versions, version)
+	}
+	slices.Sort(versions)
+	return versions, nil
+}
+
+func runCheck(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	samples := flags.Int("samples", 10, "number of offending keys printed per check")
+	flags.Parse(args)
+
+	pgPool := storage.Setup(ctx)
+	defer pgPool.Close()
+
+	checks, err := storage.NewAdminRepo(pgPool).CheckIntegrity(ctx, *samples)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, check := range checks {
+		if check.Violations == 0 {
+			fmt.Printf("ok    %s\n", check.Name)
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL  %s: %d (%s)\n", check.Name, check.Violations, strings.Join(check.Samples, ", "))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
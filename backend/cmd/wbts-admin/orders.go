@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/IBM/sarama"
+	"github.com/go-playground/validator/v10"
+
+	"wbts/internal/cache"
+	"wbts/internal/domain/dto"
+	"wbts/internal/keyring"
+	"wbts/internal/pkg"
+	"wbts/internal/schema"
+	"wbts/internal/service"
+	"wbts/internal/storage"
+	"wbts/internal/transport/kafka"
+)
+
+func newOrderConverter() *pkg.OrderConverter {
+	return &pkg.OrderConverter{
+		Keyring:           keyring.Setup(),
+		EncryptCustomerID: pkg.GetEnvBool("ENCRYPT_CUSTOMER_ID", false),
+	}
+}
+
+func loadOrder(ctx context.Context, orderRepo *storage.OrderRepo, converter *pkg.OrderConverter, orderUID string) (dto.OrderDTO, error) {
+	info, err := orderRepo.LoadByUID(ctx, orderUID)
+	if err != nil {
+		return dto.OrderDTO{}, fmt.Errorf("load order %s: %w", orderUID, err)
+	}
+	order, err := converter.OrderInfoToOrderDTO(*info)
+	if err != nil {
+		return dto.OrderDTO{}, fmt.Errorf("decode order %s: %w", orderUID, err)
+	}
+	return order, nil
+}
+
+func runGet(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("get", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("expected exactly one order_uid")
+	}
+
+	pgPool := storage.Setup(ctx)
+	defer pgPool.Close()
+
+	order, err := loadOrder(ctx, storage.NewOrderRepo(pgPool, cache.NewMemory(0), false), newOrderConverter(), flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(order)
+}
+
+func runRepublish(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("republish", flag.ExitOnError)
+	brokers := flags.String("brokers", pkg.GetEnv("KAFKA_BROKER", "localhost:20092"), "comma-separated list of Kafka brokers")
+	topic := flags.String("topic", pkg.GetEnv("KAFKA_ORDERS_TOPIC", "orders"), "Kafka topic")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return errors.New("expected at least one order_uid")
+	}
+
+	pgPool := storage.Setup(ctx)
+	defer pgPool.Close()
+	orderRepo := storage.NewOrderRepo(pgPool, cache.NewMemory(0), false)
+	converter := newOrderConverter()
+
+	config, err := kafka.Setup().Build()
+	if err != nil {
+		return err
+	}
+	config.ClientID = "wbts-admin"
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Return.Successes = true
+	producer, err := sarama.NewSyncProducer(strings.Split(*brokers, ","), config)
+	if err != nil {
+		return fmt.Errorf("create producer: %w", err)
+	}
+	defer producer.Close()
+
+	for _, orderUID := range flags.Args() {
+		order, err := loadOrder(ctx, orderRepo, converter, orderUID)
+		if err != nil {
+			return err
+		}
+		payload, err := json.Marshal(order)
+		if err != nil {
+			return err
+		}
+
+		partition, offset, err := producer.SendMessage(&sarama.ProducerMessage{
+			Topic:   *topic,
+			Key:     sarama.StringEncoder(orderUID),
+			Value:   sarama.ByteEncoder(payload),
+			Headers: []sarama.RecordHeader{{Key: []byte("content-type"), Value: []byte("application/json")}},
+		})
+		if err != nil {
+			return fmt.Errorf("publish order %s: %w", orderUID, err)
+		}
+		fmt.Printf("%s -> %s/%d@%d\n", orderUID, *topic, partition, offset)
+	}
+	return nil
+}
+
+func runReplay(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only validate the orders")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("expected exactly one file")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	schemaValidator, err := schema.NewOrderValidator()
+	if err != nil {
+		return err
+	}
+	structValidator := validator.New()
+
+	var orderService *service.OrderService
+	if !*dryRun {
+		pgPool := storage.Setup(ctx)
+		defer pgPool.Close()
+		orderService = service.NewOrderService(storage.NewOrderRepo(pgPool, cache.NewMemory(0), false), newOrderConverter(), os.Getenv("KAFKA_OUTBOX_TOPIC") != "")
+	}
+
+	var lines, saved, failed int
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() && ctx.Err() == nil {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		lines++
+
+		if err := replayLine(orderService, schemaValidator, structValidator, []byte(line)); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "line %d: %v\n", lines, err)
+			continue
+		}
+		saved++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	action := "saved"
+	if *dryRun {
+		action = "valid"
+	}
+	fmt.Printf("%d orders, %d %s, %d failed\n", lines, saved, action, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d orders failed", failed)
+	}
+	return ctx.Err()
+}
+
+func replayLine(
+	orderService *service.OrderService,
+	schemaValidator *schema.OrderValidator,
+	structValidator *validator.Validate,
+	line []byte,
+) error {
+	payload, _, err := schemaValidator.Validate(line, "")
+	if err != nil {
+		return err
+	}
+
+	var order dto.OrderDTO
+	if err := json.Unmarshal(payload, &order); err != nil {
+		return err
+	}
+	if err := structValidator.Struct(order); err != nil {
+		return err
+	}
+
+	if orderService == nil {
+		return nil
+	}
+	return orderService.Save(order, "")
+}
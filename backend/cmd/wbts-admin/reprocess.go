@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-playground/validator/v10"
+
+	"wbts/internal/cache"
+	"wbts/internal/pkg"
+	"wbts/internal/registry"
+	"wbts/internal/schema"
+	"wbts/internal/service"
+	"wbts/internal/storage"
+	"wbts/internal/transport/kafka"
+)
+
+func runReprocess(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("reprocess", flag.ExitOnError)
+	brokers := flags.String("brokers", pkg.GetEnv("KAFKA_BROKER", "localhost:20092"), "comma-separated list of Kafka brokers")
+	topic := flags.String("topic", pkg.GetEnv("KAFKA_ORDERS_TOPIC", "orders"), "Kafka topic")
+	liveGroup := pkg.GetEnv("KAFKA_GROUP_ID", "WBTS")
+	group := flags.String("group", liveGroup+"-replay", "consumer group used for the replay, must differ from the live one")
+	from := flags.String("from", "", "start: RFC 3339 time, offset for all partitions, or partition:offset,... (default oldest)")
+	to := flags.String("to", "", "exclusive end in the same format as -from (default newest at start)")
+	dryRun := flags.Bool("dry-run", false, "only validate and report which orders would be created or updated")
+	flags.Parse(args)
+
+	fromBound, err := kafka.ParseReplayBound(*from)
+	if err != nil {
+		return fmt.Errorf("-from: %w", err)
+	}
+	toBound, err := kafka.ParseReplayBound(*to)
+	if err != nil {
+		return fmt.Errorf("-to: %w", err)
+	}
+	schemaValidator, err := schema.NewOrderValidator()
+	if err != nil {
+		return err
+	}
+
+	pgPool := storage.Setup(ctx)
+	defer pgPool.Close()
+	orderService := service.NewOrderService(storage.NewOrderRepo(pgPool, cache.NewMemory(0), false), newOrderConverter(), os.Getenv("KAFKA_OUTBOX_TOPIC") != "")
+
+	router, err := kafka.TopicRouterFromEnv()
+	if err != nil {
+		return err
+	}
+	consumer := kafka.NewConsumer(
+		strings.Split(*brokers, ","),
+		router,
+		liveGroup,
+		orderService,
+		validator.New(),
+		schemaValidator,
+		kafka.NewDecoder(registry.Setup()),
+		kafka.WorkerPoolConfig{Workers: 1, QueueSize: 1},
+		kafka.Setup(),
+	)
+	report, err := consumer.Replay(ctx, kafka.ReplayOptions{Topic: *topic, GroupID: *group, From: fromBound, To: toBound, DryRun: *dryRun})
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "PARTITION\tFROM\tTO")
+	for _, r := range report.Ranges {
+		fmt.Fprintf(out, "%d\t%d\t%d\n", r.Partition, r.From, r.To)
+	}
+	out.Flush()
+
+	outcomes := make([]string, 0, len(report.Outcomes))
+	for outcome := range report.Outcomes {
+		outcomes = append(outcomes, outcome)
+	}
+	sort.Strings(outcomes)
+	for _, outcome := range outcomes {
+		fmt.Printf("%s: %d\n", outcome, report.Outcomes[outcome])
+	}
+	return err
+}
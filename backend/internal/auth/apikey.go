@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+type apiKeyEntry struct {
+	Name      string   `json:"name"`
+	KeySHA256 string   `json:"key_sha256"`
+	Scopes    []string `json:"scopes"`
+}
+
+type APIKeyAuthenticator struct {
+	keys map[string]apiKeyEntry
+}
+
+func NewAPIKeyAuthenticatorFromFile(path string) (*APIKeyAuthenticator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []apiKeyEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, errors.New("Error parsing API keys file: " + err.Error())
+	}
+
+	keys := make(map[string]apiKeyEntry, len(entries))
+	for _, entry := range entries {
+		hash := strings.ToLower(entry.KeySHA256)
+		if entry.Name == "" || len(hash) != sha256.Size*2 {
+			return nil, errors.New("API key entries must have a name and a hex encoded key_sha256")
+		}
+		keys[hash] = entry
+	}
+	return &APIKeyAuthenticator{keys}, nil
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		if scheme, value, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "ApiKey") {
+			key = strings.TrimSpace(value)
+		}
+	}
+	if key == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	entry, ok := a.keys[hex.EncodeToString(sum[:])]
+	if !ok {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return Principal{Subject: entry.Name, Method: "api_key", Scopes: entry.Scopes}, nil
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+
+	"wbts/internal/domain/dto"
+	"wbts/internal/pkg"
+)
+
+const (
+	ScopeOrdersRead       = "orders:read"
+	ScopeOrdersReadMasked = "orders:read:masked"
+	ScopeOrdersReadPII    = "orders:read:pii"
+	ScopeOrdersAdmin      = "orders:admin"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type Principal struct {
+	Subject string
+	Method  string
+	Scopes  []string
+}
+
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// OrderView is the representation of recipient data the principal may see.
+func (p Principal) OrderView() string {
+	switch {
+	case p.HasScope(ScopeOrdersReadPII):
+		return dto.ViewFull
+	case p.HasScope(ScopeOrdersReadMasked):
+		return dto.ViewMasked
+	default:
+		return dto.ViewRedacted
+	}
+}
+
+type Authenticator interface {
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+var auditLog = log.New(os.Stderr, "AUDIT ", log.LstdFlags|log.LUTC)
+
+func AuditDenied(r *http.Request, subject string, reason string) {
+	auditLog.Printf(
+		"access denied: method=%s path=%s remote=%s subject=%q reason=%q",
+		r.Method, r.URL.Path, r.RemoteAddr, subject, reason,
+	)
+}
+
+type chain []Authenticator
+
+func (c chain) Authenticate(r *http.Request) (Principal, error) {
+	for _, a := range c {
+		principal, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return Principal{}, ErrNoCredentials
+}
+
+func Setup() Authenticator {
+	var authenticators chain
+
+	if path := os.Getenv("AUTH_API_KEYS_FILE"); path != "" {
+		a, err := NewAPIKeyAuthenticatorFromFile(path)
+		if err != nil {
+			log.Fatalf("Error loading API keys: %v", err)
+		}
+		authenticators = append(authenticators, a)
+	}
+
+	if path := os.Getenv("AUTH_JWKS_FILE"); path != "" {
+		a, err := NewJWTAuthenticatorFromFile(path, os.Getenv("AUTH_JWT_ISSUER"), os.Getenv("AUTH_JWT_AUDIENCE"))
+		if err != nil {
+			log.Fatalf("Error loading JWKS: %v", err)
+		}
+		authenticators = append(authenticators, a)
+	}
+
+	if len(authenticators) == 0 {
+		if !pkg.GetEnvBool("AUTH_DISABLED", false) {
+			log.Fatal("Authentication is not configured: set AUTH_API_KEYS_FILE or AUTH_JWKS_FILE, or AUTH_DISABLED=true for local development")
+		}
+		log.Println("Warning: authentication is disabled, anonymous callers get the masked view of orders")
+		return anonymousAuthenticator{}
+	}
+	return authenticators
+}
+
+// anonymousAuthenticator lets everyone read orders, but never unmasked personal data.
+type anonymousAuthenticator struct{}
+
+func (anonymousAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	return Principal{Subject: "anonymous", Method: "anonymous", Scopes: []string{ScopeOrdersRead, ScopeOrdersReadMasked}}, nil
+}
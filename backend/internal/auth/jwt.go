@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+type jwtKey struct {
+	alg string
+	key any
+}
+
+type JWTAuthenticator struct {
+	keys   map[string]jwtKey
+	parser *jwt.Parser
+}
+
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	Scope string   `json:"scope"`
+	Scp   []string `json:"scp"`
+}
+
+func NewJWTAuthenticatorFromFile(path string, issuer string, audience string) (*JWTAuthenticator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, errors.New("Error parsing JWKS file: " + err.Error())
+	}
+
+	keys := make(map[string]jwtKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		key, err := parseJWK(k)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing JWK %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "HS384", "HS512", "RS256", "RS384", "RS512"}),
+		jwt.WithExpirationRequired(),
+	}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+	return &JWTAuthenticator{keys, jwt.NewParser(opts...)}, nil
+}
+
+func parseJWK(k jwk) (jwtKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return jwtKey{}, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return jwtKey{}, err
+		}
+		if len(n) == 0 || len(e) == 0 {
+			return jwtKey{}, errors.New("RSA key must have n and e")
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		return jwtKey{k.Alg, pub}, nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return jwtKey{}, err
+		}
+		if len(secret) < 32 {
+			return jwtKey{}, errors.New("HMAC key must be at least 256 bits")
+		}
+		return jwtKey{k.Alg, secret}, nil
+	default:
+		return jwtKey{}, errors.New("unsupported key type " + k.Kty)
+	}
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	scheme, raw, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return Principal{}, ErrNoCredentials
+	}
+
+	var claims jwtClaims
+	_, err := a.parser.ParseWithClaims(strings.TrimSpace(raw), &claims, a.keyFunc)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	scopes := claims.Scp
+	if claims.Scope != "" {
+		scopes = append(scopes, strings.Fields(claims.Scope)...)
+	}
+	return Principal{Subject: claims.Subject, Method: "jwt", Scopes: scopes}, nil
+}
+
+func (a *JWTAuthenticator) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := a.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown key id " + kid)
+	}
+
+	alg := token.Method.Alg()
+	if key.alg != "" && key.alg != alg {
+		return nil, errors.New("algorithm does not match key")
+	}
+	switch key.key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return nil, errors.New("algorithm does not match key type")
+		}
+	case []byte:
+		if !strings.HasPrefix(alg, "HS") {
+			return nil, errors.New("algorithm does not match key type")
+		}
+	}
+	return key.key, nil
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"wbts/internal/domain/dto"
+	"wbts/internal/domain/entity"
+	"wbts/internal/pkg"
+)
+
+const (
+	ModeMemory = "memory"
+	ModeRedis  = "redis"
+	ModeTiered = "tiered"
+)
+
+// OrderCache stores orders by order_uid together with their encoded variants. It is
+// best-effort: implementations report failures of the backing store as misses and log them.
+type OrderCache interface {
+	Get(ctx context.Context, order_uid string) (entity.OrderInfo, bool)
+	Set(ctx context.Context, order_uid string, info entity.OrderInfo)
+	// GetEncoded and SetEncoded access the variants of a cached order. A variant is only
+	// stored while the order itself is cached, and is dropped together with it.
+	GetEncoded(ctx context.Context, order_uid string, variant string) (dto.EncodedOrderDTO, bool)
+	SetEncoded(ctx context.Context, order_uid string, variant string, encoded dto.EncodedOrderDTO)
+	Delete(ctx context.Context, order_uid string)
+	// Reset drops every entry that may have missed an invalidation. Shared stores, which
+	// writers invalidate directly, keep their entries.
+	Reset(ctx context.Context)
+}
+
+// defaultTieredLocalTTL bounds how long a tiered cache serves an L1 entry whose invalidation
+// was lost, when ORDER_CACHE_LOCAL_TTL does not set a bound of its own.
+const defaultTieredLocalTTL = 30 * time.Second
+
+// Setup builds the cache selected by ORDER_CACHE and stops the process if it is misconfigured
+// or the shared store is unreachable.
+func Setup(ctx context.Context, encryptedAtRest bool) OrderCache {
+	orderCache, err := FromEnv(ctx, encryptedAtRest)
+	if err != nil {
+		log.Fatalf("Error setting up the order cache: %v", err)
+	}
+	return orderCache
+}
+
+// FromEnv builds the cache selected by ORDER_CACHE. Orders are cached as they are stored, so
+// the modes backed by Redis require encryptedAtRest: without it the delivery data of every
+// cached order would be written to Redis in plaintext.
+func FromEnv(ctx context.Context, encryptedAtRest bool) (OrderCache, error) {
+	mode := pkg.GetEnv("ORDER_CACHE", ModeMemory)
+	localTTL := pkg.GetEnvDuration("ORDER_CACHE_LOCAL_TTL", 0)
+	if mode == ModeMemory {
+		return NewMemory(localTTL), nil
+	}
+	if mode != ModeRedis && mode != ModeTiered {
+		return nil, fmt.Errorf("ORDER_CACHE must be %s, %s or %s, got %q", ModeMemory, ModeRedis, ModeTiered, mode)
+	}
+	if !encryptedAtRest {
+		return nil, fmt.Errorf("ORDER_CACHE=%s requires encryption at rest (ENCRYPTION_KEYRING_FILE), otherwise Redis holds plaintext personal data", mode)
+	}
+
+	shared, err := redisFromEnv(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("connect to Redis: %w", err)
+	}
+	if mode == ModeRedis {
+		return shared, nil
+	}
+	if localTTL <= 0 {
+		localTTL = defaultTieredLocalTTL
+	}
+	return NewTiered(NewMemory(localTTL), shared), nil
+}
+
+func redisFromEnv(ctx context.Context) (*Redis, error) {
+	options, err := redis.ParseURL(pkg.GetEnv("REDIS_URL", "redis://localhost:6379/0"))
+	if err != nil {
+		return nil, fmt.Errorf("REDIS_URL: %w", err)
+	}
+	client := redis.NewClient(options)
+
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Ping(pingCtx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	prefix := os.Getenv("ORDER_CACHE_REDIS_PREFIX")
+	if prefix == "" {
+		prefix = "wbts:order:"
+	}
+	return NewRedis(client, prefix, pkg.GetEnvDuration("ORDER_CACHE_REDIS_TTL", time.Hour)), nil
+}
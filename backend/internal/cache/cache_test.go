@@ -0,0 +1,238 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"wbts/internal/domain/dto"
+	"wbts/internal/domain/entity"
+)
+
+const (
+	maskedVariant = dto.ViewMasked + ":" + dto.FormatJSON + "+" + dto.EncodingIdentity
+	fullVariant   = dto.ViewFull + ":" + dto.FormatJSON + "+" + dto.EncodingIdentity
+)
+
+func testInfo(uid string) entity.OrderInfo {
+	return entity.OrderInfo{Order: entity.Order{OrderUID: uid, Tenant: dto.DefaultTenant}}
+}
+
+func testEncoded(body string) dto.EncodedOrderDTO {
+	return dto.EncodedOrderDTO{Body: []byte(body), ETag: `"` + body + `"`, LastModified: time.Unix(1700000000, 0).UTC()}
+}
+
+func newTestRedis(t *testing.T, ttl time.Duration) (*Redis, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	return NewRedis(client, "test:order:", ttl), server
+}
+
+func assertCached(t *testing.T, c OrderCache, uid string, want bool) {
+	t.Helper()
+	info, ok := c.Get(context.Background(), uid)
+	if ok != want {
+		t.Fatalf("Get(%s) cached = %t, want %t", uid, ok, want)
+	}
+	if ok && info.Order.OrderUID != uid {
+		t.Fatalf("Get(%s) returned order %s", uid, info.Order.OrderUID)
+	}
+}
+
+func assertVariant(t *testing.T, c OrderCache, uid string, variant string, want string) {
+	t.Helper()
+	encoded, ok := c.GetEncoded(context.Background(), uid, variant)
+	switch {
+	case want == "" && ok:
+		t.Fatalf("GetEncoded(%s, %s) = %q, want a miss", uid, variant, encoded.Body)
+	case want != "" && (!ok || string(encoded.Body) != want):
+		t.Fatalf("GetEncoded(%s, %s) = %q, %t, want %q", uid, variant, encoded.Body, ok, want)
+	}
+}
+
+// testOrderCache checks the contract every OrderCache implements.
+func testOrderCache(t *testing.T, c OrderCache) {
+	ctx := context.Background()
+
+	assertCached(t, c, "a", false)
+	c.SetEncoded(ctx, "a", maskedVariant, testEncoded("orphan"))
+	assertVariant(t, c, "a", maskedVariant, "")
+
+	c.Set(ctx, "a", testInfo("a"))
+	assertCached(t, c, "a", true)
+	c.SetEncoded(ctx, "a", maskedVariant, testEncoded("masked"))
+	assertVariant(t, c, "a", maskedVariant, "masked")
+	encoded, _ := c.GetEncoded(ctx, "a", maskedVariant)
+	if encoded.ETag != `"masked"` || !encoded.LastModified.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("variant metadata = %q, %s", encoded.ETag, encoded.LastModified)
+	}
+
+	c.Set(ctx, "a", testInfo("a"))
+	assertVariant(t, c, "a", maskedVariant, "")
+
+	c.Set(ctx, "b", testInfo("b"))
+	c.SetEncoded(ctx, "b", maskedVariant, testEncoded("b"))
+	c.Delete(ctx, "b")
+	assertCached(t, c, "b", false)
+	assertVariant(t, c, "b", maskedVariant, "")
+	assertCached(t, c, "a", true)
+}
+
+func TestMemory(t *testing.T) {
+	testOrderCache(t, NewMemory(0))
+
+	m := NewMemory(0)
+	m.Set(context.Background(), "a", testInfo("a"))
+	m.SetEncoded(context.Background(), "a", fullVariant, testEncoded("full"))
+	assertVariant(t, m, "a", fullVariant, "full")
+	m.Reset(context.Background())
+	assertCached(t, m, "a", false)
+}
+
+func TestMemoryExpiry(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(20 * time.Millisecond)
+
+	m.Set(ctx, "a", testInfo("a"))
+	m.SetEncoded(ctx, "a", maskedVariant, testEncoded("masked"))
+	assertCached(t, m, "a", true)
+
+	time.Sleep(30 * time.Millisecond)
+	assertCached(t, m, "a", false)
+	assertVariant(t, m, "a", maskedVariant, "")
+
+	m.Set(ctx, "b", testInfo("b"))
+	m.mtx.RLock()
+	_, kept := m.entries["a"]
+	size := len(m.entries)
+	m.mtx.RUnlock()
+	if kept || size != 1 {
+		t.Errorf("expired entry was not swept: %d entries", size)
+	}
+}
+
+func TestRedis(t *testing.T) {
+	r, _ := newTestRedis(t, time.Hour)
+	testOrderCache(t, r)
+
+	// Redis keeps its entries on Reset: writers delete changed orders themselves.
+	r.Set(context.Background(), "a", testInfo("a"))
+	r.Reset(context.Background())
+	assertCached(t, r, "a", true)
+}
+
+func TestRedisKeepsFullVariantsOut(t *testing.T) {
+	ctx := context.Background()
+	r, server := newTestRedis(t, time.Hour)
+
+	r.Set(ctx, "a", testInfo("a"))
+	r.SetEncoded(ctx, "a", fullVariant, testEncoded("Ivan Ivanov"))
+	r.SetEncoded(ctx, "a", maskedVariant, testEncoded("I*** I*****"))
+
+	assertVariant(t, r, "a", fullVariant, "")
+	assertVariant(t, r, "a", maskedVariant, "I*** I*****")
+	if server.Exists("test:order:a") && server.HGet("test:order:a", variantField+fullVariant) != "" {
+		t.Error("full variant was written to Redis")
+	}
+}
+
+func TestRedisExpiry(t *testing.T) {
+	ctx := context.Background()
+	r, server := newTestRedis(t, time.Minute)
+
+	r.Set(ctx, "a", testInfo("a"))
+	r.SetEncoded(ctx, "a", maskedVariant, testEncoded("masked"))
+	server.FastForward(2 * time.Minute)
+	assertCached(t, r, "a", false)
+	assertVariant(t, r, "a", maskedVariant, "")
+}
+
+func TestRedisUnavailableIsMiss(t *testing.T) {
+	ctx := context.Background()
+	r, server := newTestRedis(t, time.Hour)
+	r.Set(ctx, "a", testInfo("a"))
+
+	server.Close()
+	assertCached(t, r, "a", false)
+	r.Set(ctx, "b", testInfo("b"))
+	r.Delete(ctx, "a")
+}
+
+func TestTiered(t *testing.T) {
+	shared, _ := newTestRedis(t, time.Hour)
+	testOrderCache(t, NewTiered(NewMemory(0), shared))
+}
+
+func TestTieredFillsLocalFromShared(t *testing.T) {
+	ctx := context.Background()
+	shared, _ := newTestRedis(t, time.Hour)
+	local := NewMemory(0)
+	tiered := NewTiered(local, shared)
+
+	// Another instance cached the order and its masked variant in Redis.
+	shared.Set(ctx, "a", testInfo("a"))
+	shared.SetEncoded(ctx, "a", maskedVariant, testEncoded("masked"))
+
+	assertCached(t, local, "a", false)
+	assertCached(t, tiered, "a", true)
+	assertCached(t, local, "a", true)
+	assertVariant(t, tiered, "a", maskedVariant, "masked")
+	assertVariant(t, local, "a", maskedVariant, "masked")
+
+	// Full variants are served from L1 only.
+	tiered.SetEncoded(ctx, "a", fullVariant, testEncoded("full"))
+	assertVariant(t, local, "a", fullVariant, "full")
+	assertVariant(t, shared, "a", fullVariant, "")
+
+	// Reset after a missed invalidation drops L1 and keeps L2.
+	tiered.Reset(ctx)
+	assertCached(t, local, "a", false)
+	assertCached(t, shared, "a", true)
+
+	tiered.Delete(ctx, "a")
+	assertCached(t, tiered, "a", false)
+	assertCached(t, shared, "a", false)
+}
+
+func TestFromEnv(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	t.Setenv("REDIS_URL", "redis://"+server.Addr()+"/0")
+
+	t.Setenv("ORDER_CACHE", "disk")
+	if _, err := FromEnv(ctx, true); err == nil {
+		t.Error("unknown cache mode was accepted")
+	}
+
+	for _, mode := range []string{ModeRedis, ModeTiered} {
+		t.Setenv("ORDER_CACHE", mode)
+		if _, err := FromEnv(ctx, false); err == nil {
+			t.Errorf("%s cache was set up without encryption at rest", mode)
+		}
+	}
+
+	t.Setenv("ORDER_CACHE", ModeMemory)
+	if c, err := FromEnv(ctx, false); err != nil {
+		t.Errorf("memory cache without encryption at rest: %v", err)
+	} else if _, ok := c.(*Memory); !ok {
+		t.Errorf("memory mode built %T", c)
+	}
+
+	t.Setenv("ORDER_CACHE", ModeTiered)
+	c, err := FromEnv(ctx, true)
+	if err != nil {
+		t.Fatalf("tiered cache: %v", err)
+	}
+	tiered, ok := c.(*Tiered)
+	if !ok {
+		t.Fatalf("tiered mode built %T", c)
+	}
+	if local := tiered.local.(*Memory); local.ttl != defaultTieredLocalTTL {
+		t.Errorf("L1 TTL = %s, want %s when ORDER_CACHE_LOCAL_TTL is unset", local.ttl, defaultTieredLocalTTL)
+	}
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"wbts/internal/domain/dto"
+	"wbts/internal/domain/entity"
+)
+
+// memorySweepInterval bounds how often Set scans for expired entries.
+const memorySweepInterval = time.Minute
+
+type memoryEntry struct {
+	info      entity.OrderInfo
+	encoded   map[string]dto.EncodedOrderDTO
+	expiresAt time.Time
+}
+
+// Memory is the in-process cache. A zero TTL keeps entries until they are deleted; otherwise
+// expired entries are missed on lookup and removed by a sweep that runs on Set.
+type Memory struct {
+	entries   map[string]*memoryEntry
+	ttl       time.Duration
+	lastSweep time.Time
+	mtx       sync.RWMutex
+}
+
+func NewMemory(ttl time.Duration) *Memory {
+	return &Memory{entries: make(map[string]*memoryEntry), ttl: ttl, lastSweep: time.Now()}
+}
+
+func (m *Memory) lookup(order_uid string) (*memoryEntry, bool) {
+	entry, ok := m.entries[order_uid]
+	if !ok || entry.expired(time.Now()) {
+		return nil, false
+	}
+	return entry, true
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+// sweep removes expired entries at most once per min(ttl, memorySweepInterval).
+// The caller holds the write lock.
+func (m *Memory) sweep(now time.Time) {
+	if m.ttl <= 0 || now.Sub(m.lastSweep) < min(m.ttl, memorySweepInterval) {
+		return
+	}
+	for order_uid, entry := range m.entries {
+		if entry.expired(now) {
+			delete(m.entries, order_uid)
+		}
+	}
+	m.lastSweep = now
+}
+
+func (m *Memory) Get(ctx context.Context, order_uid string) (entity.OrderInfo, bool) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	entry, ok := m.lookup(order_uid)
+	if !ok {
+		return entity.OrderInfo{}, false
+	}
+	return entry.info, true
+}
+
+func (m *Memory) Set(ctx context.Context, order_uid string, info entity.OrderInfo) {
+	now := time.Now()
+	entry := &memoryEntry{info: info, encoded: make(map[string]dto.EncodedOrderDTO)}
+	if m.ttl > 0 {
+		entry.expiresAt = now.Add(m.ttl)
+	}
+
+	m.mtx.Lock()
+	m.sweep(now)
+	m.entries[order_uid] = entry
+	m.mtx.Unlock()
+}
+
+func (m *Memory) GetEncoded(ctx context.Context, order_uid string, variant string) (dto.EncodedOrderDTO, bool) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	entry, ok := m.lookup(order_uid)
+	if !ok {
+		return dto.EncodedOrderDTO{}, false
+	}
+	encoded, ok := entry.encoded[variant]
+	return encoded, ok
+}
+
+func (m *Memory) SetEncoded(ctx context.Context, order_uid string, variant string, encoded dto.EncodedOrderDTO) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if entry, ok := m.lookup(order_uid); ok {
+		entry.encoded[variant] = encoded
+	}
+}
+
+func (m *Memory) Delete(ctx context.Context, order_uid string) {
+	m.mtx.Lock()
+	delete(m.entries, order_uid)
+	m.mtx.Unlock()
+}
+
+func (m *Memory) Reset(ctx context.Context) {
+	m.mtx.Lock()
+	clear(m.entries)
+	m.mtx.Unlock()
+}
@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"wbts/internal/domain/dto"
+	"wbts/internal/domain/entity"
+)
+
+const (
+	infoField    = "info"
+	variantField = "variant:"
+)
+
+// setVariant adds a variant only while the order's info is cached, so a variant encoded from
+// an order that was deleted or replaced in the meantime is not attached to a newer version.
+var setVariant = redis.NewScript(`
+if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1 then
+	return redis.call("HSET", KEYS[1], ARGV[2], ARGV[3])
+end
+return 0
+`)
+
+// Redis is a cache shared by all instances. Every order is a hash holding the order and its
+// encoded variants, so they expire and are deleted together. Orders are written as stored,
+// with their delivery encrypted, which is why Setup only uses Redis with encryption at rest.
+// Variants of the full view are decrypted personal data and are never written to the shared
+// store; a tiered cache keeps them in its local tier only.
+type Redis struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+func NewRedis(client *redis.Client, prefix string, ttl time.Duration) *Redis {
+	return &Redis{client: client, prefix: prefix, ttl: ttl}
+}
+
+func (r *Redis) key(order_uid string) string {
+	return r.prefix + order_uid
+}
+
+func (r *Redis) Get(ctx context.Context, order_uid string) (entity.OrderInfo, bool) {
+	var info entity.OrderInfo
+	if !r.getField(ctx, order_uid, infoField, &info) {
+		return entity.OrderInfo{}, false
+	}
+	return info, true
+}
+
+func (r *Redis) Set(ctx context.Context, order_uid string, info entity.OrderInfo) {
+	value, err := json.Marshal(info)
+	if err != nil {
+		log.Printf("Error encoding order with uid=%s for Redis cache: %v", order_uid, err)
+		return
+	}
+
+	key := r.key(order_uid)
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, infoField, value)
+		if r.ttl > 0 {
+			pipe.Expire(ctx, key, r.ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error caching order with uid=%s in Redis: %v", order_uid, err)
+	}
+}
+
+func (r *Redis) GetEncoded(ctx context.Context, order_uid string, variant string) (dto.EncodedOrderDTO, bool) {
+	if !sharedVariant(variant) {
+		return dto.EncodedOrderDTO{}, false
+	}
+	var encoded dto.EncodedOrderDTO
+	if !r.getField(ctx, order_uid, variantField+variant, &encoded) {
+		return dto.EncodedOrderDTO{}, false
+	}
+	return encoded, true
+}
+
+func (r *Redis) SetEncoded(ctx context.Context, order_uid string, variant string, encoded dto.EncodedOrderDTO) {
+	if !sharedVariant(variant) {
+		return
+	}
+	value, err := json.Marshal(encoded)
+	if err != nil {
+		log.Printf("Error encoding %s variant of order with uid=%s for Redis cache: %v", variant, order_uid, err)
+		return
+	}
+
+	err = setVariant.Run(ctx, r.client, []string{r.key(order_uid)}, infoField, variantField+variant, value).Err()
+	if err != nil {
+		log.Printf("Error caching %s variant of order with uid=%s in Redis: %v", variant, order_uid, err)
+	}
+}
+
+func (r *Redis) Delete(ctx context.Context, order_uid string) {
+	if err := r.client.Del(ctx, r.key(order_uid)).Err(); err != nil {
+		log.Printf("Error deleting order with uid=%s from Redis cache: %v", order_uid, err)
+	}
+}
+
+// Reset keeps the shared entries: writers delete changed orders from Redis themselves.
+func (r *Redis) Reset(ctx context.Context) {}
+
+// sharedVariant reports whether a variant, named <view>:<format>+<encoding>, may be stored in
+// Redis: only masked and redacted views may.
+func sharedVariant(variant string) bool {
+	view, _, _ := strings.Cut(variant, ":")
+	return view == dto.ViewMasked || view == dto.ViewRedacted
+}
+
+func (r *Redis) getField(ctx context.Context, order_uid string, field string, v any) bool {
+	value, err := r.client.HGet(ctx, r.key(order_uid), field).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return false
+	}
+	if err != nil {
+		log.Printf("Error reading order with uid=%s from Redis cache: %v", order_uid, err)
+		return false
+	}
+	if err := json.Unmarshal(value, v); err != nil {
+		log.Printf("Error decoding order with uid=%s from Redis cache: %v", order_uid, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+
+	"wbts/internal/domain/dto"
+	"wbts/internal/domain/entity"
+)
+
+// Tiered puts a local cache (L1) in front of a shared one (L2). Reads fill L1 from L2, writes
+// and deletes go to both. Writers on other instances only reach L1 through the LISTEN
+// invalidation: if a notification is lost without the connection dropping, the L1 entry is
+// served stale until its TTL expires, so L1 entries must live much shorter than L2 ones.
+type Tiered struct {
+	local  OrderCache
+	shared OrderCache
+}
+
+func NewTiered(local OrderCache, shared OrderCache) *Tiered {
+	return &Tiered{local: local, shared: shared}
+}
+
+func (t *Tiered) Get(ctx context.Context, order_uid string) (entity.OrderInfo, bool) {
+	if info, ok := t.local.Get(ctx, order_uid); ok {
+		return info, true
+	}
+	info, ok := t.shared.Get(ctx, order_uid)
+	if ok {
+		t.local.Set(ctx, order_uid, info)
+	}
+	return info, ok
+}
+
+func (t *Tiered) Set(ctx context.Context, order_uid string, info entity.OrderInfo) {
+	t.shared.Set(ctx, order_uid, info)
+	t.local.Set(ctx, order_uid, info)
+}
+
+func (t *Tiered) GetEncoded(ctx context.Context, order_uid string, variant string) (dto.EncodedOrderDTO, bool) {
+	if encoded, ok := t.local.GetEncoded(ctx, order_uid, variant); ok {
+		return encoded, true
+	}
+	encoded, ok := t.shared.GetEncoded(ctx, order_uid, variant)
+	if ok {
+		t.local.SetEncoded(ctx, order_uid, variant, encoded)
+	}
+	return encoded, ok
+}
+
+func (t *Tiered) SetEncoded(ctx context.Context, order_uid string, variant string, encoded dto.EncodedOrderDTO) {
+	t.shared.SetEncoded(ctx, order_uid, variant, encoded)
+	t.local.SetEncoded(ctx, order_uid, variant, encoded)
+}
+
+func (t *Tiered) Delete(ctx context.Context, order_uid string) {
+	t.shared.Delete(ctx, order_uid)
+	t.local.Delete(ctx, order_uid)
+}
+
+func (t *Tiered) Reset(ctx context.Context) {
+	t.local.Reset(ctx)
+	t.shared.Reset(ctx)
+}
@@ -0,0 +1,16 @@
+package dto
+
+import (
+	"time"
+)
+
+type CustomerExportDTO struct {
+	CustomerID string     `json:"customer_id"`
+	ExportedAt time.Time  `json:"exported_at"`
+	Orders     []OrderDTO `json:"orders"`
+}
+
+type CustomerAnonymizationDTO struct {
+	CustomerID       string   `json:"customer_id"`
+	AnonymizedOrders []string `json:"anonymized_orders"`
+}
@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"time"
+)
+
+const (
+	FormatJSON        = "json"
+	FormatCompactJSON = "json-compact"
+	FormatMsgPack     = "msgpack"
+	FormatCBOR        = "cbor"
+)
+
+const (
+	ViewFull     = "full"
+	ViewMasked   = "masked"
+	ViewRedacted = "redacted"
+)
+
+const (
+	EncodingIdentity = "identity"
+	EncodingGzip     = "gzip"
+	EncodingZstd     = "zstd"
+	EncodingBrotli   = "br"
+)
+
+type EncodedOrderDTO struct {
+	Body         []byte
+	ETag         string
+	LastModified time.Time
+}
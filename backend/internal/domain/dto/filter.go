@@ -0,0 +1,45 @@
+package dto
+
+import (
+	"slices"
+)
+
+type OrderSearchDTO struct {
+	CustomerID string
+	Phone      string
+	Email      string
+	Tenant     string
+}
+
+// IsEmpty reports whether the search has no criterion. Tenant only narrows a search and does
+// not count, so a search cannot list all orders of a tenant.
+func (s OrderSearchDTO) IsEmpty() bool {
+	return s.CustomerID == "" && s.Phone == "" && s.Email == ""
+}
+
+type OrderFilter struct {
+	OrderUIDs       []string
+	CustomerID      string
+	DeliveryService string
+	Tenant          string
+}
+
+func (f OrderFilter) IsEmpty() bool {
+	return len(f.OrderUIDs) == 0 && f.CustomerID == "" && f.DeliveryService == "" && f.Tenant == ""
+}
+
+func (f OrderFilter) Match(order OrderDTO) bool {
+	if len(f.OrderUIDs) > 0 && !slices.Contains(f.OrderUIDs, order.OrderUID) {
+		return false
+	}
+	if f.CustomerID != "" && f.CustomerID != order.CustomerID {
+		return false
+	}
+	if f.DeliveryService != "" && f.DeliveryService != order.DeliveryService {
+		return false
+	}
+	if f.Tenant != "" && f.Tenant != order.Tenant {
+		return false
+	}
+	return true
+}
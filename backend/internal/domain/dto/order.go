@@ -4,6 +4,9 @@ import (
 	"time"
 )
 
+// DefaultTenant is assigned to orders consumed from topics without an explicit tenant.
+const DefaultTenant = "default"
+
 type DeliveryDTO struct {
 	Name    string `json:"name" validate:"required"`
 	Phone   string `json:"phone" validate:"required,min=3,max=32"`
@@ -56,4 +59,5 @@ type OrderDTO struct {
 	SmID              int64       `json:"sm_id" validate:"gt=0"`
 	DateCreated       time.Time   `json:"date_created" validate:"required"`
 	OofShard          string      `json:"oof_shard" validate:"required"`
+	Tenant            string      `json:"tenant,omitempty"`
 }
@@ -0,0 +1,13 @@
+package entity
+
+type MigrationStatus struct {
+	Version int64
+	Dirty   bool
+	Applied bool
+}
+
+type IntegrityCheck struct {
+	Name       string
+	Violations int64
+	Samples    []string
+}
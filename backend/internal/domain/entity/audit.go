@@ -0,0 +1,13 @@
+package entity
+
+const (
+	AuditCustomerExport    = "customer.export"
+	AuditCustomerAnonymize = "customer.anonymize"
+)
+
+type AuditRecord struct {
+	Actor   string
+	Action  string
+	Subject string
+	Details []byte
+}
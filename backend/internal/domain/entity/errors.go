@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrOrderNotFound = errors.New("order not found")
+
+// ErrMessageProcessed is returned when a message's dedup key is already in the ledger.
+var ErrMessageProcessed = errors.New("message already processed")
+
+// ErrInvalidOrder is returned for orders the database rejects on every attempt, e.g. on a
+// constraint violation, so consumers skip the message instead of retrying it.
+var ErrInvalidOrder = errors.New("invalid order")
+
+// ErrTenantConflict is returned when an order_uid that is already stored for one tenant
+// arrives for another. Order uids are global, so such an order is rejected, not overwritten.
+var ErrTenantConflict = fmt.Errorf("%w: order_uid belongs to another tenant", ErrInvalidOrder)
+
+// ErrOrderChanged is returned by conditional writes when the order was modified after it was read.
+var ErrOrderChanged = errors.New("order was changed concurrently")
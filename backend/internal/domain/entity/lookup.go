@@ -0,0 +1,11 @@
+package entity
+
+type OrderLookup struct {
+	Phone         string
+	PhoneIndex    string
+	Email         string
+	EmailIndex    string
+	CustomerID    string
+	CustomerIndex string
+	Tenant        string
+}
@@ -45,6 +45,14 @@ type Order struct {
 	SmID              int64
 	DateCreated       time.Time
 	OofShard          string
+	PhoneIndex        string
+	EmailIndex        string
+	CustomerIndex     string
+	Tenant            string
+	// UpdatedAt is maintained by the database on every write and is never taken from input.
+	UpdatedAt         time.Time
+	// Anonymized orders have no phone or email, so their blind indexes are empty by design.
+	Anonymized        bool
 }
 
 type OrderInfo struct {
@@ -0,0 +1,18 @@
+package entity
+
+import (
+	"time"
+)
+
+const (
+	EventOrderCreated = "OrderCreated"
+	EventOrderUpdated = "OrderUpdated"
+)
+
+type OutboxEvent struct {
+	ID        int64
+	OrderUID  string
+	EventType string
+	Payload   []byte
+	CreatedAt time.Time
+}
@@ -0,0 +1,187 @@
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+const (
+	envelopeVersion = 1
+	keySize         = 32
+)
+
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+type keyringFile struct {
+	ActiveKeyID   string `json:"active_key_id"`
+	BlindIndexKey string `json:"blind_index_key"`
+	Keys          []struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	} `json:"keys"`
+}
+
+type Envelope struct {
+	Version    int    `json:"v"`
+	KeyID      string `json:"kid"`
+	WrappedKey string `json:"dek"`
+	Ciphertext string `json:"ct"`
+}
+
+type Keyring struct {
+	activeKeyID   string
+	keys          map[string]cipher.AEAD
+	blindIndexKey []byte
+}
+
+func Setup() *Keyring {
+	path := os.Getenv("ENCRYPTION_KEYRING_FILE")
+	if path == "" {
+		log.Println("Encryption at rest is disabled: ENCRYPTION_KEYRING_FILE is not set")
+		return nil
+	}
+
+	kr, err := LoadFile(path)
+	if err != nil {
+		log.Fatalf("Error loading keyring: %v", err)
+	}
+	log.Printf("Keyring loaded, active key id=%s", kr.ActiveKeyID())
+	return kr
+}
+
+func LoadFile(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f keyringFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, errors.New("Error parsing keyring file: " + err.Error())
+	}
+
+	kr := &Keyring{activeKeyID: f.ActiveKeyID, keys: make(map[string]cipher.AEAD, len(f.Keys))}
+	for _, k := range f.Keys {
+		raw, err := base64.StdEncoding.DecodeString(k.Key)
+		if err != nil || len(raw) != keySize {
+			return nil, fmt.Errorf("key %q must be %d base64 encoded bytes", k.ID, keySize)
+		}
+		aead, err := newAEAD(raw)
+		if err != nil {
+			return nil, err
+		}
+		kr.keys[k.ID] = aead
+	}
+	if _, ok := kr.keys[kr.activeKeyID]; !ok {
+		return nil, fmt.Errorf("active key %q is not in the keyring", kr.activeKeyID)
+	}
+
+	kr.blindIndexKey, err = base64.StdEncoding.DecodeString(f.BlindIndexKey)
+	if err != nil || len(kr.blindIndexKey) < keySize {
+		return nil, fmt.Errorf("blind_index_key must be at least %d base64 encoded bytes", keySize)
+	}
+	return kr, nil
+}
+
+func (k *Keyring) ActiveKeyID() string {
+	return k.activeKeyID
+}
+
+func (k *Keyring) Encrypt(plaintext []byte, aad []byte) (Envelope, error) {
+	dek := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return Envelope{}, err
+	}
+	dataAEAD, err := newAEAD(dek)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	ciphertext, err := seal(dataAEAD, plaintext, aad)
+	if err != nil {
+		return Envelope{}, err
+	}
+	wrapped, err := seal(k.keys[k.activeKeyID], dek, []byte(k.activeKeyID))
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	return Envelope{
+		Version:    envelopeVersion,
+		KeyID:      k.activeKeyID,
+		WrappedKey: base64.StdEncoding.EncodeToString(wrapped),
+		Ciphertext: base64.StdEncoding.EncodeToString(ciphertext),
+	}, nil
+}
+
+func (k *Keyring) Decrypt(env Envelope, aad []byte) ([]byte, error) {
+	kek, ok := k.keys[env.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, env.KeyID)
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(env.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	dek, err := open(kek, wrapped, []byte(env.KeyID))
+	if err != nil {
+		return nil, errors.New("Error unwrapping data key: " + err.Error())
+	}
+	dataAEAD, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(env.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(dataAEAD, ciphertext, aad)
+	if err != nil {
+		return nil, errors.New("Error decrypting data: " + err.Error())
+	}
+	return plaintext, nil
+}
+
+func (k *Keyring) BlindIndex(kind string, value string) string {
+	mac := hmac.New(sha256.New, k.blindIndexKey)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plaintext []byte, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, data []byte, aad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], aad)
+}
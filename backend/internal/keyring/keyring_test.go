@@ -0,0 +1,192 @@
+package keyring
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, keySize))
+}
+
+func writeKeyring(t *testing.T, activeKeyID string, blindIndexKey string, keys map[string]string) string {
+	t.Helper()
+	f := map[string]any{"active_key_id": activeKeyID, "blind_index_key": blindIndexKey}
+	var entries []map[string]string
+	for id, key := range keys {
+		entries = append(entries, map[string]string{"id": id, "key": key})
+	}
+	f["keys"] = entries
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		t.Fatalf("marshal keyring: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write keyring: %v", err)
+	}
+	return path
+}
+
+func loadKeyring(t *testing.T, activeKeyID string, keys map[string]string) *Keyring {
+	t.Helper()
+	kr, err := LoadFile(writeKeyring(t, activeKeyID, testKey('b'), keys))
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	return kr
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	kr := loadKeyring(t, "k1", map[string]string{"k1": testKey(1)})
+	plaintext := []byte(`{"name":"Test Testov"}`)
+	aad := []byte("order-1")
+
+	first, err := kr.Encrypt(plaintext, aad)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	second, err := kr.Encrypt(plaintext, aad)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if first.KeyID != "k1" || first.Version != envelopeVersion {
+		t.Errorf("envelope = %+v", first)
+	}
+	if first.Ciphertext == second.Ciphertext || first.WrappedKey == second.WrappedKey {
+		t.Error("two encryptions of the same plaintext share a data key or nonce")
+	}
+	if strings.Contains(first.Ciphertext, base64.StdEncoding.EncodeToString(plaintext)) {
+		t.Error("ciphertext contains the plaintext")
+	}
+
+	for _, env := range []Envelope{first, second} {
+		decrypted, err := kr.Decrypt(env, aad)
+		if err != nil || !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("Decrypt = %q, %v", decrypted, err)
+		}
+	}
+}
+
+func TestDecryptAfterRotation(t *testing.T) {
+	old := loadKeyring(t, "k1", map[string]string{"k1": testKey(1)})
+	env, err := old.Encrypt([]byte("secret"), nil)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	rotated := loadKeyring(t, "k2", map[string]string{"k1": testKey(1), "k2": testKey(2)})
+	if decrypted, err := rotated.Decrypt(env, nil); err != nil || string(decrypted) != "secret" {
+		t.Errorf("Decrypt with the retired key = %q, %v", decrypted, err)
+	}
+	if reencrypted, _ := rotated.Encrypt([]byte("secret"), nil); reencrypted.KeyID != "k2" {
+		t.Errorf("new envelopes use key %q, want the active k2", reencrypted.KeyID)
+	}
+}
+
+func TestDecryptRejectsWrongKeysAndTampering(t *testing.T) {
+	kr := loadKeyring(t, "k1", map[string]string{"k1": testKey(1)})
+	aad := []byte("order-1")
+	env, err := kr.Encrypt([]byte("secret"), aad)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	flip := func(encoded string) string {
+		raw, _ := base64.StdEncoding.DecodeString(encoded)
+		raw[len(raw)-1] ^= 1
+		return base64.StdEncoding.EncodeToString(raw)
+	}
+	tamperedCiphertext := env
+	tamperedCiphertext.Ciphertext = flip(env.Ciphertext)
+	tamperedKey := env
+	tamperedKey.WrappedKey = flip(env.WrappedKey)
+	relabeled := env
+	relabeled.KeyID = "k2"
+
+	wrongKEK := loadKeyring(t, "k1", map[string]string{"k1": testKey(9)})
+	bothKeys := loadKeyring(t, "k1", map[string]string{"k1": testKey(1), "k2": testKey(2)})
+
+	tests := []struct {
+		name    string
+		keyring *Keyring
+		env     Envelope
+		aad     []byte
+		wantErr error
+	}{
+		{"wrong key encryption key", wrongKEK, env, aad, nil},
+		{"unknown key id", loadKeyring(t, "k2", map[string]string{"k2": testKey(2)}), env, aad, ErrUnknownKey},
+		{"data key wrapped for another key id", bothKeys, relabeled, aad, nil},
+		{"tampered ciphertext", kr, tamperedCiphertext, aad, nil},
+		{"tampered data key", kr, tamperedKey, aad, nil},
+		{"other order", kr, env, []byte("order-2"), nil},
+		{"truncated ciphertext", kr, Envelope{Version: 1, KeyID: "k1", WrappedKey: env.WrappedKey, Ciphertext: "AAAA"}, aad, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decrypted, err := tt.keyring.Decrypt(tt.env, tt.aad)
+			if err == nil {
+				t.Fatalf("Decrypt = %q, want an error", decrypted)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	kr := loadKeyring(t, "k1", map[string]string{"k1": testKey(1)})
+	// The blind index key is independent of the encryption keys, so rotating them keeps indexes.
+	rotated := loadKeyring(t, "k2", map[string]string{"k2": testKey(2)})
+
+	index := kr.BlindIndex("phone", "+9720000000")
+	if len(index) != 32 || strings.Contains(index, "9720000000") {
+		t.Errorf("index = %q, want 32 hex characters", index)
+	}
+	if again := rotated.BlindIndex("phone", "+9720000000"); again != index {
+		t.Errorf("index changed between keyrings with the same blind index key: %s != %s", again, index)
+	}
+	if kr.BlindIndex("phone", "+9720000001") == index {
+		t.Error("different values share an index")
+	}
+	if kr.BlindIndex("email", "+9720000000") == index {
+		t.Error("the same value has the same index for different kinds")
+	}
+
+	other, err := LoadFile(writeKeyring(t, "k1", testKey('c'), map[string]string{"k1": testKey(1)}))
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if other.BlindIndex("phone", "+9720000000") == index {
+		t.Error("index does not depend on the blind index key")
+	}
+}
+
+func TestLoadFileRejectsInvalidKeyrings(t *testing.T) {
+	short := base64.StdEncoding.EncodeToString([]byte("short"))
+	tests := []struct {
+		name          string
+		activeKeyID   string
+		blindIndexKey string
+		keys          map[string]string
+	}{
+		{"short key", "k1", testKey('b'), map[string]string{"k1": short}},
+		{"key is not base64", "k1", testKey('b'), map[string]string{"k1": "not base64!"}},
+		{"active key missing", "k2", testKey('b'), map[string]string{"k1": testKey(1)}},
+		{"short blind index key", "k1", short, map[string]string{"k1": testKey(1)}},
+	}
+	for _, tt := range tests {
+		if _, err := LoadFile(writeKeyring(t, tt.activeKeyID, tt.blindIndexKey, tt.keys)); err == nil {
+			t.Errorf("%s: LoadFile succeeded", tt.name)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"wbts/internal/domain/dto"
+)
+
+var cborEncMode, _ = cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+
+func Marshal(format string, v any) ([]byte, error) {
+	switch format {
+	case dto.FormatJSON:
+		return json.MarshalIndent(v, "", "    ")
+	case dto.FormatCompactJSON:
+		return json.Marshal(v)
+	case dto.FormatMsgPack:
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		enc.SetCustomStructTag("json")
+		enc.UseCompactInts(true)
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case dto.FormatCBOR:
+		return cborEncMode.Marshal(v)
+	default:
+		return nil, errors.New("Unsupported format: " + format)
+	}
+}
+
+func ContentType(format string) string {
+	switch format {
+	case dto.FormatMsgPack:
+		return "application/msgpack"
+	case dto.FormatCBOR:
+		return "application/cbor"
+	default:
+		return "application/json; charset=utf-8"
+	}
+}
+
+// encodingWriter is implemented by the gzip, zstd and brotli writers. Reset lets a writer be
+// reused for another stream, so the pools below keep their state and buffers between responses.
+type encodingWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var encodingWriterPools = map[string]*sync.Pool{
+	dto.EncodingGzip: {New: func() any { return gzip.NewWriter(nil) }},
+	dto.EncodingZstd: {New: func() any {
+		// Responses are small, so a single goroutine per encoder is enough.
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return enc
+	}},
+	dto.EncodingBrotli: {New: func() any { return brotli.NewWriterLevel(nil, brotli.DefaultCompression) }},
+}
+
+// pooledWriter returns its encoder to the pool once the stream is closed.
+type pooledWriter struct {
+	encodingWriter
+	pool *sync.Pool
+}
+
+func (w *pooledWriter) Close() error {
+	if w.encodingWriter == nil {
+		return nil
+	}
+	err := w.encodingWriter.Close()
+	w.encodingWriter.Reset(nil)
+	w.pool.Put(w.encodingWriter)
+	w.encodingWriter = nil
+	return err
+}
+
+// NewEncodingWriter compresses everything written to it into w. The writer must be closed to
+// finish the stream and release the pooled encoder; it must not be used after Close.
+func NewEncodingWriter(w io.Writer, encoding string) (io.WriteCloser, error) {
+	pool, ok := encodingWriterPools[encoding]
+	if !ok {
+		return nil, errors.New("Unsupported encoding: " + encoding)
+	}
+	cw := pool.Get().(encodingWriter)
+	cw.Reset(w)
+	return &pooledWriter{cw, pool}, nil
+}
+
+func Compress(encoding string, body []byte) ([]byte, error) {
+	if encoding == dto.EncodingIdentity {
+		return body, nil
+	}
+
+	var buf bytes.Buffer
+	cw, err := NewEncodingWriter(&buf, encoding)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := cw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,112 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"wbts/internal/domain/dto"
+)
+
+func decompress(encoding string, body []byte) ([]byte, error) {
+	var r io.Reader
+	switch encoding {
+	case dto.EncodingIdentity:
+		return body, nil
+	case dto.EncodingGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		r = gr
+	case dto.EncodingZstd:
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case dto.EncodingBrotli:
+		r = brotli.NewReader(bytes.NewReader(body))
+	}
+	return io.ReadAll(r)
+}
+
+func TestCompressRoundTrip(t *testing.T) {
+	body := []byte(strings.Repeat(`{"order_uid":"b563feb7b2b84b6test"}`, 100))
+	encodings := []string{dto.EncodingIdentity, dto.EncodingGzip, dto.EncodingZstd, dto.EncodingBrotli}
+
+	for _, encoding := range encodings {
+		t.Run(encoding, func(t *testing.T) {
+			// The second round reuses the pooled encoder of the first one.
+			for range 2 {
+				compressed, err := Compress(encoding, body)
+				if err != nil {
+					t.Fatalf("Compress: %v", err)
+				}
+				if encoding != dto.EncodingIdentity && len(compressed) >= len(body) {
+					t.Errorf("compressed to %d bytes from %d", len(compressed), len(body))
+				}
+				decoded, err := decompress(encoding, compressed)
+				if err != nil || !bytes.Equal(decoded, body) {
+					t.Fatalf("round trip = %q, %v", decoded, err)
+				}
+			}
+		})
+	}
+
+	if _, err := Compress("lz4", body); err == nil {
+		t.Error("unsupported encoding was accepted")
+	}
+}
+
+// TestPooledEncodersAreIndependent checks that concurrent streams never share an encoder.
+func TestPooledEncodersAreIndependent(t *testing.T) {
+	for _, encoding := range []string{dto.EncodingGzip, dto.EncodingZstd, dto.EncodingBrotli} {
+		t.Run(encoding, func(t *testing.T) {
+			var wg sync.WaitGroup
+			errs := make(chan error, 20)
+			for i := range 20 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					body := []byte(strings.Repeat(fmt.Sprintf("order-%d ", i), 50))
+					var buf bytes.Buffer
+					cw, err := NewEncodingWriter(&buf, encoding)
+					if err != nil {
+						errs <- err
+						return
+					}
+					// Write in two parts with a flush in between, as streamed responses do.
+					half := len(body) / 2
+					cw.Write(body[:half])
+					cw.(interface{ Flush() error }).Flush()
+					cw.Write(body[half:])
+					if err := cw.Close(); err != nil {
+						errs <- err
+						return
+					}
+					if err := cw.Close(); err != nil {
+						errs <- fmt.Errorf("second Close: %w", err)
+						return
+					}
+					if decoded, err := decompress(encoding, buf.Bytes()); err != nil || !bytes.Equal(decoded, body) {
+						errs <- fmt.Errorf("stream %d decoded to %q, %v", i, decoded, err)
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Error(err)
+			}
+		})
+	}
+}
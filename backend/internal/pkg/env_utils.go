@@ -0,0 +1,51 @@
+package pkg
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+func GetEnv(key string, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return fallback
+}
+
+func GetEnvBool(key string, fallback bool) bool {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Fatalf("Invalid boolean value %q for %s: %v", v, key, err)
+	}
+	return b
+}
+
+func GetEnvInt(key string, fallback int) int {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("Invalid integer value %q for %s: %v", v, key, err)
+	}
+	return i
+}
+
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("Invalid duration value %q for %s: %v", v, key, err)
+	}
+	return d
+}
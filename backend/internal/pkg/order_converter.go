@@ -7,9 +7,13 @@ import (
 
 	"wbts/internal/domain/dto"
 	"wbts/internal/domain/entity"
+	"wbts/internal/keyring"
 )
 
-type OrderConverter struct{}
+type OrderConverter struct {
+	Keyring           *keyring.Keyring
+	EncryptCustomerID bool
+}
 
 func (c *OrderConverter) PaymentDTOToEntity(dto dto.PaymentDTO) entity.Payment {
 	return entity.Payment{
@@ -85,21 +89,33 @@ func (c *OrderConverter) OrderDTOToOrderInfo(dto dto.OrderDTO) (entity.OrderInfo
 	if err != nil {
 		return entity.OrderInfo{}, err
 	}
+	delivery, err := c.protectDelivery(dto.OrderUID, deliveryJSON)
+	if err != nil {
+		return entity.OrderInfo{}, err
+	}
+	customerID, err := c.protectCustomerID(dto.OrderUID, dto.CustomerID)
+	if err != nil {
+		return entity.OrderInfo{}, err
+	}
 
 	order := entity.Order{
 		OrderUID:          dto.OrderUID,
 		TrackNumber:       dto.TrackNumber,
 		Entry:             dto.Entry,
-		Delivery:          string(deliveryJSON),
+		Delivery:          delivery,
 		PaymentID:         dto.Payment.Transaction,
 		Locale:            dto.Locale,
 		InternalSignature: dto.InternalSignature,
-		CustomerID:        dto.CustomerID,
+		CustomerID:        customerID,
 		DeliveryService:   dto.DeliveryService,
 		Shardkey:          dto.Shardkey,
 		SmID:              dto.SmID,
 		DateCreated:       dto.DateCreated,
 		OofShard:          dto.OofShard,
+		PhoneIndex:        c.BlindIndex(BlindIndexPhone, dto.Delivery.Phone),
+		EmailIndex:        c.BlindIndex(BlindIndexEmail, dto.Delivery.Email),
+		CustomerIndex:     c.BlindIndex(BlindIndexCustomer, dto.CustomerID),
+		Tenant:            dto.Tenant,
 	}
 
 	return entity.OrderInfo{
@@ -110,8 +126,17 @@ func (c *OrderConverter) OrderDTOToOrderInfo(dto dto.OrderDTO) (entity.OrderInfo
 }
 
 func (c *OrderConverter) OrderInfoToOrderDTO(info entity.OrderInfo) (dto.OrderDTO, error) {
+	deliveryJSON, err := c.revealDelivery(info.Order.OrderUID, info.Order.Delivery)
+	if err != nil {
+		return dto.OrderDTO{}, err
+	}
+	customerID, err := c.revealCustomerID(info.Order.OrderUID, info.Order.CustomerID)
+	if err != nil {
+		return dto.OrderDTO{}, err
+	}
+
 	var deliveryDTO dto.DeliveryDTO
-	err := json.Unmarshal([]byte(info.Order.Delivery), &deliveryDTO)
+	err = json.Unmarshal(deliveryJSON, &deliveryDTO)
 	if err != nil {
 		return dto.OrderDTO{}, err
 	}
@@ -132,11 +157,36 @@ func (c *OrderConverter) OrderInfoToOrderDTO(info entity.OrderInfo) (dto.OrderDT
 		Items:             itemsDTO,
 		Locale:            info.Order.Locale,
 		InternalSignature: info.Order.InternalSignature,
-		CustomerID:        info.Order.CustomerID,
+		CustomerID:        customerID,
 		DeliveryService:   info.Order.DeliveryService,
 		Shardkey:          info.Order.Shardkey,
 		SmID:              info.Order.SmID,
 		DateCreated:       info.Order.DateCreated,
 		OofShard:          info.Order.OofShard,
+		Tenant:            info.Order.Tenant,
 	}, nil
 }
+
+func (c *OrderConverter) OrderInfoToOrderView(info entity.OrderInfo, view string) (dto.OrderDTO, error) {
+	order, err := c.OrderInfoToOrderDTO(info)
+	if err != nil {
+		return dto.OrderDTO{}, err
+	}
+	return c.MaskOrderDTO(order, view), nil
+}
+
+func (c *OrderConverter) MaskOrderDTO(order dto.OrderDTO, view string) dto.OrderDTO {
+	if view == dto.ViewFull {
+		return order
+	}
+
+	mask, ok := deliveryMasks[view]
+	if !ok {
+		mask = deliveryMasks[dto.ViewRedacted]
+	}
+	order.Delivery.Name = mask.name(order.Delivery.Name)
+	order.Delivery.Phone = mask.phone(order.Delivery.Phone)
+	order.Delivery.Address = mask.address(order.Delivery.Address)
+	order.Delivery.Email = mask.email(order.Delivery.Email)
+	return order
+}
@@ -0,0 +1,72 @@
+package pkg
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"wbts/internal/domain/dto"
+)
+
+const redactedValue = "***"
+
+type deliveryMask struct {
+	name    func(string) string
+	phone   func(string) string
+	address func(string) string
+	email   func(string) string
+}
+
+var deliveryMasks = map[string]deliveryMask{
+	dto.ViewMasked: {
+		name:    maskName,
+		phone:   maskPhone,
+		address: redact,
+		email:   maskEmail,
+	},
+	dto.ViewRedacted: {
+		name:    redact,
+		phone:   redact,
+		address: redact,
+		email:   redact,
+	},
+}
+
+func redact(string) string {
+	return redactedValue
+}
+
+func maskName(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		first, size := utf8.DecodeRuneInString(word)
+		words[i] = string(first) + strings.Repeat("*", utf8.RuneCountInString(word[size:]))
+	}
+	return strings.Join(words, " ")
+}
+
+func maskPhone(phone string) string {
+	const visibleDigits = 2
+
+	runes := []rune(phone)
+	visible := 0
+	for i := len(runes) - 1; i >= 0; i-- {
+		if runes[i] < '0' || runes[i] > '9' {
+			continue
+		}
+		if visible < visibleDigits {
+			visible++
+			continue
+		}
+		runes[i] = '*'
+	}
+	return string(runes)
+}
+
+func maskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return redactedValue
+	}
+	first, _ := utf8.DecodeRuneInString(local)
+	return string(first) + redactedValue + "@" + domain
+}
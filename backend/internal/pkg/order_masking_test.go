@@ -0,0 +1,72 @@
+package pkg
+
+import (
+	"testing"
+
+	"wbts/internal/domain/dto"
+)
+
+func TestMasks(t *testing.T) {
+	tests := []struct {
+		mask  func(string) string
+		value string
+		want  string
+	}{
+		{maskName, "Test Testov", "T*** T*****"},
+		{maskName, "Иван  Петров", "И*** П*****"},
+		{maskName, "", ""},
+		{maskPhone, "+9720000000", "+********00"},
+		{maskPhone, "+7 (999) 123-45-67", "+* (***) ***-**-67"},
+		{maskPhone, "5", "5"},
+		{maskEmail, "test@gmail.com", "t***@gmail.com"},
+		{maskEmail, "тест@почта.рф", "т***@почта.рф"},
+		{maskEmail, "@gmail.com", "***"},
+		{maskEmail, "not an email", "***"},
+		{redact, "Ploshad Mira 15", "***"},
+	}
+	for _, tt := range tests {
+		if got := tt.mask(tt.value); got != tt.want {
+			t.Errorf("mask(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMaskOrderDTO(t *testing.T) {
+	converter := &OrderConverter{}
+	order := dto.OrderDTO{
+		OrderUID: "b563feb7b2b84b6test",
+		Delivery: dto.DeliveryDTO{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+	}
+
+	tests := []struct {
+		view string
+		want dto.DeliveryDTO
+	}{
+		{dto.ViewFull, order.Delivery},
+		{dto.ViewMasked, dto.DeliveryDTO{
+			Name: "T*** T*****", Phone: "+********00", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "***", Region: "Kraiot", Email: "t***@gmail.com",
+		}},
+		{dto.ViewRedacted, dto.DeliveryDTO{
+			Name: "***", Phone: "***", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "***", Region: "Kraiot", Email: "***",
+		}},
+		// An unknown view must never reveal more than the redacted one.
+		{"unknown", dto.DeliveryDTO{
+			Name: "***", Phone: "***", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "***", Region: "Kraiot", Email: "***",
+		}},
+	}
+	for _, tt := range tests {
+		masked := converter.MaskOrderDTO(order, tt.view)
+		if masked.Delivery != tt.want || masked.OrderUID != order.OrderUID {
+			t.Errorf("%s view: delivery = %+v, want %+v", tt.view, masked.Delivery, tt.want)
+		}
+	}
+	if order.Delivery.Phone != "+9720000000" {
+		t.Error("masking changed the original order")
+	}
+}
@@ -0,0 +1,186 @@
+package pkg
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"wbts/internal/domain/dto"
+	"wbts/internal/domain/entity"
+	"wbts/internal/keyring"
+)
+
+const (
+	encryptedCustomerPrefix = "enc:"
+
+	BlindIndexPhone    = "phone"
+	BlindIndexEmail    = "email"
+	BlindIndexCustomer = "customer_id"
+)
+
+func NormalizePhone(phone string) string {
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (c *OrderConverter) BlindIndex(kind string, value string) string {
+	if c.Keyring == nil || value == "" {
+		return ""
+	}
+
+	switch kind {
+	case BlindIndexPhone:
+		value = NormalizePhone(value)
+	case BlindIndexEmail:
+		value = NormalizeEmail(value)
+	}
+	return c.Keyring.BlindIndex(kind, value)
+}
+
+func (c *OrderConverter) ReencryptOrder(order entity.Order) (entity.Order, bool, error) {
+	if c.Keyring == nil {
+		return order, false, errors.New("keyring is not configured")
+	}
+
+	deliveryKeyID, deliveryEncrypted := storedDeliveryKeyID(order.Delivery)
+	customerKeyID, customerEncrypted := storedCustomerKeyID(order.CustomerID)
+	indexed := order.CustomerIndex != "" && (order.Anonymized || order.PhoneIndex != "" && order.EmailIndex != "")
+	upToDate := deliveryEncrypted && deliveryKeyID == c.Keyring.ActiveKeyID() &&
+		customerEncrypted == c.EncryptCustomerID &&
+		(!customerEncrypted || customerKeyID == c.Keyring.ActiveKeyID()) &&
+		indexed
+	if upToDate {
+		return order, false, nil
+	}
+
+	deliveryJSON, err := c.revealDelivery(order.OrderUID, order.Delivery)
+	if err != nil {
+		return order, false, err
+	}
+	customerID, err := c.revealCustomerID(order.OrderUID, order.CustomerID)
+	if err != nil {
+		return order, false, err
+	}
+
+	var delivery dto.DeliveryDTO
+	if err := json.Unmarshal(deliveryJSON, &delivery); err != nil {
+		return order, false, err
+	}
+
+	if order.Delivery, err = c.protectDelivery(order.OrderUID, deliveryJSON); err != nil {
+		return order, false, err
+	}
+	if order.CustomerID, err = c.protectCustomerID(order.OrderUID, customerID); err != nil {
+		return order, false, err
+	}
+	order.PhoneIndex = c.BlindIndex(BlindIndexPhone, delivery.Phone)
+	order.EmailIndex = c.BlindIndex(BlindIndexEmail, delivery.Email)
+	order.CustomerIndex = c.BlindIndex(BlindIndexCustomer, customerID)
+	return order, true, nil
+}
+
+func (c *OrderConverter) protectDelivery(order_uid string, deliveryJSON []byte) (string, error) {
+	if c.Keyring == nil {
+		return string(deliveryJSON), nil
+	}
+
+	env, err := c.Keyring.Encrypt(deliveryJSON, deliveryAAD(order_uid))
+	if err != nil {
+		return "", err
+	}
+	envJSON, err := json.Marshal(env)
+	if err != nil {
+		return "", err
+	}
+	return string(envJSON), nil
+}
+
+func (c *OrderConverter) revealDelivery(order_uid string, stored string) ([]byte, error) {
+	env, ok := parseDeliveryEnvelope(stored)
+	if !ok {
+		return []byte(stored), nil
+	}
+	if c.Keyring == nil {
+		return nil, errors.New("delivery of order " + order_uid + " is encrypted but keyring is not configured")
+	}
+	return c.Keyring.Decrypt(env, deliveryAAD(order_uid))
+}
+
+func (c *OrderConverter) protectCustomerID(order_uid string, customerID string) (string, error) {
+	if c.Keyring == nil || !c.EncryptCustomerID {
+		return customerID, nil
+	}
+
+	env, err := c.Keyring.Encrypt([]byte(customerID), customerAAD(order_uid))
+	if err != nil {
+		return "", err
+	}
+	envJSON, err := json.Marshal(env)
+	if err != nil {
+		return "", err
+	}
+	return encryptedCustomerPrefix + base64.RawURLEncoding.EncodeToString(envJSON), nil
+}
+
+func (c *OrderConverter) revealCustomerID(order_uid string, stored string) (string, error) {
+	env, ok := parseCustomerEnvelope(stored)
+	if !ok {
+		return stored, nil
+	}
+	if c.Keyring == nil {
+		return "", errors.New("customer_id of order " + order_uid + " is encrypted but keyring is not configured")
+	}
+	customerID, err := c.Keyring.Decrypt(env, customerAAD(order_uid))
+	if err != nil {
+		return "", err
+	}
+	return string(customerID), nil
+}
+
+func parseDeliveryEnvelope(stored string) (keyring.Envelope, bool) {
+	var env keyring.Envelope
+	if err := json.Unmarshal([]byte(stored), &env); err != nil {
+		return keyring.Envelope{}, false
+	}
+	return env, env.Version > 0 && env.KeyID != "" && env.Ciphertext != ""
+}
+
+func parseCustomerEnvelope(stored string) (keyring.Envelope, bool) {
+	encoded, ok := strings.CutPrefix(stored, encryptedCustomerPrefix)
+	if !ok {
+		return keyring.Envelope{}, false
+	}
+	envJSON, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return keyring.Envelope{}, false
+	}
+	return parseDeliveryEnvelope(string(envJSON))
+}
+
+func storedDeliveryKeyID(stored string) (string, bool) {
+	env, ok := parseDeliveryEnvelope(stored)
+	return env.KeyID, ok
+}
+
+func storedCustomerKeyID(stored string) (string, bool) {
+	env, ok := parseCustomerEnvelope(stored)
+	return env.KeyID, ok
+}
+
+func deliveryAAD(order_uid string) []byte {
+	return []byte("orders.delivery:" + order_uid)
+}
+
+func customerAAD(order_uid string) []byte {
+	return []byte("orders.customer_id:" + order_uid)
+}
@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var ErrInvalidField = errors.New("invalid field")
+
+type fieldTree map[string]fieldTree
+
+func ParseFields(raw string) []string {
+	var fields []string
+	for _, field := range strings.Split(raw, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func Project(v any, fields []string) (map[string]any, error) {
+	tree := fieldTree{}
+	for _, field := range fields {
+		node := tree
+		for _, name := range strings.Split(field, ".") {
+			if name == "" {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidField, field)
+			}
+			if node[name] == nil {
+				node[name] = fieldTree{}
+			}
+			node = node[name]
+		}
+	}
+
+	return projectStruct(reflect.ValueOf(v), tree, "")
+}
+
+func projectStruct(v reflect.Value, tree fieldTree, prefix string) (map[string]any, error) {
+	out := make(map[string]any, len(tree))
+	for name, subtree := range tree {
+		path := prefix + name
+		fv, ok := fieldByJSONName(v, name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidField, path)
+		}
+		if len(subtree) == 0 {
+			out[name] = fv.Interface()
+			continue
+		}
+
+		switch {
+		case isNestedStruct(fv.Type()):
+			projected, err := projectStruct(fv, subtree, path+".")
+			if err != nil {
+				return nil, err
+			}
+			out[name] = projected
+		case fv.Kind() == reflect.Slice && isNestedStruct(fv.Type().Elem()):
+			// An empty slice has no element to check the nested fields against, so they are
+			// checked against a zero element: the same request must not fail only for some orders.
+			if fv.Len() == 0 {
+				if _, err := projectStruct(reflect.Zero(fv.Type().Elem()), subtree, path+"."); err != nil {
+					return nil, err
+				}
+			}
+			projected := make([]map[string]any, 0, fv.Len())
+			for i := range fv.Len() {
+				elem, err := projectStruct(fv.Index(i), subtree, path+".")
+				if err != nil {
+					return nil, err
+				}
+				projected = append(projected, elem)
+			}
+			out[name] = projected
+		default:
+			return nil, fmt.Errorf("%w: %q has no nested fields", ErrInvalidField, path)
+		}
+	}
+	return out, nil
+}
+
+func fieldByJSONName(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := range t.NumField() {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if tag == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func isNestedStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != reflect.TypeOf(time.Time{})
+}
@@ -0,0 +1,112 @@
+package pkg
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"wbts/internal/domain/dto"
+)
+
+func projectionOrder() dto.OrderDTO {
+	return dto.OrderDTO{
+		OrderUID:    "b563feb7b2b84b6test",
+		Delivery:    dto.DeliveryDTO{Name: "Test Testov", City: "Kiryat Mozkin"},
+		Payment:     dto.PaymentDTO{Amount: 1817, Currency: "USD"},
+		Items:       []dto.ItemDTO{{Name: "Mascaras", Price: 453}, {Name: "Lipstick", Price: 120}},
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	if got := ParseFields(" order_uid, ,items.name,"); !slices.Equal(got, []string{"order_uid", "items.name"}) {
+		t.Errorf("ParseFields = %q", got)
+	}
+	if got := ParseFields(""); got != nil {
+		t.Errorf("ParseFields(\"\") = %q, want nil", got)
+	}
+}
+
+func TestProject(t *testing.T) {
+	order := projectionOrder()
+	tests := []struct {
+		name   string
+		fields []string
+		want   map[string]any
+	}{
+		{"top-level field", []string{"order_uid"}, map[string]any{"order_uid": order.OrderUID}},
+		{"whole struct", []string{"delivery"}, map[string]any{"delivery": order.Delivery}},
+		{"time is a leaf", []string{"date_created"}, map[string]any{"date_created": order.DateCreated}},
+		{
+			"nested fields",
+			[]string{"delivery.city", "payment.amount", "payment.currency"},
+			map[string]any{
+				"delivery": map[string]any{"city": "Kiryat Mozkin"},
+				"payment":  map[string]any{"amount": int64(1817), "currency": "USD"},
+			},
+		},
+		{
+			"fields of every item",
+			[]string{"items.name"},
+			map[string]any{"items": []map[string]any{{"name": "Mascaras"}, {"name": "Lipstick"}}},
+		},
+		{
+			"duplicate and overlapping fields",
+			[]string{"order_uid", "order_uid", "items.price"},
+			map[string]any{
+				"order_uid": order.OrderUID,
+				"items":     []map[string]any{{"price": int64(453)}, {"price": int64(120)}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Project(order, tt.fields)
+			if err != nil {
+				t.Fatalf("Project: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Project = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProjectRejectsInvalidFields(t *testing.T) {
+	withoutItems := projectionOrder()
+	withoutItems.Items = nil
+
+	tests := []struct {
+		name   string
+		order  dto.OrderDTO
+		fields []string
+	}{
+		{"unknown field", projectionOrder(), []string{"order_id"}},
+		{"Go field name", projectionOrder(), []string{"OrderUID"}},
+		{"unknown nested field", projectionOrder(), []string{"delivery.street"}},
+		{"unknown item field", projectionOrder(), []string{"items.color"}},
+		{"unknown item field without items", withoutItems, []string{"items.color"}},
+		{"nested field of a scalar", projectionOrder(), []string{"order_uid.length"}},
+		{"nested field of a time", projectionOrder(), []string{"date_created.year"}},
+		{"empty segment", projectionOrder(), []string{"delivery..city"}},
+		{"trailing dot", projectionOrder(), []string{"delivery."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := Project(tt.order, tt.fields); !errors.Is(err, ErrInvalidField) {
+				t.Errorf("Project(%q) = %v, %v, want ErrInvalidField", tt.fields, got, err)
+			}
+		})
+	}
+
+	got, err := Project(withoutItems, []string{"items.name"})
+	if err != nil {
+		t.Fatalf("Project without items: %v", err)
+	}
+	if items := got["items"].([]map[string]any); len(items) != 0 {
+		t.Errorf("items = %v, want none", items)
+	}
+}
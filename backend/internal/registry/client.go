@@ -0,0 +1,140 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TypeAvro     = "AVRO"
+	TypeProtobuf = "PROTOBUF"
+	TypeJSON     = "JSON"
+
+	contentType = "application/vnd.schemaregistry.v1+json"
+)
+
+var ErrSchemaNotFound = errors.New("schema not found")
+
+type Schema struct {
+	ID         int    `json:"id,omitempty"`
+	SchemaType string `json:"schemaType,omitempty"`
+	Schema     string `json:"schema"`
+}
+
+type errorResponse struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+// Client talks to a Confluent Schema Registry compatible HTTP API. Schemas are immutable
+// per id, so lookups are cached for the lifetime of the client.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	cache      map[int]Schema
+	mtx        sync.RWMutex
+}
+
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: httpClient,
+		cache:      make(map[int]Schema),
+	}
+}
+
+func Setup() *Client {
+	baseURL := os.Getenv("SCHEMA_REGISTRY_URL")
+	if baseURL == "" {
+		log.Println("Binary order messages are disabled: SCHEMA_REGISTRY_URL is not set")
+		return nil
+	}
+	return NewClient(baseURL, &http.Client{Timeout: 10 * time.Second})
+}
+
+func (c *Client) SchemaByID(ctx context.Context, id int) (Schema, error) {
+	c.mtx.RLock()
+	schema, ok := c.cache[id]
+	c.mtx.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	if err := c.do(ctx, http.MethodGet, "/schemas/ids/"+strconv.Itoa(id), nil, &schema); err != nil {
+		return Schema{}, fmt.Errorf("get schema id=%d: %w", id, err)
+	}
+	schema.ID = id
+	if schema.SchemaType == "" {
+		schema.SchemaType = TypeAvro
+	}
+
+	c.mtx.Lock()
+	c.cache[id] = schema
+	c.mtx.Unlock()
+	return schema, nil
+}
+
+// Register adds the schema under the subject, or returns the id of an identical schema
+// registered before.
+func (c *Client) Register(ctx context.Context, subject string, schema Schema) (int, error) {
+	if schema.SchemaType == TypeAvro {
+		schema.SchemaType = ""
+	}
+
+	var registered struct {
+		ID int `json:"id"`
+	}
+	path := "/subjects/" + url.PathEscape(subject) + "/versions"
+	if err := c.do(ctx, http.MethodPost, path, Schema{SchemaType: schema.SchemaType, Schema: schema.Schema}, &registered); err != nil {
+		return 0, fmt.Errorf("register schema for subject %s: %w", subject, err)
+	}
+	return registered.ID, nil
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, in any, out any) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", contentType)
+	if in != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr errorResponse
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&apiErr)
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%w: %s", ErrSchemaNotFound, apiErr.Message)
+		}
+		return fmt.Errorf("registry responded %d: %s", resp.StatusCode, apiErr.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
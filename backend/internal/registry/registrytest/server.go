@@ -0,0 +1,106 @@
+// Package registrytest provides an in-process Schema Registry for tests and local runs.
+package registrytest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+
+	"wbts/internal/registry"
+)
+
+type Server struct {
+	*httptest.Server
+
+	schemas  []registry.Schema
+	subjects map[string][]int
+	mtx      sync.Mutex
+}
+
+func NewServer() *Server {
+	s := &Server{subjects: make(map[string][]int)}
+	s.Server = httptest.NewServer(s.Handler())
+	return s
+}
+
+// Handler serves the subset of the registry API used by registry.Client.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /schemas/ids/{id}", s.getSchema)
+	mux.HandleFunc("POST /subjects/{subject}/versions", s.register)
+	mux.HandleFunc("GET /subjects", s.listSubjects)
+	return mux
+}
+
+// Add registers a schema directly and returns its id.
+func (s *Server) Add(subject string, schema registry.Schema) int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if schema.SchemaType == "" {
+		schema.SchemaType = registry.TypeAvro
+	}
+	for _, id := range s.subjects[subject] {
+		existing := s.schemas[id-1]
+		if existing.SchemaType == schema.SchemaType && existing.Schema == schema.Schema {
+			return id
+		}
+	}
+
+	schema.ID = len(s.schemas) + 1
+	s.schemas = append(s.schemas, schema)
+	s.subjects[subject] = append(s.subjects[subject], schema.ID)
+	return schema.ID
+}
+
+func (s *Server) getSchema(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if err != nil || id < 1 || id > len(s.schemas) {
+		writeError(w, http.StatusNotFound, 40403, "Schema not found")
+		return
+	}
+
+	schema := s.schemas[id-1]
+	if schema.SchemaType == registry.TypeAvro {
+		schema.SchemaType = ""
+	}
+	schema.ID = 0
+	writeJSON(w, http.StatusOK, schema)
+}
+
+func (s *Server) register(w http.ResponseWriter, r *http.Request) {
+	var schema registry.Schema
+	if err := json.NewDecoder(r.Body).Decode(&schema); err != nil || schema.Schema == "" {
+		writeError(w, http.StatusUnprocessableEntity, 42201, "Invalid schema")
+		return
+	}
+
+	id := s.Add(r.PathValue("subject"), schema)
+	writeJSON(w, http.StatusOK, map[string]int{"id": id})
+}
+
+func (s *Server) listSubjects(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	subjects := make([]string, 0, len(s.subjects))
+	for subject := range s.subjects {
+		subjects = append(subjects, subject)
+	}
+	s.mtx.Unlock()
+
+	writeJSON(w, http.StatusOK, subjects)
+}
+
+func writeError(w http.ResponseWriter, status int, code int, message string) {
+	writeJSON(w, status, map[string]any{"error_code": code, "message": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
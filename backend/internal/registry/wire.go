@@ -0,0 +1,70 @@
+package registry
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// MagicByte prefixes payloads in the Confluent wire format:
+// magic byte, 4-byte big-endian schema id, then the encoded message.
+const MagicByte = 0x0
+
+var ErrInvalidWireFormat = errors.New("invalid schema registry wire format")
+
+func IsWireFormat(payload []byte) bool {
+	return len(payload) > 5 && payload[0] == MagicByte
+}
+
+func ParseWireFormat(payload []byte) (int, []byte, error) {
+	if !IsWireFormat(payload) {
+		return 0, nil, ErrInvalidWireFormat
+	}
+	return int(binary.BigEndian.Uint32(payload[1:5])), payload[5:], nil
+}
+
+func AppendWireFormat(dst []byte, schemaID int, payload []byte) []byte {
+	dst = append(dst, MagicByte)
+	dst = binary.BigEndian.AppendUint32(dst, uint32(schemaID))
+	return append(dst, payload...)
+}
+
+// ParseMessageIndexes reads the protobuf message index path that follows the schema id:
+// a zigzag varint count followed by that many zigzag varint indexes. A single zero byte is
+// the shorthand for the first message in the schema.
+func ParseMessageIndexes(payload []byte) ([]int, []byte, error) {
+	count, n := binary.Varint(payload)
+	if n <= 0 || count < 0 {
+		return nil, nil, ErrInvalidWireFormat
+	}
+	payload = payload[n:]
+	if count == 0 {
+		return []int{0}, payload, nil
+	}
+	// Every index takes at least one byte, so a larger count can only come from a corrupt
+	// message and must not size the allocation below.
+	if count > int64(len(payload)) {
+		return nil, nil, ErrInvalidWireFormat
+	}
+
+	indexes := make([]int, 0, count)
+	for range count {
+		index, n := binary.Varint(payload)
+		if n <= 0 || index < 0 {
+			return nil, nil, ErrInvalidWireFormat
+		}
+		indexes = append(indexes, int(index))
+		payload = payload[n:]
+	}
+	return indexes, payload, nil
+}
+
+func AppendMessageIndexes(dst []byte, indexes []int) []byte {
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return append(dst, 0)
+	}
+	dst = binary.AppendVarint(dst, int64(len(indexes)))
+	for _, index := range indexes {
+		dst = binary.AppendVarint(dst, int64(index))
+	}
+	return dst
+}
@@ -0,0 +1,48 @@
+package registry
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParseWireFormat(t *testing.T) {
+	schemaID, payload, err := ParseWireFormat(AppendWireFormat(nil, 42, []byte("body")))
+	if err != nil || schemaID != 42 || string(payload) != "body" {
+		t.Fatalf("ParseWireFormat = %d, %q, %v, want 42, body", schemaID, payload, err)
+	}
+
+	for _, payload := range [][]byte{nil, {MagicByte}, {MagicByte, 0, 0, 0, 1}, {1, 0, 0, 0, 1, 2}} {
+		if _, _, err := ParseWireFormat(payload); !errors.Is(err, ErrInvalidWireFormat) {
+			t.Errorf("ParseWireFormat(%v) error = %v, want ErrInvalidWireFormat", payload, err)
+		}
+	}
+}
+
+func TestParseMessageIndexes(t *testing.T) {
+	for _, indexes := range [][]int{{0}, {3}, {1, 2, 0}} {
+		encoded := AppendMessageIndexes(nil, indexes)
+		parsed, rest, err := ParseMessageIndexes(append(encoded, "body"...))
+		if err != nil || !slices.Equal(parsed, indexes) || string(rest) != "body" {
+			t.Errorf("ParseMessageIndexes(%v) = %v, %q, %v", indexes, parsed, rest, err)
+		}
+	}
+
+	invalid := map[string][]byte{
+		"empty":             nil,
+		"truncated varint":  {0x80},
+		"negative count":    binary.AppendVarint(nil, -1),
+		"missing indexes":   binary.AppendVarint(nil, 2),
+		"negative index":    binary.AppendVarint(binary.AppendVarint(nil, 1), -3),
+		"oversized count":   binary.AppendVarint(nil, 1<<40),
+		"count beyond body": append(binary.AppendVarint(nil, 1000), 2, 2, 2),
+	}
+	for name, payload := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := ParseMessageIndexes(payload); !errors.Is(err, ErrInvalidWireFormat) {
+				t.Errorf("error = %v, want ErrInvalidWireFormat", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package schema
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+const (
+	VersionField  = "schema_version"
+	VersionHeader = "schema_version"
+	LatestVersion = 2
+)
+
+var ErrUnsupportedVersion = errors.New("unsupported schema version")
+
+//go:embed order/*.json
+var orderSchemas embed.FS
+
+// Upcaster rewrites a document of one schema version into the next one.
+type Upcaster func(doc map[string]any) (map[string]any, error)
+
+// upcasters are keyed by the version they upgrade from.
+var upcasters = map[int]Upcaster{
+	1: upcastV1,
+}
+
+// upcastV1 rewrites a v1 document into v2. v1 producers sent phones, currencies and locales
+// in free form; v2 requires them canonical. Values that cannot be canonicalised are left as
+// they are, so the v2 schema rejects them.
+func upcastV1(doc map[string]any) (map[string]any, error) {
+	if delivery, ok := doc["delivery"].(map[string]any); ok {
+		if phone, ok := delivery["phone"].(string); ok {
+			delivery["phone"] = canonicalPhone(phone)
+		}
+	}
+	if payment, ok := doc["payment"].(map[string]any); ok {
+		if currency, ok := payment["currency"].(string); ok {
+			payment["currency"] = strings.ToUpper(strings.TrimSpace(currency))
+		}
+	}
+	if locale, ok := doc["locale"].(string); ok {
+		language, _, _ := strings.Cut(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"), "-")
+		doc["locale"] = strings.ToLower(language)
+	}
+
+	doc[VersionField] = json.Number("2")
+	return doc, nil
+}
+
+// canonicalPhone drops the separators people write phone numbers with, keeping a leading +.
+func canonicalPhone(phone string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(phone) {
+		switch {
+		case r >= '0' && r <= '9', r == '+' && i == 0:
+			b.WriteRune(r)
+		case strings.ContainsRune(" -.()", r):
+		default:
+			return phone
+		}
+	}
+	return b.String()
+}
+
+type ValidationError struct {
+	Version int
+	Err     *jsonschema.ValidationError
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("order does not match schema v%d: %v", e.Version, e.Err)
+}
+
+// Causes flattens the schema errors into one line per failed keyword.
+func (e *ValidationError) Causes() []string {
+	var causes []string
+	var walk func(err *jsonschema.ValidationError)
+	walk = func(err *jsonschema.ValidationError) {
+		if len(err.Causes) == 0 {
+			causes = append(causes, err.Error())
+			return
+		}
+		for _, cause := range err.Causes {
+			walk(cause)
+		}
+	}
+	walk(e.Err)
+	return causes
+}
+
+type OrderValidator struct {
+	schemas map[int]*jsonschema.Schema
+}
+
+func NewOrderValidator() (*OrderValidator, error) {
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat()
+
+	entries, err := orderSchemas.ReadDir("order")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		data, err := orderSchemas.ReadFile("order/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", entry.Name(), err)
+		}
+		if err := compiler.AddResource(schemaURL(entry.Name()), doc); err != nil {
+			return nil, err
+		}
+	}
+
+	schemas := make(map[int]*jsonschema.Schema, LatestVersion)
+	for version := 1; version <= LatestVersion; version++ {
+		schema, err := compiler.Compile(schemaURL(fmt.Sprintf("v%d.json", version)))
+		if err != nil {
+			return nil, fmt.Errorf("compile order schema v%d: %w", version, err)
+		}
+		schemas[version] = schema
+	}
+	return &OrderValidator{schemas}, nil
+}
+
+// Validate checks the payload against the schema of its declared version and upcasts it to
+// LatestVersion, checking the result against the latest schema as well. headerVersion takes
+// precedence over the schema_version field; documents without either are treated as version 1.
+func (v *OrderValidator) Validate(payload []byte, headerVersion string) ([]byte, int, error) {
+	raw, err := jsonschema.UnmarshalJSON(bytes.NewReader(payload))
+	if err != nil {
+		return nil, 0, fmt.Errorf("parse order: %w", err)
+	}
+	doc, ok := raw.(map[string]any)
+	if !ok {
+		return nil, 0, errors.New("order must be a JSON object")
+	}
+
+	version, err := documentVersion(doc, headerVersion)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, ok := v.schemas[version]; !ok {
+		return nil, version, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+	if err := v.validate(doc, version); err != nil {
+		return nil, version, err
+	}
+
+	if version < LatestVersion {
+		for from := version; from < LatestVersion; from++ {
+			if doc, err = upcasters[from](doc); err != nil {
+				return nil, version, fmt.Errorf("upcast order from v%d: %w", from, err)
+			}
+		}
+		if err := v.validate(doc, LatestVersion); err != nil {
+			return nil, version, fmt.Errorf("upcast order from v%d: %w", version, err)
+		}
+	}
+
+	upcasted, err := json.Marshal(doc)
+	if err != nil {
+		return nil, version, err
+	}
+	return upcasted, version, nil
+}
+
+func (v *OrderValidator) validate(doc map[string]any, version int) error {
+	err := v.schemas[version].Validate(doc)
+	var validationErr *jsonschema.ValidationError
+	if errors.As(err, &validationErr) {
+		return &ValidationError{version, validationErr}
+	}
+	return err
+}
+
+func documentVersion(doc map[string]any, headerVersion string) (int, error) {
+	if headerVersion != "" {
+		version, err := strconv.Atoi(headerVersion)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrUnsupportedVersion, headerVersion)
+		}
+		return version, nil
+	}
+
+	field, ok := doc[VersionField]
+	if !ok {
+		return 1, nil
+	}
+	number, ok := field.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("%w: %v", ErrUnsupportedVersion, field)
+	}
+	version, err := strconv.Atoi(number.String())
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUnsupportedVersion, field)
+	}
+	return version, nil
+}
+
+func schemaURL(name string) string {
+	return "https://wbts.local/schemas/order/" + name
+}
@@ -0,0 +1,258 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func testDocument(edit func(doc map[string]any)) []byte {
+	doc := map[string]any{
+		"order_uid":    "b563feb7b2b84b6test",
+		"track_number": "WBILMTESTTRACK",
+		"entry":        "WBIL",
+		"delivery": map[string]any{
+			"name": "Test Testov", "phone": "+9720000000", "zip": "2639809", "city": "Kiryat Mozkin",
+			"address": "Ploshad Mira 15", "region": "Kraiot", "email": "test@gmail.com",
+		},
+		"payment": map[string]any{
+			"transaction": "b563feb7b2b84b6test", "request_id": "", "currency": "USD", "provider": "wbpay",
+			"amount": 1817, "payment_dt": 1637907727, "bank": "alpha", "delivery_cost": 1500,
+			"goods_total": 317, "custom_fee": 0,
+		},
+		"items": []any{map[string]any{
+			"chrt_id": 9934930, "track_number": "WBILMTESTTRACK", "price": 453, "rid": "ab4219087a764ae0btest",
+			"name": "Mascaras", "sale": 30, "size": "0", "total_price": 317, "nm_id": 2389212,
+			"brand": "Vivienne Sabo", "status": 202,
+		}},
+		"locale":             "en",
+		"internal_signature": "sig",
+		"customer_id":        "test",
+		"delivery_service":   "meest",
+		"shardkey":           "9",
+		"sm_id":              99,
+		"date_created":       "2021-11-26T06:22:19Z",
+		"oof_shard":          "1",
+	}
+	if edit != nil {
+		edit(doc)
+	}
+	payload, _ := json.Marshal(doc)
+	return payload
+}
+
+func nested(doc map[string]any, key string) map[string]any {
+	return doc[key].(map[string]any)
+}
+
+func TestDocumentVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		field   any
+		header  string
+		want    int
+		wantErr bool
+	}{
+		{name: "neither", want: 1},
+		{name: "field", field: 2, want: 2},
+		{name: "header wins over field", field: 1, header: "2", want: 2},
+		{name: "header without field", header: "1", want: 1},
+		{name: "future version", field: 3, want: 3},
+		{name: "invalid header", header: "v2", wantErr: true},
+		{name: "string field", field: "2", wantErr: true},
+		{name: "fractional field", field: 1.5, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := map[string]any{}
+			if tt.field != nil {
+				raw, _ := json.Marshal(map[string]any{VersionField: tt.field})
+				decoder := json.NewDecoder(bytes.NewReader(raw))
+				decoder.UseNumber()
+				decoder.Decode(&doc)
+			}
+			version, err := documentVersion(doc, tt.header)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnsupportedVersion) {
+					t.Errorf("documentVersion = %d, %v, want ErrUnsupportedVersion", version, err)
+				}
+				return
+			}
+			if err != nil || version != tt.want {
+				t.Errorf("documentVersion = %d, %v, want %d", version, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	validator, err := NewOrderValidator()
+	if err != nil {
+		t.Fatalf("NewOrderValidator: %v", err)
+	}
+
+	v2 := func(edit func(doc map[string]any)) []byte {
+		return testDocument(func(doc map[string]any) {
+			doc[VersionField] = 2
+			if edit != nil {
+				edit(doc)
+			}
+		})
+	}
+
+	tests := []struct {
+		name    string
+		payload []byte
+		header  string
+		// wantVersion is the detected version, failedAt the schema version the document
+		// failed, 0 if it is valid.
+		wantVersion int
+		failedAt    int
+		want        func(t *testing.T, doc map[string]any)
+	}{
+		{name: "v1", payload: testDocument(nil), wantVersion: 1},
+		{name: "v2", payload: v2(nil), wantVersion: 2},
+		{name: "v2 announced in the header", payload: v2(nil), header: "2", wantVersion: 2},
+		{
+			name: "v1 with free-form values is upcast",
+			payload: testDocument(func(doc map[string]any) {
+				nested(doc, "delivery")["phone"] = " +972 (0) 000-00.00 "
+				nested(doc, "payment")["currency"] = "usd "
+				doc["locale"] = "ru_RU"
+			}),
+			wantVersion: 1,
+			want: func(t *testing.T, doc map[string]any) {
+				if phone := nested(doc, "delivery")["phone"]; phone != "+97200000000" {
+					t.Errorf("phone = %v", phone)
+				}
+				if currency := nested(doc, "payment")["currency"]; currency != "USD" {
+					t.Errorf("currency = %v", currency)
+				}
+				if doc["locale"] != "ru" {
+					t.Errorf("locale = %v", doc["locale"])
+				}
+			},
+		},
+		{
+			name:        "v1 missing a required field",
+			payload:     testDocument(func(doc map[string]any) { delete(doc, "order_uid") }),
+			wantVersion: 1, failedAt: 1,
+		},
+		{
+			name:        "v1 item with zero total",
+			payload:     testDocument(func(doc map[string]any) { doc["items"].([]any)[0].(map[string]any)["total_price"] = 0 }),
+			wantVersion: 1, failedAt: 1,
+		},
+		{
+			name:        "v1 phone that cannot be canonicalised",
+			payload:     testDocument(func(doc map[string]any) { nested(doc, "delivery")["phone"] = "call me" }),
+			wantVersion: 1, failedAt: 2,
+		},
+		{
+			name:        "v1 currency that is no code",
+			payload:     testDocument(func(doc map[string]any) { nested(doc, "payment")["currency"] = "dollar" }),
+			wantVersion: 1, failedAt: 2,
+		},
+		{
+			name:        "v2 with a free-form phone",
+			payload:     v2(func(doc map[string]any) { nested(doc, "delivery")["phone"] = "+972 000 0000" }),
+			wantVersion: 2, failedAt: 2,
+		},
+		{
+			name:        "v2 with a lowercase currency",
+			payload:     v2(func(doc map[string]any) { nested(doc, "payment")["currency"] = "usd" }),
+			wantVersion: 2, failedAt: 2,
+		},
+		{
+			name:        "v2 announced in the header only",
+			payload:     testDocument(nil),
+			header:      "2",
+			wantVersion: 2, failedAt: 2,
+		},
+		{
+			name:        "v2 missing a v1 field",
+			payload:     v2(func(doc map[string]any) { delete(doc, "delivery") }),
+			wantVersion: 2, failedAt: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upcasted, version, err := validator.Validate(tt.payload, tt.header)
+			if version != tt.wantVersion {
+				t.Errorf("version = %d, want %d", version, tt.wantVersion)
+			}
+			if tt.failedAt != 0 {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) || validationErr.Version != tt.failedAt {
+					t.Fatalf("error = %v, want a v%d validation error", err, tt.failedAt)
+				}
+				if len(validationErr.Causes()) == 0 {
+					t.Error("validation error has no causes")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+
+			var doc map[string]any
+			if err := json.Unmarshal(upcasted, &doc); err != nil {
+				t.Fatalf("upcasted document: %v", err)
+			}
+			if doc[VersionField] != float64(LatestVersion) {
+				t.Errorf("%s = %v, want %d", VersionField, doc[VersionField], LatestVersion)
+			}
+			if tt.want != nil {
+				tt.want(t, doc)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsUnsupportedDocuments(t *testing.T) {
+	validator, err := NewOrderValidator()
+	if err != nil {
+		t.Fatalf("NewOrderValidator: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		payload []byte
+		header  string
+		wantErr error
+	}{
+		{"unknown field version", testDocument(func(doc map[string]any) { doc[VersionField] = 3 }), "", ErrUnsupportedVersion},
+		{"unknown header version", testDocument(nil), "0", ErrUnsupportedVersion},
+		{"malformed JSON", []byte(`{"order_uid":`), "", nil},
+		{"not an object", []byte(`["order"]`), "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := validator.Validate(tt.payload, tt.header)
+			if err == nil {
+				t.Fatal("Validate succeeded")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCanonicalPhone(t *testing.T) {
+	tests := map[string]string{
+		"+9720000000":          "+9720000000",
+		" +7 (999) 123-45-67 ": "+79991234567",
+		"8.999.123.45.67":      "89991234567",
+		"+7 999 CALL-ME":       "+7 999 CALL-ME",
+		"7+9991234567":         "7+9991234567",
+	}
+	for phone, want := range tests {
+		if got := canonicalPhone(phone); got != want {
+			t.Errorf("canonicalPhone(%q) = %q, want %q", phone, got, want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"wbts/internal/domain/dto"
+	"wbts/internal/domain/entity"
+	"wbts/internal/pkg"
+)
+
+const anonymizedValue = "ANONYMIZED"
+
+// maxAnonymizeRetries bounds how often the customer's orders are re-read and anonymized again
+// after one of them was changed by a concurrent write.
+const maxAnonymizeRetries = 3
+
+func (s *OrderService) ExportCustomer(customerID string, actor string) (dto.CustomerExportDTO, error) {
+	ctx := context.Background()
+	orderInfos, err := s.getCustomerOrders(ctx, customerID)
+	if err != nil {
+		return dto.CustomerExportDTO{}, err
+	}
+
+	export := dto.CustomerExportDTO{
+		CustomerID: customerID,
+		ExportedAt: time.Now().UTC(),
+		Orders:     make([]dto.OrderDTO, 0, len(orderInfos)),
+	}
+	for _, orderInfo := range orderInfos {
+		order, err := s.orderConverter.OrderInfoToOrderDTO(orderInfo)
+		if err != nil {
+			return dto.CustomerExportDTO{}, err
+		}
+		export.Orders = append(export.Orders, order)
+	}
+
+	details, err := json.Marshal(map[string]int{"orders": len(export.Orders)})
+	if err != nil {
+		return dto.CustomerExportDTO{}, err
+	}
+	err = s.orderRepo.RecordAudit(ctx, entity.AuditRecord{
+		Actor:   actor,
+		Action:  entity.AuditCustomerExport,
+		Subject: customerID,
+		Details: details,
+	})
+	if err != nil {
+		return dto.CustomerExportDTO{}, err
+	}
+
+	return export, nil
+}
+
+// AnonymizeCustomer erases the delivery data of all orders of the customer. The orders are read
+// from the database rather than the cache and written only if none of them changed since, so
+// a concurrent upsert is never overwritten with anonymized data derived from an older version.
+func (s *OrderService) AnonymizeCustomer(customerID string, actor string) (dto.CustomerAnonymizationDTO, error) {
+	ctx := context.Background()
+	for attempt := 0; ; attempt++ {
+		result, err := s.anonymizeCustomer(ctx, customerID, actor)
+		if !errors.Is(err, entity.ErrOrderChanged) || attempt == maxAnonymizeRetries {
+			return result, err
+		}
+	}
+}
+
+func (s *OrderService) anonymizeCustomer(ctx context.Context, customerID string, actor string) (dto.CustomerAnonymizationDTO, error) {
+	orderInfos, err := s.getCustomerOrders(ctx, customerID)
+	if err != nil {
+		return dto.CustomerAnonymizationDTO{}, err
+	}
+
+	result := dto.CustomerAnonymizationDTO{CustomerID: customerID, AnonymizedOrders: make([]string, 0, len(orderInfos))}
+	orders := make([]entity.Order, 0, len(orderInfos))
+	for _, orderInfo := range orderInfos {
+		order, err := s.orderConverter.OrderInfoToOrderDTO(orderInfo)
+		if err != nil {
+			return dto.CustomerAnonymizationDTO{}, err
+		}
+
+		order.Delivery = dto.DeliveryDTO{
+			Name:   anonymizedValue,
+			City:   order.Delivery.City,
+			Region: order.Delivery.Region,
+		}
+		anonymized, err := s.orderConverter.OrderDTOToOrderInfo(order)
+		if err != nil {
+			return dto.CustomerAnonymizationDTO{}, err
+		}
+
+		anonymized.Order.Anonymized = true
+		anonymized.Order.UpdatedAt = orderInfo.Order.UpdatedAt
+		orders = append(orders, anonymized.Order)
+		result.AnonymizedOrders = append(result.AnonymizedOrders, order.OrderUID)
+	}
+
+	details, err := json.Marshal(map[string][]string{"orders": result.AnonymizedOrders})
+	if err != nil {
+		return dto.CustomerAnonymizationDTO{}, err
+	}
+	err = s.orderRepo.ReplaceProtectedFields(ctx, orders, entity.AuditRecord{
+		Actor:   actor,
+		Action:  entity.AuditCustomerAnonymize,
+		Subject: customerID,
+		Details: details,
+	})
+	if err != nil {
+		return dto.CustomerAnonymizationDTO{}, err
+	}
+
+	return result, nil
+}
+
+// getCustomerOrders reads the customer's orders from the database, bypassing the cache, which
+// may still hold a version an invalidation has not reached yet.
+func (s *OrderService) getCustomerOrders(ctx context.Context, customerID string) ([]entity.OrderInfo, error) {
+	customerIndex := s.orderConverter.BlindIndex(pkg.BlindIndexCustomer, customerID)
+	uids, err := s.orderRepo.FindCustomerOrderUIDs(ctx, customerID, customerIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	orderInfos := make([]entity.OrderInfo, 0, len(uids))
+	for _, uid := range uids {
+		orderInfo, err := s.orderRepo.LoadByUID(ctx, uid)
+		if err != nil {
+			return nil, err
+		}
+		orderInfos = append(orderInfos, *orderInfo)
+	}
+	return orderInfos, nil
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"wbts/internal/domain/dto"
+	"wbts/internal/domain/entity"
+	"wbts/internal/keyring"
+	"wbts/internal/pkg"
+)
+
+// fakeCustomerRepo keeps the stored orders and a cache that may lag behind them. Protected
+// fields are compare-and-swapped on UpdatedAt like the database does.
+type fakeCustomerRepo struct {
+	OrderRepo
+	orders  map[string]entity.OrderInfo
+	cached  map[string]entity.OrderInfo
+	audit   []entity.AuditRecord
+	replace func(orders []entity.Order)
+	clock   time.Time
+}
+
+func (r *fakeCustomerRepo) GetByUID(ctx context.Context, order_uid string) (*entity.OrderInfo, error) {
+	if info, ok := r.cached[order_uid]; ok {
+		return &info, nil
+	}
+	return r.LoadByUID(ctx, order_uid)
+}
+
+func (r *fakeCustomerRepo) LoadByUID(ctx context.Context, order_uid string) (*entity.OrderInfo, error) {
+	info, ok := r.orders[order_uid]
+	if !ok {
+		return nil, entity.ErrOrderNotFound
+	}
+	return &info, nil
+}
+
+func (r *fakeCustomerRepo) FindCustomerOrderUIDs(ctx context.Context, customerID string, customerIndex string) ([]string, error) {
+	var uids []string
+	for uid, info := range r.orders {
+		if info.Order.CustomerIndex == customerIndex {
+			uids = append(uids, uid)
+		}
+	}
+	slices.Sort(uids)
+	return uids, nil
+}
+
+func (r *fakeCustomerRepo) ReplaceProtectedFields(ctx context.Context, orders []entity.Order, audit entity.AuditRecord) error {
+	if r.replace != nil {
+		r.replace(orders)
+	}
+	for _, order := range orders {
+		if !r.orders[order.OrderUID].Order.UpdatedAt.Equal(order.UpdatedAt) {
+			return entity.ErrOrderChanged
+		}
+	}
+	for _, order := range orders {
+		r.write(order)
+	}
+	r.audit = append(r.audit, audit)
+	return nil
+}
+
+func (r *fakeCustomerRepo) RecordAudit(ctx context.Context, record entity.AuditRecord) error {
+	r.audit = append(r.audit, record)
+	return nil
+}
+
+// write stores a new version of the order, as a concurrent upsert would.
+func (r *fakeCustomerRepo) write(order entity.Order) {
+	r.clock = r.clock.Add(time.Second)
+	info := r.orders[order.OrderUID]
+	info.Order = order
+	info.Order.UpdatedAt = r.clock
+	r.orders[order.OrderUID] = info
+}
+
+func testKeyring(t *testing.T) *keyring.Keyring {
+	t.Helper()
+	key := func(b byte) string { return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32))) }
+	data, err := json.Marshal(map[string]any{
+		"active_key_id":   "k1",
+		"blind_index_key": key('b'),
+		"keys":            []map[string]string{{"id": "k1", "key": key('k')}},
+	})
+	if err != nil {
+		t.Fatalf("marshal keyring: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write keyring: %v", err)
+	}
+	kr, err := keyring.LoadFile(path)
+	if err != nil {
+		t.Fatalf("load keyring: %v", err)
+	}
+	return kr
+}
+
+func customerOrder(uid string, customerID string) dto.OrderDTO {
+	return dto.OrderDTO{
+		OrderUID: uid,
+		Entry:    "WBIL",
+		Delivery: dto.DeliveryDTO{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment:     dto.PaymentDTO{Transaction: uid, Currency: "USD", Amount: 1817},
+		Items:       []dto.ItemDTO{},
+		CustomerID:  customerID,
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		Tenant:      dto.DefaultTenant,
+	}
+}
+
+func newCustomerService(t *testing.T, orders ...dto.OrderDTO) (*OrderService, *fakeCustomerRepo, *pkg.OrderConverter) {
+	t.Helper()
+	converter := &pkg.OrderConverter{Keyring: testKeyring(t), EncryptCustomerID: true}
+	repo := &fakeCustomerRepo{
+		orders: make(map[string]entity.OrderInfo),
+		cached: make(map[string]entity.OrderInfo),
+		clock:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for _, order := range orders {
+		info, err := converter.OrderDTOToOrderInfo(order)
+		if err != nil {
+			t.Fatalf("convert %s: %v", order.OrderUID, err)
+		}
+		repo.orders[order.OrderUID] = info
+		repo.write(info.Order)
+	}
+	return NewOrderService(repo, converter, false), repo, converter
+}
+
+func TestExportCustomer(t *testing.T) {
+	service, repo, _ := newCustomerService(t,
+		customerOrder("a", "customer-1"),
+		customerOrder("b", "customer-1"),
+		customerOrder("c", "customer-2"),
+	)
+
+	export, err := service.ExportCustomer("customer-1", "admin")
+	if err != nil {
+		t.Fatalf("ExportCustomer: %v", err)
+	}
+	if len(export.Orders) != 2 || export.Orders[0].OrderUID != "a" || export.Orders[1].OrderUID != "b" {
+		t.Fatalf("exported orders = %+v", export.Orders)
+	}
+	if order := export.Orders[0]; order.Delivery.Phone != "+9720000000" || order.CustomerID != "customer-1" {
+		t.Errorf("export does not carry the decrypted data: %+v", order)
+	}
+
+	if len(repo.audit) != 1 {
+		t.Fatalf("audit records = %+v", repo.audit)
+	}
+	if record := repo.audit[0]; record.Action != entity.AuditCustomerExport || record.Subject != "customer-1" ||
+		record.Actor != "admin" || string(record.Details) != `{"orders":2}` {
+		t.Errorf("audit record = %+v, details %s", record, record.Details)
+	}
+}
+
+func TestAnonymizeCustomer(t *testing.T) {
+	service, repo, converter := newCustomerService(t, customerOrder("a", "customer-1"), customerOrder("b", "customer-2"))
+
+	// The cache still holds a version older than the stored one.
+	repo.cached["a"] = repo.orders["a"]
+	repo.write(repo.orders["a"].Order)
+
+	result, err := service.AnonymizeCustomer("customer-1", "admin")
+	if err != nil {
+		t.Fatalf("AnonymizeCustomer: %v", err)
+	}
+	if len(result.AnonymizedOrders) != 1 || result.AnonymizedOrders[0] != "a" {
+		t.Fatalf("anonymized orders = %v", result.AnonymizedOrders)
+	}
+
+	stored := repo.orders["a"].Order
+	if !stored.Anonymized || stored.PhoneIndex != "" || stored.EmailIndex != "" {
+		t.Errorf("stored order is not anonymized: anonymized=%t phone=%q email=%q", stored.Anonymized, stored.PhoneIndex, stored.EmailIndex)
+	}
+	if stored.CustomerIndex != converter.BlindIndex(pkg.BlindIndexCustomer, "customer-1") {
+		t.Error("customer index was dropped, the order can no longer be found for the customer")
+	}
+	order, err := converter.OrderInfoToOrderDTO(repo.orders["a"])
+	if err != nil {
+		t.Fatalf("decrypt anonymized order: %v", err)
+	}
+	want := dto.DeliveryDTO{Name: anonymizedValue, City: "Kiryat Mozkin", Region: "Kraiot"}
+	if order.Delivery != want {
+		t.Errorf("delivery = %+v, want %+v", order.Delivery, want)
+	}
+	if repo.orders["b"].Order.Anonymized {
+		t.Error("order of another customer was anonymized")
+	}
+
+	if len(repo.audit) != 1 || repo.audit[0].Action != entity.AuditCustomerAnonymize || string(repo.audit[0].Details) != `{"orders":["a"]}` {
+		t.Errorf("audit records = %+v", repo.audit)
+	}
+}
+
+func TestAnonymizeCustomerRetriesOnConcurrentWrite(t *testing.T) {
+	service, repo, converter := newCustomerService(t, customerOrder("a", "customer-1"))
+
+	// An upsert moves the order to another city between reading and writing it.
+	moved := customerOrder("a", "customer-1")
+	moved.Delivery.City = "Haifa"
+	writes := 0
+	repo.replace = func([]entity.Order) {
+		if writes++; writes == 1 {
+			info, _ := converter.OrderDTOToOrderInfo(moved)
+			repo.write(info.Order)
+		}
+	}
+
+	if _, err := service.AnonymizeCustomer("customer-1", "admin"); err != nil {
+		t.Fatalf("AnonymizeCustomer: %v", err)
+	}
+	order, err := converter.OrderInfoToOrderDTO(repo.orders["a"])
+	if err != nil {
+		t.Fatalf("decrypt anonymized order: %v", err)
+	}
+	if !repo.orders["a"].Order.Anonymized || order.Delivery.Name != anonymizedValue || order.Delivery.City != "Haifa" {
+		t.Errorf("anonymized order = %+v, want the concurrent write anonymized", order.Delivery)
+	}
+	if writes != 2 || len(repo.audit) != 1 {
+		t.Errorf("writes = %d, audit records = %d, want a single retry and one audit record", writes, len(repo.audit))
+	}
+}
+
+func TestAnonymizeCustomerGivesUpOnPersistentConflicts(t *testing.T) {
+	service, repo, _ := newCustomerService(t, customerOrder("a", "customer-1"))
+
+	writes := 0
+	repo.replace = func([]entity.Order) {
+		writes++
+		repo.write(repo.orders["a"].Order)
+	}
+
+	_, err := service.AnonymizeCustomer("customer-1", "admin")
+	if !errors.Is(err, entity.ErrOrderChanged) {
+		t.Fatalf("error = %v, want ErrOrderChanged", err)
+	}
+	if writes != maxAnonymizeRetries+1 || repo.orders["a"].Order.Anonymized || len(repo.audit) != 0 {
+		t.Errorf("writes = %d, anonymized = %t, audit = %d", writes, repo.orders["a"].Order.Anonymized, len(repo.audit))
+	}
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"wbts/internal/domain/dto"
+	"wbts/internal/pkg"
+)
+
+// encodeOrder marshals and compresses an order. The ETag is computed before compression, so
+// every content coding of a representation shares it; compressed responses carry it as a weak
+// validator, like the ones compressed by the REST middleware.
+func encodeOrder(order any, lastModified time.Time, format string, encoding string) (dto.EncodedOrderDTO, error) {
+	body, err := pkg.Marshal(format, order)
+	if err != nil {
+		return dto.EncodedOrderDTO{}, err
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if encoding != dto.EncodingIdentity {
+		etag = "W/" + etag
+	}
+
+	body, err = pkg.Compress(encoding, body)
+	if err != nil {
+		return dto.EncodedOrderDTO{}, err
+	}
+
+	return dto.EncodedOrderDTO{
+		Body:         body,
+		ETag:         etag,
+		LastModified: lastModified,
+	}, nil
+}
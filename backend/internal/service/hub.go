@@ -0,0 +1,79 @@
+package service
+
+import (
+	"log"
+	"sync"
+
+	"wbts/internal/domain/dto"
+)
+
+const subscriptionBuffer = 64
+
+type subscription struct {
+	ch     chan dto.OrderDTO
+	filter func(dto.OrderDTO) bool
+}
+
+// OrderHub fans saved orders out to subscribers. Channels are only closed while the write lock
+// is held and Publish sends under the read lock, so a send never races with a close.
+type OrderHub struct {
+	subs   map[*subscription]struct{}
+	closed bool
+	mtx    sync.RWMutex
+}
+
+func NewOrderHub() *OrderHub {
+	return &OrderHub{subs: make(map[*subscription]struct{})}
+}
+
+// Subscribe returns a channel with the orders that pass filter and a function that ends the
+// subscription. The channel is closed when the subscription ends or the hub is closed.
+func (h *OrderHub) Subscribe(filter func(dto.OrderDTO) bool) (<-chan dto.OrderDTO, func()) {
+	sub := &subscription{make(chan dto.OrderDTO, subscriptionBuffer), filter}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if h.closed {
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+	h.subs[sub] = struct{}{}
+
+	return sub.ch, func() {
+		h.mtx.Lock()
+		defer h.mtx.Unlock()
+		if _, ok := h.subs[sub]; ok {
+			delete(h.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Close ends all subscriptions, so streams can finish before the server shuts down. Later
+// subscriptions are closed immediately.
+func (h *OrderHub) Close() {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	for sub := range h.subs {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+	h.closed = true
+}
+
+func (h *OrderHub) Publish(order dto.OrderDTO) {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+	for sub := range h.subs {
+		if sub.filter != nil && !sub.filter(order) {
+			continue
+		}
+		select {
+		case sub.ch <- order:
+		default:
+			log.Printf("Subscriber is too slow, dropping update for order with uid=%s", order.OrderUID)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"wbts/internal/domain/dto"
+)
+
+func receive(t *testing.T, ch <-chan dto.OrderDTO) (dto.OrderDTO, bool) {
+	t.Helper()
+	select {
+	case order, ok := <-ch:
+		return order, ok
+	case <-time.After(time.Second):
+		t.Fatal("no update received")
+		return dto.OrderDTO{}, false
+	}
+}
+
+func TestHubSubscriptions(t *testing.T) {
+	hub := NewOrderHub()
+	all, unsubscribeAll := hub.Subscribe(nil)
+	acme, unsubscribeAcme := hub.Subscribe(func(order dto.OrderDTO) bool { return order.Tenant == "acme" })
+	defer unsubscribeAcme()
+
+	hub.Publish(dto.OrderDTO{OrderUID: "a", Tenant: "default"})
+	hub.Publish(dto.OrderDTO{OrderUID: "b", Tenant: "acme"})
+
+	for _, want := range []string{"a", "b"} {
+		if order, _ := receive(t, all); order.OrderUID != want {
+			t.Errorf("unfiltered subscriber got %s, want %s", order.OrderUID, want)
+		}
+	}
+	if order, _ := receive(t, acme); order.OrderUID != "b" {
+		t.Errorf("filtered subscriber got %s, want b", order.OrderUID)
+	}
+
+	unsubscribeAll()
+	unsubscribeAll()
+	if _, ok := receive(t, all); ok {
+		t.Error("channel is open after unsubscribing")
+	}
+	hub.Publish(dto.OrderDTO{OrderUID: "c", Tenant: "acme"})
+	if order, _ := receive(t, acme); order.OrderUID != "c" {
+		t.Errorf("remaining subscriber got %s, want c", order.OrderUID)
+	}
+}
+
+func TestHubDropsUpdatesForSlowSubscribers(t *testing.T) {
+	hub := NewOrderHub()
+	slow, unsubscribe := hub.Subscribe(nil)
+	defer unsubscribe()
+
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for i := range subscriptionBuffer + 10 {
+			hub.Publish(dto.OrderDTO{OrderUID: fmt.Sprint(i)})
+		}
+	}()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a subscriber that does not read")
+	}
+
+	if len(slow) != subscriptionBuffer {
+		t.Fatalf("%d updates buffered, want %d", len(slow), subscriptionBuffer)
+	}
+	for i := range subscriptionBuffer {
+		if order, _ := receive(t, slow); order.OrderUID != fmt.Sprint(i) {
+			t.Fatalf("update %d is %s, want the oldest updates kept", i, order.OrderUID)
+		}
+	}
+
+	// Once it has caught up, the subscriber gets new updates again.
+	hub.Publish(dto.OrderDTO{OrderUID: "next"})
+	if order, _ := receive(t, slow); order.OrderUID != "next" {
+		t.Errorf("got %s after catching up, want next", order.OrderUID)
+	}
+}
+
+// TestHubClose closes the hub while orders are published and subscriptions come and go; a send
+// on a closed channel would panic.
+func TestHubClose(t *testing.T) {
+	hub := NewOrderHub()
+	var publishers, readers sync.WaitGroup
+	stop := make(chan struct{})
+
+	for range 4 {
+		publishers.Add(1)
+		go func() {
+			defer publishers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-time.After(50 * time.Microsecond):
+					hub.Publish(dto.OrderDTO{OrderUID: "a"})
+				}
+			}
+		}()
+	}
+	for i := range 20 {
+		ch, unsubscribe := hub.Subscribe(nil)
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			// Reading until the channel is closed fails the test by timeout if one stays open.
+			for range ch {
+				if i%2 == 0 {
+					unsubscribe()
+				}
+			}
+		}()
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	hub.Close()
+	hub.Close()
+	readers.Wait()
+	close(stop)
+	publishers.Wait()
+
+	ch, unsubscribe := hub.Subscribe(nil)
+	unsubscribe()
+	if _, ok := <-ch; ok {
+		t.Error("subscription after Close is open")
+	}
+	hub.Publish(dto.OrderDTO{OrderUID: "b"})
+}
@@ -1,41 +1,77 @@
 package service
 
 import (
-	"log"
+	"fmt"
 	"context"
+	"encoding/json"
+	"errors"
 
 	"wbts/internal/domain/dto"
 	"wbts/internal/domain/entity"
+	"wbts/internal/pkg"
 )
 
 type OrderRepo interface {
 	GetByUID(ctx context.Context, order_uid string) (*entity.OrderInfo, error) 
-	Upsert(ctx context.Context, orderInfo entity.OrderInfo) error
+	LoadByUID(ctx context.Context, order_uid string) (*entity.OrderInfo, error)
+	GetEncoded(
+		ctx context.Context,
+		order_uid string,
+		variant string,
+		encode func(entity.OrderInfo) (dto.EncodedOrderDTO, error),
+	) (dto.EncodedOrderDTO, error)
+	Upsert(ctx context.Context, orderInfo entity.OrderInfo, eventPayload []byte, messageKey string) error
+	ListUIDs(ctx context.Context, after string, limit int) ([]string, error)
+	FindUIDs(ctx context.Context, lookup entity.OrderLookup, limit int) ([]string, error)
+	FindCustomerOrderUIDs(ctx context.Context, customerID string, customerIndex string) ([]string, error)
+	ReplaceProtectedFields(ctx context.Context, orders []entity.Order, audit entity.AuditRecord) error
+	RecordAudit(ctx context.Context, record entity.AuditRecord) error
 }
 
 type OrderConverter interface {
 	OrderDTOToOrderInfo(dto dto.OrderDTO) (entity.OrderInfo, error)
 	OrderInfoToOrderDTO(info entity.OrderInfo) (dto.OrderDTO, error)
+	OrderInfoToOrderView(info entity.OrderInfo, view string) (dto.OrderDTO, error)
+	MaskOrderDTO(order dto.OrderDTO, view string) dto.OrderDTO
+	BlindIndex(kind string, value string) string
 }
 
 type OrderService struct {
 	orderRepo OrderRepo
 	orderConverter OrderConverter
+	orderHub *OrderHub
+	outboxEnabled bool
 }
 
-func NewOrderService(orderRepo OrderRepo, orderConverter OrderConverter) *OrderService {
-	return &OrderService {orderRepo, orderConverter}
+// NewOrderService creates the service. outboxEnabled must only be set while an outbox relay
+// publishes and cleans up the events, otherwise order_outbox grows without bound.
+func NewOrderService(orderRepo OrderRepo, orderConverter OrderConverter, outboxEnabled bool) *OrderService {
+	return &OrderService {orderRepo, orderConverter, NewOrderHub(), outboxEnabled}
 }
 
-func (s *OrderService) Save(order dto.OrderDTO) {
+// Save stores the order. A non-empty messageKey makes the save idempotent: an order from a
+// message that was already processed is skipped with entity.ErrMessageProcessed.
+func (s *OrderService) Save(order dto.OrderDTO, messageKey string) error {
 	orderInfo, err := s.orderConverter.OrderDTOToOrderInfo(order)
 	if err != nil {
-		log.Printf("Error converting order DTO to Entity: %v", err)
+		return fmt.Errorf("convert order DTO to entity: %w", err)
 	}
 
-	if err := s.orderRepo.Upsert(context.Background(), orderInfo); err != nil {
-		log.Printf("Error saving to DB: %v", err)
+	// Events are stored unencrypted and leave the service, so they carry the masked view only.
+	var eventPayload []byte
+	if s.outboxEnabled {
+		eventPayload, err = json.Marshal(s.orderConverter.MaskOrderDTO(order, dto.ViewMasked))
+		if err != nil {
+			return fmt.Errorf("marshal order event: %w", err)
+		}
 	}
+
+	if err := s.orderRepo.Upsert(context.Background(), orderInfo, eventPayload, messageKey); err != nil {
+		return fmt.Errorf("save order to DB: %w", err)
+	}
+
+	s.orderHub.Publish(order)
+	return nil
 }
 
 func (s *OrderService) Get(order_uid string) (dto.OrderDTO, error) {
@@ -50,4 +86,115 @@ func (s *OrderService) Get(order_uid string) (dto.OrderDTO, error) {
 	}
 
 	return orderDTO, nil
-}
\ No newline at end of file
+}
+
+func (s *OrderService) List(after string, limit int) ([]dto.OrderDTO, error) {
+	uids, err := s.orderRepo.ListUIDs(context.Background(), after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]dto.OrderDTO, 0, len(uids))
+	for _, uid := range uids {
+		order, err := s.Get(uid)
+		if errors.Is(err, entity.ErrOrderNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, nil
+}
+
+func (s *OrderService) Search(search dto.OrderSearchDTO, view string, limit int) ([]dto.OrderDTO, error) {
+	email := pkg.NormalizeEmail(search.Email)
+	lookup := entity.OrderLookup{
+		Phone:         search.Phone,
+		PhoneIndex:    s.orderConverter.BlindIndex(pkg.BlindIndexPhone, search.Phone),
+		Email:         email,
+		EmailIndex:    s.orderConverter.BlindIndex(pkg.BlindIndexEmail, email),
+		CustomerID:    search.CustomerID,
+		CustomerIndex: s.orderConverter.BlindIndex(pkg.BlindIndexCustomer, search.CustomerID),
+		Tenant:        search.Tenant,
+	}
+
+	uids, err := s.orderRepo.FindUIDs(context.Background(), lookup, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]dto.OrderDTO, 0, len(uids))
+	for _, uid := range uids {
+		orderInfo, err := s.orderRepo.GetByUID(context.Background(), uid)
+		if errors.Is(err, entity.ErrOrderNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		order, err := s.orderConverter.OrderInfoToOrderView(*orderInfo, view)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, nil
+}
+
+func (s *OrderService) Subscribe(filter func(dto.OrderDTO) bool) (<-chan dto.OrderDTO, func()) {
+	return s.orderHub.Subscribe(filter)
+}
+
+// CloseSubscriptions ends all order streams.
+func (s *OrderService) CloseSubscriptions() {
+	s.orderHub.Close()
+}
+
+func (s *OrderService) Mask(order dto.OrderDTO, view string) dto.OrderDTO {
+	return s.orderConverter.MaskOrderDTO(order, view)
+}
+
+func (s *OrderService) GetEncoded(order_uid string, view string, format string, encoding string) (dto.EncodedOrderDTO, error) {
+	return s.orderRepo.GetEncoded(
+		context.Background(),
+		order_uid,
+		view+":"+format+"+"+encoding,
+		func(info entity.OrderInfo) (dto.EncodedOrderDTO, error) {
+			orderDTO, err := s.orderConverter.OrderInfoToOrderView(info, view)
+			if err != nil {
+				return dto.EncodedOrderDTO{}, err
+			}
+			return encodeOrder(orderDTO, info.Order.UpdatedAt, format, encoding)
+		},
+	)
+}
+
+func (s *OrderService) GetEncodedProjection(
+	order_uid string,
+	view string,
+	fields []string,
+	format string,
+	encoding string,
+) (dto.EncodedOrderDTO, error) {
+	orderInfo, err := s.orderRepo.GetByUID(context.Background(), order_uid)
+	if err != nil {
+		return dto.EncodedOrderDTO{}, err
+	}
+
+	orderDTO, err := s.orderConverter.OrderInfoToOrderView(*orderInfo, view)
+	if err != nil {
+		return dto.EncodedOrderDTO{}, err
+	}
+
+	projection, err := pkg.Project(orderDTO, fields)
+	if err != nil {
+		return dto.EncodedOrderDTO{}, err
+	}
+
+	return encodeOrder(projection, orderInfo.Order.UpdatedAt, format, encoding)
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"wbts/internal/domain/entity"
+)
+
+var integrityChecks = []struct {
+	name  string
+	query string
+}{
+	{
+		"orders without payment",
+		"SELECT o.order_uid FROM orders o LEFT JOIN payments p ON p.transaction = o.payment_id WHERE p.transaction IS NULL",
+	},
+	{
+		"orders without items",
+		"SELECT o.order_uid FROM orders o WHERE NOT EXISTS (SELECT 1 FROM orders_items oi WHERE oi.order_uid = o.order_uid)",
+	},
+	{
+		"order items referencing missing orders",
+		`SELECT oi.order_uid || '/' || oi.chrt_id FROM orders_items oi
+		LEFT JOIN orders o ON o.order_uid = oi.order_uid WHERE o.order_uid IS NULL`,
+	},
+	{
+		"order items referencing missing items",
+		`SELECT oi.order_uid || '/' || oi.chrt_id FROM orders_items oi
+		LEFT JOIN items i ON i.chrt_id = oi.chrt_id WHERE i.chrt_id IS NULL`,
+	},
+	{
+		"payments not referenced by orders",
+		"SELECT p.transaction FROM payments p WHERE NOT EXISTS (SELECT 1 FROM orders o WHERE o.payment_id = p.transaction)",
+	},
+	{
+		"items not referenced by orders",
+		"SELECT i.chrt_id::text FROM items i WHERE NOT EXISTS (SELECT 1 FROM orders_items oi WHERE oi.chrt_id = i.chrt_id)",
+	},
+}
+
+type AdminRepo struct {
+	pgPool *pgxpool.Pool
+}
+
+func NewAdminRepo(pgPool *pgxpool.Pool) *AdminRepo {
+	return &AdminRepo{pgPool}
+}
+
+// MigrationStatus reads the version table maintained by golang-migrate.
+func (r *AdminRepo) MigrationStatus(ctx context.Context) (entity.MigrationStatus, error) {
+	var status entity.MigrationStatus
+	if missing, err := r.MissingTables(ctx, []string{"schema_migrations"}); err != nil || len(missing) > 0 {
+		return status, err
+	}
+
+	err := r.pgPool.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&status.Version, &status.Dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return status, nil
+	}
+	if err != nil {
+		return status, err
+	}
+	status.Applied = true
+	return status, nil
+}
+
+func (r *AdminRepo) MissingTables(ctx context.Context, tables []string) ([]string, error) {
+	const query = "SELECT t FROM unnest($1::text[]) AS t WHERE to_regclass(t) IS NULL"
+
+	rows, err := r.pgPool.Query(ctx, query, tables)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// CheckIntegrity runs the referential checks between orders, payments, items and orders_items,
+// returning the number of violations and up to sampleSize offending keys for each.
+func (r *AdminRepo) CheckIntegrity(ctx context.Context, sampleSize int) ([]entity.IntegrityCheck, error) {
+	checks := make([]entity.IntegrityCheck, 0, len(integrityChecks))
+	for _, c := range integrityChecks {
+		check := entity.IntegrityCheck{Name: c.name}
+
+		if err := r.pgPool.QueryRow(ctx, "SELECT count(*) FROM ("+c.query+") violations").Scan(&check.Violations); err != nil {
+			return nil, err
+		}
+		if check.Violations > 0 {
+			rows, err := r.pgPool.Query(ctx, c.query+" LIMIT $1", sampleSize)
+			if err != nil {
+				return nil, err
+			}
+			if check.Samples, err = pgx.CollectRows(rows, pgx.RowTo[string]); err != nil {
+				return nil, err
+			}
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
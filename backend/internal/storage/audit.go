@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"wbts/internal/domain/entity"
+)
+
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+func (r *OrderRepo) RecordAudit(ctx context.Context, record entity.AuditRecord) error {
+	return insertAuditRecord(ctx, r.pgPool, record)
+}
+
+func insertAuditRecord(ctx context.Context, db execer, record entity.AuditRecord) error {
+	const query = "INSERT INTO audit_log(actor, action, subject, details) VALUES ($1, $2, $3, $4)"
+
+	details := string(record.Details)
+	if details == "" {
+		details = "{}"
+	}
+	if _, err := db.Exec(ctx, query, record.Actor, record.Action, record.Subject, details); err != nil {
+		return errors.New("Error writing audit record: " + err.Error())
+	}
+	return nil
+}
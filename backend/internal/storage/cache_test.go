@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"wbts/internal/cache"
+	"wbts/internal/domain/dto"
+	"wbts/internal/domain/entity"
+)
+
+const maskedVariant = dto.ViewMasked + ":" + dto.FormatJSON + "+" + dto.EncodingIdentity
+
+// newInstance is one API replica: a repository with its own L1 in front of the shared Redis.
+// The tests drive Evict and EvictAll directly, the callbacks ListenInvalidations registers for
+// NOTIFY payloads and for (re)subscribing.
+func newInstance(t *testing.T, server *miniredis.Miniredis) (*OrderRepo, *cache.Memory, *cache.Redis) {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	local := cache.NewMemory(0)
+	shared := cache.NewRedis(client, "test:order:", time.Hour)
+	return NewOrderRepo(nil, cache.NewTiered(local, shared), true), local, shared
+}
+
+func cachedOrder(uid string) entity.OrderInfo {
+	return entity.OrderInfo{Order: entity.Order{OrderUID: uid, Tenant: dto.DefaultTenant}}
+}
+
+func encodeBody(body string) func(entity.OrderInfo) (dto.EncodedOrderDTO, error) {
+	return func(entity.OrderInfo) (dto.EncodedOrderDTO, error) {
+		return dto.EncodedOrderDTO{Body: []byte(body)}, nil
+	}
+}
+
+func TestNotifyEvictsOtherInstances(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	writer, writerL1, shared := newInstance(t, server)
+	reader, readerL1, _ := newInstance(t, server)
+
+	writer.cache.Set(ctx, "a", cachedOrder("a"))
+	if _, err := reader.GetEncoded(ctx, "a", maskedVariant, encodeBody("v1")); err != nil {
+		t.Fatalf("GetEncoded: %v", err)
+	}
+	if _, ok := readerL1.GetEncoded(ctx, "a", maskedVariant); !ok {
+		t.Fatal("reader did not fill its L1 from Redis")
+	}
+
+	// The writer evicts after its commit; the reader still holds the old version in L1.
+	writer.Evict("a")
+	if _, ok := shared.Get(ctx, "a"); ok {
+		t.Fatal("writer did not evict the order from Redis")
+	}
+	if _, ok := writerL1.Get(ctx, "a"); ok {
+		t.Fatal("writer did not evict the order from its L1")
+	}
+	if _, ok := readerL1.Get(ctx, "a"); !ok {
+		t.Fatal("reader L1 should be stale until the notification arrives")
+	}
+
+	// The NOTIFY for "a" reaches the reader.
+	reader.Evict("a")
+	if _, ok := readerL1.Get(ctx, "a"); ok {
+		t.Error("notification did not evict the order from the reader's L1")
+	}
+	if _, ok := readerL1.GetEncoded(ctx, "a", maskedVariant); ok {
+		t.Error("notification did not evict the encoded variant from the reader's L1")
+	}
+}
+
+func TestResubscribeDropsOnlyLocalEntries(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	repo, local, shared := newInstance(t, server)
+
+	repo.cache.Set(ctx, "a", cachedOrder("a"))
+	repo.EvictAll()
+
+	if _, ok := local.Get(ctx, "a"); ok {
+		t.Error("L1 entry survived resubscribing, although notifications may have been missed")
+	}
+	if _, ok := shared.Get(ctx, "a"); !ok {
+		t.Error("Redis entry was dropped, although writers evict it themselves")
+	}
+}
+
+func TestEvictionDuringFillIsNotCached(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	repo, local, shared := newInstance(t, server)
+	repo.cache.Set(ctx, "a", cachedOrder("a"))
+
+	// A notification arriving while the variant is being encoded must win over the fill.
+	encoded, err := repo.GetEncoded(ctx, "a", maskedVariant, func(entity.OrderInfo) (dto.EncodedOrderDTO, error) {
+		repo.Evict("a")
+		repo.cache.Set(ctx, "a", cachedOrder("a"))
+		return dto.EncodedOrderDTO{Body: []byte("stale")}, nil
+	})
+	if err != nil || string(encoded.Body) != "stale" {
+		t.Fatalf("GetEncoded = %q, %v", encoded.Body, err)
+	}
+	if _, ok := local.GetEncoded(ctx, "a", maskedVariant); ok {
+		t.Error("variant encoded before the eviction was cached in L1")
+	}
+	if _, ok := shared.GetEncoded(ctx, "a", maskedVariant); ok {
+		t.Error("variant encoded before the eviction was cached in Redis")
+	}
+}
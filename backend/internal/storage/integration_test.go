@@ -0,0 +1,147 @@
+//go:build integration
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"wbts/internal/cache"
+	"wbts/internal/domain/dto"
+	"wbts/internal/pkg"
+	"wbts/internal/service"
+)
+
+// The integration tests run against the PostgreSQL server in TEST_DATABASE_URL:
+//
+//	TEST_DATABASE_URL=postgres://... go test -tags integration ./internal/storage
+//
+// Every test migrates a schema of its own and drops it afterwards.
+const migrationsDir = "../../../migrations/postgres"
+
+func newTestPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	admin, err := pgx.Connect(ctx, url)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer admin.Close(ctx)
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		conn, err := pgx.Connect(context.Background(), url)
+		if err != nil {
+			t.Errorf("connect: %v", err)
+			return
+		}
+		defer conn.Close(context.Background())
+		conn.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+	})
+
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatalf("parse TEST_DATABASE_URL: %v", err)
+	}
+	config.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("create pool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	migrations, err := filepath.Glob(filepath.Join(migrationsDir, "*.up.sql"))
+	if err != nil || len(migrations) == 0 {
+		t.Fatalf("no migrations in %s: %v", migrationsDir, err)
+	}
+	slices.Sort(migrations)
+	for _, migration := range migrations {
+		sql, err := os.ReadFile(migration)
+		if err != nil {
+			t.Fatalf("read %s: %v", migration, err)
+		}
+		if _, err := pool.Exec(ctx, string(sql)); err != nil {
+			t.Fatalf("apply %s: %v", filepath.Base(migration), err)
+		}
+	}
+	return pool
+}
+
+func integrationOrder(uid string) dto.OrderDTO {
+	return dto.OrderDTO{
+		OrderUID: uid,
+		Entry:    "WBIL",
+		Delivery: dto.DeliveryDTO{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: dto.PaymentDTO{Transaction: uid, Currency: "USD", Provider: "wbpay", Amount: 1817, Bank: "alpha"},
+		Items: []dto.ItemDTO{{
+			ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, Rid: "ab4219087a764ae0btest", Name: "Mascaras",
+			Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		}},
+		Locale:            "en",
+		InternalSignature: "sig",
+		CustomerID:        "customer-1",
+		DeliveryService:   "meest",
+		Shardkey:          "9",
+		SmID:              99,
+		DateCreated:       time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:          "1",
+		Tenant:            dto.DefaultTenant,
+	}
+}
+
+func TestReingestKeepsAnonymizedDelivery(t *testing.T) {
+	ctx := context.Background()
+	repo := NewOrderRepo(newTestPool(t), cache.NewMemory(0), false)
+	converter := &pkg.OrderConverter{}
+	orderService := service.NewOrderService(repo, converter, false)
+
+	order := integrationOrder("a")
+	if err := orderService.Save(order, ""); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := orderService.AnonymizeCustomer("customer-1", "test"); err != nil {
+		t.Fatalf("AnonymizeCustomer: %v", err)
+	}
+
+	// A redelivery, a replay and a producer update with a new track number.
+	updated := order
+	updated.TrackNumber = "WBILMNEWTRACK"
+	for i, reingested := range []dto.OrderDTO{order, order, updated} {
+		if err := orderService.Save(reingested, fmt.Sprintf("id:%d", i)); err != nil {
+			t.Fatalf("Save #%d: %v", i, err)
+		}
+	}
+
+	info, err := repo.LoadByUID(ctx, "a")
+	if err != nil {
+		t.Fatalf("LoadByUID: %v", err)
+	}
+	stored, err := converter.OrderInfoToOrderDTO(*info)
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if !info.Order.Anonymized || stored.Delivery.Phone != "" || stored.Delivery.Name == order.Delivery.Name {
+		t.Errorf("re-ingested order restored the delivery: anonymized=%t, delivery=%+v", info.Order.Anonymized, stored.Delivery)
+	}
+	if stored.TrackNumber != "WBILMNEWTRACK" {
+		t.Errorf("track number = %s, the update of other fields was lost", stored.TrackNumber)
+	}
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"wbts/internal/domain/entity"
+)
+
+// LedgerRepo maintains processed_messages, the ledger of consumed message keys that makes
+// redelivered Kafka messages no-ops.
+type LedgerRepo struct {
+	pgPool *pgxpool.Pool
+}
+
+func NewLedgerRepo(pgPool *pgxpool.Pool) *LedgerRepo {
+	return &LedgerRepo{pgPool}
+}
+
+// recordMessage adds the key to the ledger within tx. A key that is already recorded, or that
+// is being recorded by a concurrent transaction which then commits, yields ErrMessageProcessed.
+func recordMessage(ctx context.Context, tx pgx.Tx, messageKey string) error {
+	tag, err := tx.Exec(ctx, "INSERT INTO processed_messages(message_key) VALUES ($1) ON CONFLICT DO NOTHING", messageKey)
+	if err != nil {
+		return errors.New("Error recording processed message: " + err.Error())
+	}
+	if tag.RowsAffected() == 0 {
+		return entity.ErrMessageProcessed
+	}
+	return nil
+}
+
+func (r *LedgerRepo) DeleteProcessed(ctx context.Context, olderThan time.Duration) (int64, error) {
+	const query = "DELETE FROM processed_messages WHERE processed_at < NOW() - make_interval(secs => $1)"
+
+	tag, err := r.pgPool.Exec(ctx, query, olderThan.Seconds())
+	if err != nil {
+		return 0, errors.New("Error deleting processed messages: " + err.Error())
+	}
+	return tag.RowsAffected(), nil
+}
+
+// PurgeExpired deletes ledger entries older than retention every interval until ctx is done.
+// Retention must exceed the longest time a message can be redelivered after, or duplicates
+// older than that are processed again.
+func (r *LedgerRepo) PurgeExpired(ctx context.Context, retention time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := r.DeleteProcessed(ctx, retention)
+			if err != nil {
+				log.Printf("Error purging processed messages: %v", err)
+			} else if deleted > 0 {
+				log.Printf("Deleted %d processed message keys", deleted)
+			}
+		}
+	}
+}
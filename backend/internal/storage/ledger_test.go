@@ -0,0 +1,111 @@
+//go:build integration
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"wbts/internal/cache"
+	"wbts/internal/domain/entity"
+	"wbts/internal/pkg"
+	"wbts/internal/service"
+)
+
+func countRows(t *testing.T, pool *pgxpool.Pool, table string) int {
+	t.Helper()
+	var n int
+	if err := pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM "+table).Scan(&n); err != nil {
+		t.Fatalf("count %s: %v", table, err)
+	}
+	return n
+}
+
+func TestLedgerSkipsRedeliveries(t *testing.T) {
+	ctx := context.Background()
+	pool := newTestPool(t)
+	repo := NewOrderRepo(pool, cache.NewMemory(0), false)
+	orderService := service.NewOrderService(repo, &pkg.OrderConverter{}, true)
+
+	if err := orderService.Save(integrationOrder("a"), "orders/0/1"); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// The redelivered message carries a change that must not be applied a second time.
+	redelivered := integrationOrder("a")
+	redelivered.TrackNumber = "WBILMNEWTRACK"
+	if err := orderService.Save(redelivered, "orders/0/1"); !errors.Is(err, entity.ErrMessageProcessed) {
+		t.Fatalf("Save of a redelivered message = %v, want ErrMessageProcessed", err)
+	}
+	info, err := repo.LoadByUID(ctx, "a")
+	if err != nil {
+		t.Fatalf("LoadByUID: %v", err)
+	}
+	if info.Order.TrackNumber == "WBILMNEWTRACK" {
+		t.Error("the redelivered message was applied")
+	}
+	if n := countRows(t, pool, "order_outbox"); n != 1 {
+		t.Errorf("%d outbox events, want 1 for the first delivery only", n)
+	}
+
+	// Another message, and saves without a key, are applied as usual.
+	if err := orderService.Save(redelivered, "orders/0/2"); err != nil {
+		t.Fatalf("Save of a new message: %v", err)
+	}
+	if err := orderService.Save(redelivered, ""); err != nil {
+		t.Fatalf("Save without a key: %v", err)
+	}
+	if n := countRows(t, pool, "order_outbox"); n != 3 {
+		t.Errorf("%d outbox events, want 3", n)
+	}
+	if n := countRows(t, pool, "processed_messages"); n != 2 {
+		t.Errorf("%d ledger entries, want 2", n)
+	}
+
+	ledger := NewLedgerRepo(pool)
+	if n, err := ledger.DeleteProcessed(ctx, time.Hour); err != nil || n != 0 {
+		t.Errorf("DeleteProcessed(1h) = %d, %v, want recent keys kept", n, err)
+	}
+	if n, err := ledger.DeleteProcessed(ctx, 0); err != nil || n != 2 {
+		t.Errorf("DeleteProcessed(0) = %d, %v, want 2", n, err)
+	}
+}
+
+func TestLedgerConcurrentDeliveries(t *testing.T) {
+	pool := newTestPool(t)
+	orderService := service.NewOrderService(NewOrderRepo(pool, cache.NewMemory(0), false), &pkg.OrderConverter{}, true)
+
+	const deliveries = 4
+	errs := make(chan error, deliveries)
+	var wg sync.WaitGroup
+	for range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- orderService.Save(integrationOrder("a"), "orders/0/1")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	saved := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			saved++
+		case !errors.Is(err, entity.ErrMessageProcessed):
+			t.Errorf("Save: %v", err)
+		}
+	}
+	if saved != 1 {
+		t.Errorf("%d concurrent deliveries saved, want 1", saved)
+	}
+	if n := countRows(t, pool, "order_outbox"); n != 1 {
+		t.Errorf("%d outbox events, want 1", n)
+	}
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	orderChangedChannel     = "order_changed"
+	listenReconnectInterval = time.Second
+)
+
+// listenChannel keeps a dedicated connection LISTENing on channel until ctx is done,
+// reconnecting after a second whenever the connection drops. onListen is called every time
+// the subscription is (re)established, handle for every notification payload.
+func listenChannel(ctx context.Context, pgPool *pgxpool.Pool, channel string, onListen func(), handle func(payload string)) {
+	for ctx.Err() == nil {
+		if err := listenOnce(ctx, pgPool, channel, onListen, handle); err != nil && ctx.Err() == nil {
+			log.Printf("Listener on %s disconnected: %v", channel, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(listenReconnectInterval):
+			}
+		}
+	}
+}
+
+func listenOnce(ctx context.Context, pgPool *pgxpool.Pool, channel string, onListen func(), handle func(payload string)) error {
+	poolConn, err := pgPool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	conn := poolConn.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+channel); err != nil {
+		return err
+	}
+	if onListen != nil {
+		onListen()
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		handle(notification.Payload)
+	}
+}
+
+func notifyOrderChanged(ctx context.Context, db execer, order_uid string) error {
+	if _, err := db.Exec(ctx, "SELECT pg_notify($1, $2)", orderChangedChannel, order_uid); err != nil {
+		return errors.New("Error notifying about order change: " + err.Error())
+	}
+	return nil
+}
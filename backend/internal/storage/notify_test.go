@@ -0,0 +1,106 @@
+//go:build integration
+
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"wbts/internal/cache"
+	"wbts/internal/pkg"
+	"wbts/internal/service"
+)
+
+// waitFor polls cond until it holds or the timeout expires.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// listenerPID is the backend LISTENing for order changes, 0 while there is none.
+func listenerPID(t *testing.T, pool *pgxpool.Pool) int {
+	t.Helper()
+	const query = `
+		SELECT COALESCE(MAX(pid), 0) FROM pg_stat_activity
+		WHERE datname = current_database() AND query = 'LISTEN ' || $1 AND pid <> pg_backend_pid()
+	`
+	var pid int
+	if err := pool.QueryRow(context.Background(), query, orderChangedChannel).Scan(&pid); err != nil {
+		t.Fatalf("find the listener: %v", err)
+	}
+	return pid
+}
+
+func TestListenInvalidations(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := newTestPool(t)
+	converter := &pkg.OrderConverter{}
+
+	// Two replicas with caches of their own; the second one listens for changes.
+	writer := service.NewOrderService(NewOrderRepo(pool, cache.NewMemory(0), false), converter, false)
+	replicaCache := cache.NewMemory(0)
+	replica := NewOrderRepo(pool, replicaCache, false)
+	replica.ListenInvalidations(ctx)
+	waitFor(t, "the listener", func() bool { return listenerPID(t, pool) != 0 })
+
+	cached := func(order_uid string) bool {
+		_, ok := replicaCache.Get(ctx, order_uid)
+		return ok
+	}
+	for _, uid := range []string{"a", "b"} {
+		if err := writer.Save(integrationOrder(uid), ""); err != nil {
+			t.Fatalf("Save %s: %v", uid, err)
+		}
+		if _, err := replica.GetByUID(ctx, uid); err != nil {
+			t.Fatalf("GetByUID %s: %v", uid, err)
+		}
+	}
+	if !cached("a") || !cached("b") {
+		t.Fatal("replica did not cache the orders")
+	}
+
+	// A write on another instance evicts just that order.
+	updated := integrationOrder("a")
+	updated.TrackNumber = "WBILMNEWTRACK"
+	if err := writer.Save(updated, ""); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	waitFor(t, "the eviction of a", func() bool { return !cached("a") })
+	if !cached("b") {
+		t.Error("an unrelated order was evicted")
+	}
+
+	// Notifications are lost while the listener is down, so it drops the whole cache once it
+	// is back.
+	if _, err := replica.GetByUID(ctx, "a"); err != nil {
+		t.Fatalf("GetByUID: %v", err)
+	}
+	pid := listenerPID(t, pool)
+	if _, err := pool.Exec(ctx, "SELECT pg_terminate_backend($1)", pid); err != nil {
+		t.Fatalf("terminate the listener: %v", err)
+	}
+	waitFor(t, "the listener to reconnect", func() bool {
+		newPID := listenerPID(t, pool)
+		return newPID != 0 && newPID != pid
+	})
+	waitFor(t, "the cache to be dropped", func() bool { return !cached("a") && !cached("b") })
+
+	// The new subscription keeps evicting.
+	if _, err := replica.GetByUID(ctx, "b"); err != nil {
+		t.Fatalf("GetByUID: %v", err)
+	}
+	if err := writer.Save(integrationOrder("b"), ""); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	waitFor(t, "the eviction of b after reconnecting", func() bool { return !cached("b") })
+}
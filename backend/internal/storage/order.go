@@ -5,36 +5,276 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"wbts/internal/cache"
+	"wbts/internal/domain/dto"
 	"wbts/internal/domain/entity"
 	"wbts/internal/pkg"
 )
 
+const orderColumns = `
+	order_uid, track_number, entry, delivery, payment_id, locale, internal_signature,
+	customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard,
+	COALESCE(phone_bidx, ''), COALESCE(email_bidx, ''), COALESCE(customer_bidx, ''), tenant,
+	updated_at, anonymized
+`
+
 type OrderRepo struct {
 	pgPool *pgxpool.Pool
-	cache  map[string]entity.OrderInfo
+	cache  cache.OrderCache
 	mtx sync.RWMutex
+	cacheEncoded bool
+	// generation changes on every eviction, so a load that raced with one is not cached.
+	generation uint64
 }
 
-func NewOrderRepo(pgPool *pgxpool.Pool) *OrderRepo {
-	return &OrderRepo{pgPool, make(map[string]entity.OrderInfo), sync.RWMutex{}}
+func NewOrderRepo(pgPool *pgxpool.Pool, orderCache cache.OrderCache, cacheEncoded bool) *OrderRepo {
+	return &OrderRepo{pgPool, orderCache, sync.RWMutex{}, cacheEncoded, 0}
 }
 
 func (r *OrderRepo) GetByUID(ctx context.Context, order_uid string) (*entity.OrderInfo, error) {
-	startTime := time.Now()
-	r.mtx.RLock()
-	v, ok := r.cache[order_uid]
-	r.mtx.RUnlock()
-	if ok {
-		log.Printf("Cache hit for order with uid=%s. Fetching time: %s", order_uid, time.Since(startTime))
-		return &v, nil
+	orderInfo, err := r.getCachedOrder(ctx, order_uid)
+	if err != nil {
+		return nil, err
+	}
+
+	return &orderInfo, nil
+}
+
+func (r *OrderRepo) GetEncoded(
+	ctx context.Context,
+	order_uid string,
+	variant string,
+	encode func(entity.OrderInfo) (dto.EncodedOrderDTO, error),
+) (dto.EncodedOrderDTO, error) {
+	if encoded, ok := r.cache.GetEncoded(ctx, order_uid, variant); ok {
+		return encoded, nil
 	}
 
+	generation := r.currentGeneration()
+	orderInfo, err := r.getCachedOrder(ctx, order_uid)
+	if err != nil {
+		return dto.EncodedOrderDTO{}, err
+	}
+
+	encoded, err := encode(orderInfo)
+	if err != nil {
+		return dto.EncodedOrderDTO{}, err
+	}
+
+	if r.cacheEncoded {
+		r.mtx.RLock()
+		if r.generation == generation {
+			r.cache.SetEncoded(ctx, order_uid, variant, encoded)
+		}
+		r.mtx.RUnlock()
+	}
+
+	return encoded, nil
+}
+
+func (r *OrderRepo) ListUIDs(ctx context.Context, after string, limit int) ([]string, error) {
+	const query = "SELECT order_uid FROM orders WHERE order_uid > $1 ORDER BY order_uid LIMIT $2"
+
+	rows, err := r.pgPool.Query(ctx, query, after, limit)
+	if err != nil {
+		return nil, errors.New("Error listing orders: " + err.Error())
+	}
+	defer rows.Close()
+
+	uids := make([]string, 0, limit)
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			return nil, errors.New("Error scanning order uids: " + err.Error())
+		}
+		uids = append(uids, uid)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("Error getting order uid rows: " + err.Error())
+	}
+
+	return uids, nil
+}
+
+func (r *OrderRepo) FindUIDs(ctx context.Context, lookup entity.OrderLookup, limit int) ([]string, error) {
+	var conditions []string
+	var args []any
+	addCondition := func(format string, values ...any) {
+		placeholders := make([]any, len(values))
+		for i, v := range values {
+			args = append(args, v)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		conditions = append(conditions, fmt.Sprintf(format, placeholders...))
+	}
+
+	if lookup.Phone != "" {
+		addCondition("(phone_bidx = %s OR delivery->>'phone' = %s)", lookup.PhoneIndex, lookup.Phone)
+	}
+	if lookup.Email != "" {
+		addCondition("(email_bidx = %s OR lower(delivery->>'email') = %s)", lookup.EmailIndex, lookup.Email)
+	}
+	if lookup.CustomerID != "" {
+		addCondition("(customer_bidx = %s OR customer_id = %s)", lookup.CustomerIndex, lookup.CustomerID)
+	}
+	if len(conditions) == 0 {
+		return nil, errors.New("Order lookup requires at least one condition")
+	}
+	if lookup.Tenant != "" {
+		addCondition("tenant = %s", lookup.Tenant)
+	}
+
+	args = append(args, limit)
+	query := fmt.Sprintf(
+		"SELECT order_uid FROM orders WHERE %s ORDER BY order_uid LIMIT $%d",
+		strings.Join(conditions, " AND "), len(args),
+	)
+
+	rows, err := r.pgPool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errors.New("Error finding orders: " + err.Error())
+	}
+	uids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, errors.New("Error scanning order uids: " + err.Error())
+	}
+	return uids, nil
+}
+
+func (r *OrderRepo) ListOrders(ctx context.Context, after string, limit int) ([]entity.Order, error) {
+	query := "SELECT " + orderColumns + " FROM orders WHERE order_uid > $1 ORDER BY order_uid LIMIT $2"
+
+	rows, err := r.pgPool.Query(ctx, query, after, limit)
+	if err != nil {
+		return nil, errors.New("Error listing orders: " + err.Error())
+	}
+	orders, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (entity.Order, error) {
+		return scanOrder(row)
+	})
+	if err != nil {
+		return nil, errors.New("Error scanning orders: " + err.Error())
+	}
+	return orders, nil
+}
+
+func (r *OrderRepo) FindCustomerOrderUIDs(ctx context.Context, customerID string, customerIndex string) ([]string, error) {
+	const query = `
+		SELECT order_uid FROM orders
+		WHERE customer_id = $1 OR customer_bidx = NULLIF($2, '')
+		ORDER BY order_uid
+	`
+
+	rows, err := r.pgPool.Query(ctx, query, customerID, customerIndex)
+	if err != nil {
+		return nil, errors.New("Error finding customer orders: " + err.Error())
+	}
+	uids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, errors.New("Error scanning order uids: " + err.Error())
+	}
+	return uids, nil
+}
+
+// UpdateProtectedFields writes re-encrypted fields only if the order is still at the version
+// it was read at (order.UpdatedAt), and returns ErrOrderChanged otherwise, so a concurrent
+// upsert is never overwritten with stale data.
+func (r *OrderRepo) UpdateProtectedFields(ctx context.Context, order entity.Order) error {
+	tx, err := r.pgPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	updated, err := updateProtectedFields(ctx, tx, order)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return entity.ErrOrderChanged
+	}
+	if err := notifyOrderChanged(ctx, tx, order.OrderUID); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	r.Evict(order.OrderUID)
+	return nil
+}
+
+// ReplaceProtectedFields writes the protected fields of all orders and the audit record in one
+// transaction. Every order is only updated while its updated_at still equals order.UpdatedAt;
+// if any of them changed since it was read, nothing is written and entity.ErrOrderChanged is
+// returned.
+func (r *OrderRepo) ReplaceProtectedFields(ctx context.Context, orders []entity.Order, audit entity.AuditRecord) error {
+	tx, err := r.pgPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, order := range orders {
+		updated, err := updateProtectedFields(ctx, tx, order)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return entity.ErrOrderChanged
+		}
+		if err := notifyOrderChanged(ctx, tx, order.OrderUID); err != nil {
+			return err
+		}
+	}
+	if err := insertAuditRecord(ctx, tx, audit); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		r.Evict(order.OrderUID)
+	}
+	return nil
+}
+
+// updateProtectedFields reports whether the order was updated. The update only applies while
+// updated_at still equals order.UpdatedAt, so a concurrent write is never overwritten.
+func updateProtectedFields(ctx context.Context, db execer, order entity.Order) (bool, error) {
+	const query = `
+		UPDATE orders SET
+			delivery = $2,
+			customer_id = $3,
+			phone_bidx = NULLIF($4, ''),
+			email_bidx = NULLIF($5, ''),
+			customer_bidx = NULLIF($6, ''),
+			anonymized = $7,
+			updated_at = NOW()
+		WHERE order_uid = $1 AND updated_at = $8
+	`
+
+	tag, err := db.Exec(
+		ctx, query,
+		order.OrderUID, order.Delivery, order.CustomerID, order.PhoneIndex, order.EmailIndex, order.CustomerIndex,
+		order.Anonymized, order.UpdatedAt,
+	)
+	if err != nil {
+		return false, errors.New("Error updating protected order fields: " + err.Error())
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// LoadByUID reads the order straight from the database, neither consulting nor filling the cache.
+func (r *OrderRepo) LoadByUID(ctx context.Context, order_uid string) (*entity.OrderInfo, error) {
 	order, err := r.getOrderByUID(ctx, order_uid)
 	if err != nil {
 		return nil, err
@@ -50,23 +290,81 @@ func (r *OrderRepo) GetByUID(ctx context.Context, order_uid string) (*entity.Ord
 		return nil, err
 	}
 
-	orderInfo := entity.OrderInfo{Order: order, Payment: payment, Items: items}
-	
+	return &entity.OrderInfo{Order: order, Payment: payment, Items: items}, nil
+}
+
+func (r *OrderRepo) Evict(order_uid string) {
 	r.mtx.Lock()
-	r.cache[order_uid] = orderInfo
+	r.cache.Delete(context.Background(), order_uid)
+	r.generation++
 	r.mtx.Unlock()
+}
+
+func (r *OrderRepo) EvictAll() {
+	r.mtx.Lock()
+	r.cache.Reset(context.Background())
+	r.generation++
+	r.mtx.Unlock()
+}
+
+func (r *OrderRepo) currentGeneration() uint64 {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	return r.generation
+}
+
+// ListenInvalidations evicts orders changed by any instance, as announced by the NOTIFY each
+// repository write sends on commit. Notifications sent while the listener was disconnected
+// are lost, so the whole cache is dropped whenever the subscription is (re)established.
+func (r *OrderRepo) ListenInvalidations(ctx context.Context) {
+	go listenChannel(ctx, r.pgPool, orderChangedChannel, r.EvictAll, r.Evict)
+}
+
+func (r *OrderRepo) getCachedOrder(ctx context.Context, order_uid string) (entity.OrderInfo, error) {
+	startTime := time.Now()
+	generation := r.currentGeneration()
+	if info, ok := r.cache.Get(ctx, order_uid); ok {
+		log.Printf("Cache hit for order with uid=%s. Fetching time: %s", order_uid, time.Since(startTime))
+		return info, nil
+	}
+
+	info, err := r.LoadByUID(ctx, order_uid)
+	if err != nil {
+		return entity.OrderInfo{}, err
+	}
+
+	// The read lock lets fills of different orders run concurrently while keeping them
+	// ordered with evictions, which take the write lock.
+	r.mtx.RLock()
+	if r.generation == generation {
+		r.cache.Set(ctx, order_uid, *info)
+	}
+	r.mtx.RUnlock()
 
 	log.Printf("Order with uid=%s was not found in cache. Fetching time: %s", order_uid, time.Since(startTime))
-	return &orderInfo, nil
+	return *info, nil
 }
 
-func (r *OrderRepo) Upsert(ctx context.Context, orderInfo entity.OrderInfo) error {
+// Upsert saves the order and, unless eventPayload is nil, its outbox event in one transaction.
+// A non-empty messageKey is recorded in the processed message ledger first, and a key seen
+// before rolls the whole transaction back with ErrMessageProcessed, so a redelivered message
+// emits no second event.
+// Data exceptions and constraint violations are returned as ErrInvalidOrder.
+func (r *OrderRepo) Upsert(ctx context.Context, orderInfo entity.OrderInfo, eventPayload []byte, messageKey string) (err error) {
+	defer func() { err = classifyError(err) }()
+
 	tx, err := r.pgPool.Begin(ctx)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback(ctx)
 
+	if messageKey != "" {
+		if err := recordMessage(ctx, tx, messageKey); err != nil {
+			return err
+		}
+	}
+
 	if err := r.upsertPayment(ctx, tx, orderInfo.Payment); err != nil {
 		return err
 	}
@@ -75,7 +373,11 @@ func (r *OrderRepo) Upsert(ctx context.Context, orderInfo entity.OrderInfo) erro
 		return err
 	}
 
-	if err := r.upsertOrder(ctx, tx, orderInfo.Order); err != nil {
+	inserted, err := r.upsertOrder(ctx, tx, orderInfo.Order)
+	if err != nil {
+		return err
+	}
+	if err := notifyOrderChanged(ctx, tx, orderInfo.Order.OrderUID); err != nil {
 		return err
 	}
 
@@ -87,7 +389,32 @@ func (r *OrderRepo) Upsert(ctx context.Context, orderInfo entity.OrderInfo) erro
 		return err
 	}
 
-	return tx.Commit(ctx)
+	if eventPayload != nil {
+		eventType := entity.EventOrderUpdated
+		if inserted {
+			eventType = entity.EventOrderCreated
+		}
+		if err := r.insertOutboxEvent(ctx, tx, orderInfo.Order.OrderUID, eventType, eventPayload); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	r.Evict(orderInfo.Order.OrderUID)
+	return nil
+}
+
+// classifyError marks errors that repeat on every retry as entity.ErrInvalidOrder: SQLSTATE
+// class 22 (data exception) and 23 (integrity constraint violation).
+func classifyError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && (strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23")) {
+		return fmt.Errorf("%w: %w", entity.ErrInvalidOrder, err)
+	}
+	return err
 }
 
 func (r *OrderRepo) upsertPayment(ctx context.Context, tx pgx.Tx, payment entity.Payment) error {
@@ -151,16 +478,22 @@ func (r *OrderRepo) upsertItems(ctx context.Context, tx pgx.Tx, items []entity.I
 	return nil
 }
 
-func (r *OrderRepo) upsertOrder(ctx context.Context, tx pgx.Tx, order entity.Order) error {
+// upsertOrder reports whether the order was inserted. Updating an anonymized order keeps its
+// anonymized delivery and the blind indexes derived from it, so a redelivered, replayed or
+// updated message cannot restore the erased data.
+func (r *OrderRepo) upsertOrder(ctx context.Context, tx pgx.Tx, order entity.Order) (bool, error) {
 	const query = `
         INSERT INTO orders(
 			order_uid, track_number, entry, delivery, payment_id, locale, internal_signature, 
-			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard,
+			phone_bidx, email_bidx, customer_bidx, tenant
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NULLIF($14, ''), NULLIF($15, ''), NULLIF($16, ''), COALESCE(NULLIF($17, ''), 'default')
+		)
         ON CONFLICT (order_uid) DO UPDATE SET
             track_number=EXCLUDED.track_number,
             entry=EXCLUDED.entry,
-            delivery=EXCLUDED.delivery,
+            delivery=CASE WHEN orders.anonymized THEN orders.delivery ELSE EXCLUDED.delivery END,
             payment_id=EXCLUDED.payment_id,
             locale=EXCLUDED.locale,
             internal_signature=EXCLUDED.internal_signature,
@@ -169,19 +502,32 @@ func (r *OrderRepo) upsertOrder(ctx context.Context, tx pgx.Tx, order entity.Ord
             shardkey=EXCLUDED.shardkey,
             sm_id=EXCLUDED.sm_id,
             date_created=EXCLUDED.date_created,
-            oof_shard=EXCLUDED.oof_shard
+            oof_shard=EXCLUDED.oof_shard,
+            phone_bidx=CASE WHEN orders.anonymized THEN orders.phone_bidx ELSE EXCLUDED.phone_bidx END,
+            email_bidx=CASE WHEN orders.anonymized THEN orders.email_bidx ELSE EXCLUDED.email_bidx END,
+            customer_bidx=EXCLUDED.customer_bidx,
+            tenant=EXCLUDED.tenant,
+            updated_at=NOW()
+        WHERE orders.tenant = EXCLUDED.tenant
+        RETURNING (xmax = 0)
 	`
-	_, err := tx.Exec(
+	var inserted bool
+	err := tx.QueryRow(
 		ctx,
 		query,
 		order.OrderUID, order.TrackNumber, order.Entry, order.Delivery, order.PaymentID,
         order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService,
         order.Shardkey, order.SmID, order.DateCreated, order.OofShard,
-	)
+        order.PhoneIndex, order.EmailIndex, order.CustomerIndex, order.Tenant,
+	).Scan(&inserted)
+	// The conflict update skips rows of another tenant and then returns no row.
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, entity.ErrTenantConflict
+	}
 	if err != nil {
-		return err
+		return false, err
 	}
-	return nil
+	return inserted, nil
 }
 
 func (r *OrderRepo) insertOrdersItems(ctx context.Context, tx pgx.Tx, order_uid string, chrt_ids []int64) error {
@@ -195,15 +541,36 @@ func (r *OrderRepo) insertOrdersItems(ctx context.Context, tx pgx.Tx, order_uid
 	return nil
 }
 
-func (r *OrderRepo) getOrderByUID(ctx context.Context, order_uid string) (entity.Order, error) {
-	const query = "SELECT * FROM orders WHERE order_uid = $1"
+func (r *OrderRepo) insertOutboxEvent(ctx context.Context, tx pgx.Tx, order_uid string, eventType string, payload []byte) error {
+	const query = "INSERT INTO order_outbox(order_uid, event_type, payload) VALUES ($1, $2, $3)"
+
+	if _, err := tx.Exec(ctx, query, order_uid, eventType, string(payload)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "SELECT pg_notify($1, $2)", outboxChannel, order_uid); err != nil {
+		return err
+	}
+	return nil
+}
 
-	var order entity.Order 
-	err := r.pgPool.QueryRow(ctx, query, order_uid).Scan(
+func scanOrder(row pgx.Row) (entity.Order, error) {
+	var order entity.Order
+	err := row.Scan(
 		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Delivery, &order.PaymentID, &order.Locale,
 		&order.InternalSignature, &order.CustomerID, &order.DeliveryService, &order.Shardkey, &order.SmID,
-		&order.DateCreated, &order.OofShard,
+		&order.DateCreated, &order.OofShard, &order.PhoneIndex, &order.EmailIndex, &order.CustomerIndex,
+		&order.Tenant, &order.UpdatedAt, &order.Anonymized,
 	)
+	return order, err
+}
+
+func (r *OrderRepo) getOrderByUID(ctx context.Context, order_uid string) (entity.Order, error) {
+	query := "SELECT " + orderColumns + " FROM orders WHERE order_uid = $1"
+
+	order, err := scanOrder(r.pgPool.QueryRow(ctx, query, order_uid))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return entity.Order{}, entity.ErrOrderNotFound
+	}
 	if err != nil {
 		return entity.Order{}, errors.New("Error getting order by UID: " + err.Error())
 	}
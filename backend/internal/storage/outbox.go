@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"wbts/internal/domain/entity"
+)
+
+const (
+	outboxChannel = "order_outbox"
+	outboxLockKey = 20250801
+)
+
+type OutboxRepo struct {
+	pgPool *pgxpool.Pool
+}
+
+func NewOutboxRepo(pgPool *pgxpool.Pool) *OutboxRepo {
+	return &OutboxRepo{pgPool}
+}
+
+func (r *OutboxRepo) PublishPending(
+	ctx context.Context,
+	limit int,
+	publish func(ctx context.Context, events []entity.OutboxEvent) error,
+) (int, error) {
+	tx, err := r.pgPool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var locked bool
+	if err := tx.QueryRow(ctx, "SELECT pg_try_advisory_xact_lock($1)", outboxLockKey).Scan(&locked); err != nil {
+		return 0, errors.New("Error acquiring outbox lock: " + err.Error())
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	const query = `
+		SELECT id, order_uid, event_type, payload, created_at FROM order_outbox
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1
+	`
+	rows, err := tx.Query(ctx, query, limit)
+	if err != nil {
+		return 0, errors.New("Error getting outbox events: " + err.Error())
+	}
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (entity.OutboxEvent, error) {
+		var event entity.OutboxEvent
+		err := row.Scan(&event.ID, &event.OrderUID, &event.EventType, &event.Payload, &event.CreatedAt)
+		return event, err
+	})
+	if err != nil {
+		return 0, errors.New("Error scanning outbox events: " + err.Error())
+	}
+	if len(events) == 0 {
+		return 0, tx.Commit(ctx)
+	}
+
+	if err := publish(ctx, events); err != nil {
+		return 0, err
+	}
+
+	ids := make([]int64, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	if _, err := tx.Exec(ctx, "UPDATE order_outbox SET published_at = NOW() WHERE id = ANY($1)", ids); err != nil {
+		return 0, errors.New("Error marking outbox events as published: " + err.Error())
+	}
+
+	return len(events), tx.Commit(ctx)
+}
+
+func (r *OutboxRepo) DeletePublished(ctx context.Context, olderThan time.Duration) (int64, error) {
+	const query = "DELETE FROM order_outbox WHERE published_at < NOW() - make_interval(secs => $1)"
+
+	tag, err := r.pgPool.Exec(ctx, query, olderThan.Seconds())
+	if err != nil {
+		return 0, errors.New("Error deleting published outbox events: " + err.Error())
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *OutboxRepo) Listen(ctx context.Context) <-chan struct{} {
+	notifications := make(chan struct{}, 1)
+
+	go func() {
+		defer close(notifications)
+		listenChannel(ctx, r.pgPool, outboxChannel, nil, func(string) {
+			select {
+			case notifications <- struct{}{}:
+			default:
+			}
+		})
+	}()
+
+	return notifications
+}
@@ -0,0 +1,134 @@
+//go:build integration
+
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"wbts/internal/cache"
+	"wbts/internal/domain/dto"
+	"wbts/internal/domain/entity"
+	"wbts/internal/pkg"
+	"wbts/internal/service"
+)
+
+func TestOutboxRelay(t *testing.T) {
+	ctx := context.Background()
+	pool := newTestPool(t)
+	orderService := service.NewOrderService(NewOrderRepo(pool, cache.NewMemory(0), false), &pkg.OrderConverter{}, true)
+	outbox := NewOutboxRepo(pool)
+
+	updated := integrationOrder("a")
+	updated.TrackNumber = "WBILMNEWTRACK"
+	for _, order := range []dto.OrderDTO{integrationOrder("a"), integrationOrder("b"), updated} {
+		if err := orderService.Save(order, ""); err != nil {
+			t.Fatalf("Save %s: %v", order.OrderUID, err)
+		}
+	}
+
+	var published []entity.OutboxEvent
+	publish := func(ctx context.Context, events []entity.OutboxEvent) error {
+		published = append(published, events...)
+		return nil
+	}
+
+	// A failed publish leaves the events pending.
+	n, err := outbox.PublishPending(ctx, 10, func(context.Context, []entity.OutboxEvent) error {
+		return errors.New("broker unavailable")
+	})
+	if err == nil || n != 0 {
+		t.Fatalf("PublishPending with a failing publisher = %d, %v, want an error", n, err)
+	}
+
+	// Another relay holds the lock: this one must not publish the same events.
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", outboxLockKey); err != nil {
+		t.Fatalf("take the outbox lock: %v", err)
+	}
+	if n, err := outbox.PublishPending(ctx, 10, publish); err != nil || n != 0 || len(published) != 0 {
+		t.Fatalf("PublishPending while locked = %d, %v, published %d events", n, err, len(published))
+	}
+	tx.Rollback(ctx)
+
+	// Events are claimed in order, in batches of the limit, and acknowledged once published.
+	for _, want := range []int{2, 1, 0} {
+		n, err := outbox.PublishPending(ctx, 2, publish)
+		if err != nil || n != want {
+			t.Fatalf("PublishPending = %d, %v, want %d", n, err, want)
+		}
+	}
+	wantEvents := []struct{ uid, eventType string }{
+		{"a", entity.EventOrderCreated},
+		{"b", entity.EventOrderCreated},
+		{"a", entity.EventOrderUpdated},
+	}
+	if len(published) != len(wantEvents) {
+		t.Fatalf("published %d events, want %d", len(published), len(wantEvents))
+	}
+	for i, want := range wantEvents {
+		event := published[i]
+		if event.OrderUID != want.uid || event.EventType != want.eventType {
+			t.Errorf("event %d = %s %s, want %s %s", i, event.EventType, event.OrderUID, want.eventType, want.uid)
+		}
+		if i > 0 && event.ID <= published[i-1].ID {
+			t.Errorf("event %d has id %d after %d", i, event.ID, published[i-1].ID)
+		}
+		var payload dto.OrderDTO
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			t.Fatalf("event %d payload: %v", i, err)
+		}
+		if payload.Delivery.Phone == integrationOrder(want.uid).Delivery.Phone {
+			t.Errorf("event %d carries the unmasked phone", i)
+		}
+	}
+
+	if n, err := outbox.DeletePublished(ctx, time.Hour); err != nil || n != 0 {
+		t.Errorf("DeletePublished(1h) = %d, %v, want recent events kept", n, err)
+	}
+	if n, err := outbox.DeletePublished(ctx, 0); err != nil || n != 3 {
+		t.Errorf("DeletePublished(0) = %d, %v, want 3", n, err)
+	}
+}
+
+func TestOutboxListen(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := newTestPool(t)
+	orderService := service.NewOrderService(NewOrderRepo(pool, cache.NewMemory(0), false), &pkg.OrderConverter{}, true)
+
+	notifications := NewOutboxRepo(pool).Listen(ctx)
+	// The listener connects in the background, so save until a notification arrives.
+	deadline := time.After(10 * time.Second)
+	for saved := false; !saved; {
+		if err := orderService.Save(integrationOrder("a"), ""); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		select {
+		case <-notifications:
+			saved = true
+		case <-time.After(200 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("no notification for the new outbox event")
+		}
+	}
+
+	cancel()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-notifications:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("notification channel is open after the context was cancelled")
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"wbts/internal/pkg"
+)
+
+const (
+	SASLPlain       = "PLAIN"
+	SASLScramSHA256 = "SCRAM-SHA-256"
+	SASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+// ClientConfig holds the Sarama options shared by the consumer, the outbox relay and the
+// command line tools. Build turns it into a fresh sarama.Config for every client.
+type ClientConfig struct {
+	ClientID          string
+	Version           string
+	InitialOffset     string
+	RebalanceStrategy string
+	SessionTimeout    time.Duration
+	HeartbeatInterval time.Duration
+	RebalanceTimeout  time.Duration
+	FetchMinBytes     int
+	FetchDefaultBytes int
+	FetchMaxBytes     int
+	FetchMaxWait      time.Duration
+
+	SASLMechanism string
+	SASLUsername  string
+	SASLPassword  string
+
+	TLSEnabled            bool
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSServerName         string
+	TLSInsecureSkipVerify bool
+}
+
+func ClientConfigFromEnv() ClientConfig {
+	return ClientConfig{
+		ClientID:          pkg.GetEnv("KAFKA_CLIENT_ID", "wbts"),
+		Version:           pkg.GetEnv("KAFKA_VERSION", "2.8.0"),
+		InitialOffset:     pkg.GetEnv("KAFKA_INITIAL_OFFSET", "newest"),
+		RebalanceStrategy: pkg.GetEnv("KAFKA_REBALANCE_STRATEGY", "range"),
+		SessionTimeout:    pkg.GetEnvDuration("KAFKA_SESSION_TIMEOUT", 10*time.Second),
+		HeartbeatInterval: pkg.GetEnvDuration("KAFKA_HEARTBEAT_INTERVAL", 3*time.Second),
+		RebalanceTimeout:  pkg.GetEnvDuration("KAFKA_REBALANCE_TIMEOUT", time.Minute),
+		FetchMinBytes:     pkg.GetEnvInt("KAFKA_FETCH_MIN_BYTES", 1),
+		FetchDefaultBytes: pkg.GetEnvInt("KAFKA_FETCH_DEFAULT_BYTES", 1<<20),
+		FetchMaxBytes:     pkg.GetEnvInt("KAFKA_FETCH_MAX_BYTES", 0),
+		FetchMaxWait:      pkg.GetEnvDuration("KAFKA_FETCH_MAX_WAIT", 500*time.Millisecond),
+
+		SASLMechanism: strings.ToUpper(os.Getenv("KAFKA_SASL_MECHANISM")),
+		SASLUsername:  os.Getenv("KAFKA_SASL_USERNAME"),
+		SASLPassword:  os.Getenv("KAFKA_SASL_PASSWORD"),
+
+		TLSEnabled:            pkg.GetEnvBool("KAFKA_TLS_ENABLED", false),
+		TLSCAFile:             os.Getenv("KAFKA_TLS_CA_FILE"),
+		TLSCertFile:           os.Getenv("KAFKA_TLS_CERT_FILE"),
+		TLSKeyFile:            os.Getenv("KAFKA_TLS_KEY_FILE"),
+		TLSServerName:         os.Getenv("KAFKA_TLS_SERVER_NAME"),
+		TLSInsecureSkipVerify: pkg.GetEnvBool("KAFKA_TLS_INSECURE_SKIP_VERIFY", false),
+	}
+}
+
+// Setup reads the Kafka client configuration from the environment and stops the process
+// if it is invalid, so misconfiguration is reported at startup rather than on first use.
+func Setup() ClientConfig {
+	cfg := ClientConfigFromEnv()
+	if _, err := cfg.Build(); err != nil {
+		log.Fatalf("Invalid Kafka configuration: %v", err)
+	}
+	if cfg.SASLMechanism == SASLPlain && !cfg.TLSEnabled {
+		log.Println("Warning: SASL/PLAIN is used without TLS, credentials are sent in clear text")
+	}
+	return cfg
+}
+
+func (c ClientConfig) Build() (*sarama.Config, error) {
+	config := sarama.NewConfig()
+	config.ClientID = c.ClientID
+
+	version, err := sarama.ParseKafkaVersion(c.Version)
+	if err != nil {
+		return nil, fmt.Errorf("KAFKA_VERSION: %w", err)
+	}
+	config.Version = version
+
+	switch strings.ToLower(c.InitialOffset) {
+	case "oldest":
+		config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	case "newest":
+		config.Consumer.Offsets.Initial = sarama.OffsetNewest
+	default:
+		return nil, fmt.Errorf("KAFKA_INITIAL_OFFSET must be oldest or newest, got %q", c.InitialOffset)
+	}
+
+	switch strings.ToLower(c.RebalanceStrategy) {
+	case "range":
+		config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRange()}
+	case "roundrobin":
+		config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRoundRobin()}
+	case "sticky":
+		config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategySticky()}
+	default:
+		return nil, fmt.Errorf("KAFKA_REBALANCE_STRATEGY must be range, roundrobin or sticky, got %q", c.RebalanceStrategy)
+	}
+
+	if c.HeartbeatInterval*3 > c.SessionTimeout {
+		return nil, fmt.Errorf(
+			"KAFKA_HEARTBEAT_INTERVAL (%s) must be at most a third of KAFKA_SESSION_TIMEOUT (%s)",
+			c.HeartbeatInterval,
+			c.SessionTimeout,
+		)
+	}
+	config.Consumer.Group.Session.Timeout = c.SessionTimeout
+	config.Consumer.Group.Heartbeat.Interval = c.HeartbeatInterval
+	config.Consumer.Group.Rebalance.Timeout = c.RebalanceTimeout
+	config.Consumer.Fetch.Min = int32(c.FetchMinBytes)
+	config.Consumer.Fetch.Default = int32(c.FetchDefaultBytes)
+	config.Consumer.Fetch.Max = int32(c.FetchMaxBytes)
+	config.Consumer.MaxWaitTime = c.FetchMaxWait
+	config.Consumer.Return.Errors = true
+
+	if err := c.applySASL(config); err != nil {
+		return nil, err
+	}
+	if err := c.applyTLS(config); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c ClientConfig) applySASL(config *sarama.Config) error {
+	if c.SASLMechanism == "" {
+		return nil
+	}
+	if c.SASLUsername == "" || c.SASLPassword == "" {
+		return errors.New("KAFKA_SASL_USERNAME and KAFKA_SASL_PASSWORD are required with KAFKA_SASL_MECHANISM")
+	}
+
+	config.Net.SASL.Enable = true
+	config.Net.SASL.User = c.SASLUsername
+	config.Net.SASL.Password = c.SASLPassword
+	switch c.SASLMechanism {
+	case SASLPlain:
+		config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case SASLScramSHA256:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		config.Net.SASL.SCRAMClientGeneratorFunc = newSCRAMClient(sha256HashGenerator)
+	case SASLScramSHA512:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		config.Net.SASL.SCRAMClientGeneratorFunc = newSCRAMClient(sha512HashGenerator)
+	default:
+		return fmt.Errorf(
+			"KAFKA_SASL_MECHANISM must be %s, %s or %s, got %q",
+			SASLPlain,
+			SASLScramSHA256,
+			SASLScramSHA512,
+			c.SASLMechanism,
+		)
+	}
+	return nil
+}
+
+func (c ClientConfig) applyTLS(config *sarama.Config) error {
+	if !c.TLSEnabled {
+		if c.TLSCAFile != "" || c.TLSCertFile != "" || c.TLSKeyFile != "" {
+			return errors.New("KAFKA_TLS_* files are set, but KAFKA_TLS_ENABLED is false")
+		}
+		return nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.TLSServerName,
+		InsecureSkipVerify: c.TLSInsecureSkipVerify,
+	}
+	if c.TLSCAFile != "" {
+		pem, err := os.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return fmt.Errorf("KAFKA_TLS_CA_FILE: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("KAFKA_TLS_CA_FILE: no certificates found in %s", c.TLSCAFile)
+		}
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return errors.New("KAFKA_TLS_CERT_FILE and KAFKA_TLS_KEY_FILE must be set together")
+	}
+	if c.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("KAFKA_TLS_CERT_FILE: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	config.Net.TLS.Enable = true
+	config.Net.TLS.Config = tlsConfig
+	return nil
+}
@@ -0,0 +1,179 @@
+package kafka
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+func validClientConfig() ClientConfig {
+	return ClientConfig{
+		ClientID:          "wbts",
+		Version:           "2.8.0",
+		InitialOffset:     "newest",
+		RebalanceStrategy: "range",
+		SessionTimeout:    10 * time.Second,
+		HeartbeatInterval: 3 * time.Second,
+		RebalanceTimeout:  time.Minute,
+		FetchMinBytes:     1,
+		FetchDefaultBytes: 1 << 20,
+		FetchMaxWait:      500 * time.Millisecond,
+	}
+}
+
+// writeCertificate writes a self-signed certificate and its key as PEM files.
+func writeCertificate(t *testing.T) (certFile string, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kafka"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
+
+func TestBuildClientConfig(t *testing.T) {
+	cfg := validClientConfig()
+	cfg.InitialOffset = "OLDEST"
+	cfg.RebalanceStrategy = "sticky"
+	config, err := cfg.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if config.Consumer.Offsets.Initial != sarama.OffsetOldest || !config.Consumer.Return.Errors {
+		t.Errorf("consumer config = %+v", config.Consumer)
+	}
+	if config.Consumer.Group.Rebalance.GroupStrategies[0].Name() != sarama.StickyBalanceStrategyName {
+		t.Errorf("rebalance strategy = %s", config.Consumer.Group.Rebalance.GroupStrategies[0].Name())
+	}
+	if config.Net.SASL.Enable || config.Net.TLS.Enable {
+		t.Error("SASL or TLS enabled without being configured")
+	}
+
+	// Every call builds a config of its own, so clients cannot change each other's settings.
+	if other, _ := cfg.Build(); other == config {
+		t.Error("Build returned the same config twice")
+	}
+}
+
+func TestBuildClientConfigSecurity(t *testing.T) {
+	certFile, keyFile := writeCertificate(t)
+
+	cfg := validClientConfig()
+	cfg.SASLMechanism = SASLScramSHA512
+	cfg.SASLUsername, cfg.SASLPassword = "wbts", "secret"
+	cfg.TLSEnabled = true
+	cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile = certFile, certFile, keyFile
+	cfg.TLSServerName = "kafka"
+
+	config, err := cfg.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	sasl := config.Net.SASL
+	if !sasl.Enable || sasl.Mechanism != sarama.SASLTypeSCRAMSHA512 || sasl.SCRAMClientGeneratorFunc == nil {
+		t.Errorf("SASL = %+v", sasl)
+	}
+	tlsConfig := config.Net.TLS.Config
+	if !config.Net.TLS.Enable || tlsConfig.RootCAs == nil || len(tlsConfig.Certificates) != 1 ||
+		tlsConfig.ServerName != "kafka" || tlsConfig.InsecureSkipVerify {
+		t.Errorf("TLS = %+v", tlsConfig)
+	}
+
+	cfg.SASLMechanism = SASLPlain
+	if config, err := cfg.Build(); err != nil || config.Net.SASL.Mechanism != sarama.SASLTypePlaintext {
+		t.Errorf("PLAIN: %v", err)
+	}
+}
+
+func TestBuildClientConfigRejectsInvalidSettings(t *testing.T) {
+	certFile, _ := writeCertificate(t)
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0o600)
+
+	tests := []struct {
+		name    string
+		edit    func(cfg *ClientConfig)
+		wantErr string
+	}{
+		{"version", func(cfg *ClientConfig) { cfg.Version = "latest" }, "KAFKA_VERSION"},
+		{"initial offset", func(cfg *ClientConfig) { cfg.InitialOffset = "earliest" }, "KAFKA_INITIAL_OFFSET"},
+		{"rebalance strategy", func(cfg *ClientConfig) { cfg.RebalanceStrategy = "cooperative" }, "KAFKA_REBALANCE_STRATEGY"},
+		{"heartbeat too slow", func(cfg *ClientConfig) { cfg.HeartbeatInterval = 4 * time.Second }, "KAFKA_HEARTBEAT_INTERVAL"},
+		{"fetch min bytes", func(cfg *ClientConfig) { cfg.FetchMinBytes = 0 }, "Fetch.Min"},
+		{"SASL without password", func(cfg *ClientConfig) {
+			cfg.SASLMechanism, cfg.SASLUsername = SASLPlain, "wbts"
+		}, "KAFKA_SASL_PASSWORD"},
+		{"SASL mechanism", func(cfg *ClientConfig) {
+			cfg.SASLMechanism, cfg.SASLUsername, cfg.SASLPassword = "GSSAPI", "wbts", "secret"
+		}, "KAFKA_SASL_MECHANISM"},
+		{"TLS files without TLS", func(cfg *ClientConfig) { cfg.TLSCAFile = certFile }, "KAFKA_TLS_ENABLED"},
+		{"missing CA file", func(cfg *ClientConfig) {
+			cfg.TLSEnabled, cfg.TLSCAFile = true, filepath.Join(t.TempDir(), "missing.pem")
+		}, "KAFKA_TLS_CA_FILE"},
+		{"CA file without certificates", func(cfg *ClientConfig) { cfg.TLSEnabled, cfg.TLSCAFile = true, notPEM }, "no certificates"},
+		{"certificate without key", func(cfg *ClientConfig) { cfg.TLSEnabled, cfg.TLSCertFile = true, certFile }, "must be set together"},
+		{"certificate with a wrong key", func(cfg *ClientConfig) {
+			cfg.TLSEnabled, cfg.TLSCertFile, cfg.TLSKeyFile = true, certFile, notPEM
+		}, "KAFKA_TLS_CERT_FILE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validClientConfig()
+			tt.edit(&cfg)
+			_, err := cfg.Build()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Build = %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientConfigFromEnv(t *testing.T) {
+	t.Setenv("KAFKA_SASL_MECHANISM", "scram-sha-256")
+	t.Setenv("KAFKA_SESSION_TIMEOUT", "30s")
+	t.Setenv("KAFKA_FETCH_MAX_BYTES", "1048576")
+
+	cfg := ClientConfigFromEnv()
+	if cfg.SASLMechanism != SASLScramSHA256 {
+		t.Errorf("SASL mechanism = %q, want it upper-cased", cfg.SASLMechanism)
+	}
+	if cfg.SessionTimeout != 30*time.Second || cfg.FetchMaxBytes != 1<<20 {
+		t.Errorf("config = %+v", cfg)
+	}
+	if cfg.ClientID != "wbts" || cfg.InitialOffset != "newest" || cfg.HeartbeatInterval != 3*time.Second {
+		t.Errorf("defaults = %+v", cfg)
+	}
+}
@@ -1,90 +1,275 @@
 package kafka
 
 import (
-    "log"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
 
-    "github.com/IBM/sarama"
+	"github.com/IBM/sarama"
 	"github.com/go-playground/validator/v10"
 
 	"wbts/internal/domain/dto"
+	"wbts/internal/schema"
 )
 
+// MessageIDHeader carries a producer-assigned ID used to detect duplicate messages.
+const MessageIDHeader = "message-id"
+
 type OrderService interface {
-    Save(order dto.OrderDTO)
+	Save(order dto.OrderDTO, messageKey string) error
+	Get(order_uid string) (dto.OrderDTO, error)
 }
 
 type Consumer struct {
-	brokers []string
-    topic string
-	groupID string
-	orderService OrderService
-	validator *validator.Validate
+	brokers         []string
+	router          *TopicRouter
+	groupID         string
+	orderService    OrderService
+	validator       *validator.Validate
+	schemaValidator *schema.OrderValidator
+	decoder         *Decoder
+	pool            WorkerPoolConfig
+	config          ClientConfig
+	status          *consumerStatus
 }
 
-func NewConsumer(brokers []string, topic string, groupID string, orderService OrderService, validator *validator.Validate) *Consumer {
-    return &Consumer{brokers, topic, groupID, orderService, validator}
+func NewConsumer(
+	brokers []string,
+	router *TopicRouter,
+	groupID string,
+	orderService OrderService,
+	validator *validator.Validate,
+	schemaValidator *schema.OrderValidator,
+	decoder *Decoder,
+	pool WorkerPoolConfig,
+	config ClientConfig,
+) *Consumer {
+	return &Consumer{
+		brokers:         brokers,
+		router:          router,
+		groupID:         groupID,
+		orderService:    orderService,
+		validator:       validator,
+		schemaValidator: schemaValidator,
+		decoder:         decoder,
+		pool:            pool,
+		config:          config,
+		status:          newConsumerStatus(),
+	}
 }
 
 func (c *Consumer) Setup(session sarama.ConsumerGroupSession) error {
-    log.Printf("Start consuming topic: %s", c.topic)
-    return nil
+	log.Printf("Start consuming topics: %v", claimedTopics(session))
+	c.status.setHealthy()
+	return nil
 }
 
 func (c *Consumer) Cleanup(session sarama.ConsumerGroupSession) error {
-    log.Printf("Finish consuming topic: %s", c.topic)
-    return nil
+	log.Printf("Finish consuming topics: %v", claimedTopics(session))
+	return nil
 }
 
 func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-    for msg := range claim.Messages() {
-        log.Printf(
-            "Received message: Topic=%s, Partition=%d, Offset=%d, Key=%s, Value=%s\n",
-            msg.Topic,
-            msg.Partition,
-            msg.Offset,
-            string(msg.Key),
-            string(msg.Value),
-        )
-		
-		var order dto.OrderDTO 
-		if err := json.Unmarshal([]byte(msg.Value), &order); err != nil {
-			log.Printf("Error parsing message: %v", err)
-		}
+	c.consumePartition(session, claim)
+	return nil
+}
+
+func claimedTopics(session sarama.ConsumerGroupSession) []string {
+	topics := make([]string, 0, len(session.Claims()))
+	for topic := range session.Claims() {
+		topics = append(topics, topic)
+	}
+	slices.Sort(topics)
+	return topics
+}
 
+// decodeOrder runs a message through decoding, schema validation with upcasting and struct
+// validation, in the order the consumer applies them, using the validation profile of the
+// message's topic. The order is assigned to the topic's tenant whatever the payload says.
+func (c *Consumer) decodeOrder(ctx context.Context, msg *sarama.ConsumerMessage) (dto.OrderDTO, error) {
+	route, ok := c.router.Route(msg.Topic)
+	if !ok {
+		return dto.OrderDTO{}, fmt.Errorf("topic %s has no tenant route", msg.Topic)
+	}
+
+	document, format, err := c.decoder.Decode(ctx, msg)
+	if err != nil {
+		return dto.OrderDTO{}, fmt.Errorf("decode %s message: %w", format, err)
+	}
+
+	payload, version, err := c.schemaValidator.Validate(document, headerValue(msg, schema.VersionHeader))
+	if err != nil {
+		return dto.OrderDTO{}, err
+	}
+	if version < route.Profile.MinSchemaVersion {
+		return dto.OrderDTO{}, fmt.Errorf(
+			"schema v%d is below v%d required by the %s profile of topic %s",
+			version, route.Profile.MinSchemaVersion, route.Profile.Name, msg.Topic,
+		)
+	}
+	if version < schema.LatestVersion {
+		log.Printf("Upcasted message from schema v%d to v%d", version, schema.LatestVersion)
+	}
+
+	var order dto.OrderDTO
+	if err := json.Unmarshal(payload, &order); err != nil {
+		return dto.OrderDTO{}, fmt.Errorf("parse message: %w", err)
+	}
+	if route.Profile.StructValidation {
 		if err := c.validator.Struct(order); err != nil {
-			for _, err := range err.(validator.ValidationErrors) {
-            	log.Printf(
-					"Message can't be processed. Field validation error: Field '%s' failed on the '%s' tag\n", 
-					err.Field(), 
-					err.Tag(),
-				)
-        	}
-		} else {
-			c.orderService.Save(order)
+			return dto.OrderDTO{}, err
+		}
+	}
+	order.Tenant = route.Tenant
+	return order, nil
+}
+
+// messageKey is the ledger key of a message: the producer's message ID if it sets the header,
+// so retried sends are detected as well, or the message's position in the topic otherwise.
+func messageKey(msg *sarama.ConsumerMessage) string {
+	if id := headerValue(msg, MessageIDHeader); id != "" {
+		return "id:" + id
+	}
+	return fmt.Sprintf("offset:%s/%d/%d", msg.Topic, msg.Partition, msg.Offset)
+}
+
+func headerValue(msg *sarama.ConsumerMessage, key string) string {
+	for _, header := range msg.Headers {
+		if string(header.Key) == key {
+			return string(header.Value)
 		}
+	}
+	return ""
+}
 
-        session.MarkMessage(msg, "")
-		session.Commit()
-    }
-    return nil
+func logProcessingError(err error) {
+	var schemaErr *schema.ValidationError
+	var fieldErrs validator.ValidationErrors
+	switch {
+	case errors.As(err, &schemaErr):
+		for _, cause := range schemaErr.Causes() {
+			log.Printf("Message can't be processed. Schema v%d validation error: %s", schemaErr.Version, cause)
+		}
+	case errors.As(err, &fieldErrs):
+		for _, err := range fieldErrs {
+			log.Printf(
+				"Message can't be processed. Field validation error: Field '%s' failed on the '%s' tag\n",
+				err.Field(),
+				err.Tag(),
+			)
+		}
+	default:
+		log.Printf("Message can't be processed: %v", err)
+	}
 }
 
+// Run consumes until ctx is cancelled. Broker and group failures never stop the process:
+// the consumer is marked degraded and the group is recreated with exponential backoff.
 func (c *Consumer) Run(ctx context.Context) {
-    consumerGroup, err := sarama.NewConsumerGroup(c.brokers, c.groupID, nil)
-    if err != nil {
-        log.Fatalf("Error creating consumer group client: %v", err)
-    }
-    defer consumerGroup.Close()
-
-    for {
-        err := consumerGroup.Consume(ctx, []string{c.topic}, c)
-        if err != nil {
-            log.Fatalf("Error from consumer: %v", err)
-        }
-        if ctx.Err() != nil {
-            return
-        }
-    }
+	config, err := c.config.Build()
+	if err != nil {
+		log.Fatalf("Error building consumer config: %v", err)
+	}
+
+	backoff := reconnectBackoffMin
+	for {
+		started := time.Now()
+		err := c.consume(ctx, config)
+		if ctx.Err() != nil {
+			return
+		}
+
+		c.status.setDegraded(err)
+		consumerStats.Add("reconnects", 1)
+		if time.Since(started) > reconnectBackoffMax {
+			backoff = reconnectBackoffMin
+		}
+		var sleep time.Duration
+		sleep, backoff = nextBackoff(backoff)
+		log.Printf("Kafka consumer is degraded: %v. Reconnecting in %s", err, sleep)
+
+		select {
+		case <-time.After(sleep):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Consumer) consume(ctx context.Context, config *sarama.Config) error {
+	client, err := sarama.NewClient(c.brokers, config)
+	if err != nil {
+		return fmt.Errorf("create client: %w", err)
+	}
+	defer client.Close()
+
+	consumerGroup, err := sarama.NewConsumerGroupFromClient(c.groupID, client)
+	if err != nil {
+		return fmt.Errorf("create consumer group: %w", err)
+	}
+	defer consumerGroup.Close()
+
+	// Errors the group recovers from on its own (failed fetches, offset commits) do not end
+	// the session, so they only mark the consumer degraded until messages arrive again.
+	go func() {
+		for err := range consumerGroup.Errors() {
+			consumerStats.Add("errors", 1)
+			c.status.setDegraded(fmt.Errorf("consumer group: %w", err))
+			log.Printf("Error from consumer group: %v", err)
+		}
+	}()
+
+	for {
+		topics, err := c.router.Topics(client)
+		if err != nil {
+			return err
+		}
+
+		sessionCtx, cancel := context.WithCancel(ctx)
+		if c.router.Dynamic() {
+			go c.watchTopics(sessionCtx, cancel, client, topics)
+		}
+		err = consumerGroup.Consume(sessionCtx, topics, c)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("consume: %w", err)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// watchTopics ends the current session once the set of topics matching the pattern changes,
+// so the consumer rejoins the group with the new subscription.
+func (c *Consumer) watchTopics(ctx context.Context, cancel context.CancelFunc, client sarama.Client, current []string) {
+	ticker := time.NewTicker(c.router.refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			topics, err := c.router.Topics(client)
+			if err != nil {
+				log.Printf("Error refreshing subscribed topics: %v", err)
+				continue
+			}
+			if !slices.Equal(topics, current) {
+				log.Printf("Subscribed topics changed from %v to %v", current, topics)
+				cancel()
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Healthy returns nil while the consumer has a working group session.
+func (c *Consumer) Healthy(ctx context.Context) error {
+	return c.status.err()
 }
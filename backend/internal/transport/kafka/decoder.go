@@ -0,0 +1,182 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/hamba/avro/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	ordersv1 "wbts/api/orders/v1"
+	"wbts/internal/registry"
+)
+
+const contentTypeHeader = "content-type"
+
+var ErrRegistryDisabled = errors.New("binary message received, but schema registry is not configured")
+
+// Decoder turns JSON, Avro and Protobuf order messages into JSON documents for schema
+// validation. Binary messages use the Confluent wire format; the format comes from the
+// content-type header, or from the registered schema type when the header is absent.
+type Decoder struct {
+	registry    *registry.Client
+	avroSchemas map[int]avro.Schema
+	mtx         sync.Mutex
+}
+
+func NewDecoder(registry *registry.Client) *Decoder {
+	return &Decoder{registry: registry, avroSchemas: make(map[int]avro.Schema)}
+}
+
+func (d *Decoder) Decode(ctx context.Context, msg *sarama.ConsumerMessage) ([]byte, string, error) {
+	format := messageFormat(headerValue(msg, contentTypeHeader))
+	if format == registry.TypeJSON || (format == "" && !registry.IsWireFormat(msg.Value)) {
+		return msg.Value, registry.TypeJSON, nil
+	}
+	if d.registry == nil {
+		return nil, format, ErrRegistryDisabled
+	}
+
+	schemaID, payload, err := registry.ParseWireFormat(msg.Value)
+	if err != nil {
+		return nil, format, err
+	}
+	schema, err := d.registry.SchemaByID(ctx, schemaID)
+	if err != nil {
+		return nil, format, err
+	}
+	if format == "" {
+		format = schema.SchemaType
+	}
+	if format != schema.SchemaType {
+		return nil, format, fmt.Errorf("message declares %s, but schema id=%d is %s", format, schemaID, schema.SchemaType)
+	}
+
+	var doc any
+	switch format {
+	case registry.TypeAvro:
+		doc, err = d.decodeAvro(schema, payload)
+	case registry.TypeProtobuf:
+		doc, err = decodeProtobuf(schema, payload)
+	default:
+		err = fmt.Errorf("unsupported schema type %s", format)
+	}
+	if err != nil {
+		return nil, format, fmt.Errorf("decode %s order with schema id=%d: %w", format, schemaID, err)
+	}
+
+	decoded, err := json.Marshal(doc)
+	return decoded, format, err
+}
+
+func messageFormat(contentType string) string {
+	contentType, _, _ = strings.Cut(contentType, ";")
+	switch strings.TrimSpace(strings.ToLower(contentType)) {
+	case "application/json":
+		return registry.TypeJSON
+	case "application/avro", "avro/binary", "application/vnd.apache.avro+binary":
+		return registry.TypeAvro
+	case "application/protobuf", "application/x-protobuf", "application/vnd.google.protobuf":
+		return registry.TypeProtobuf
+	default:
+		return ""
+	}
+}
+
+func (d *Decoder) decodeAvro(schema registry.Schema, payload []byte) (any, error) {
+	d.mtx.Lock()
+	writerSchema, ok := d.avroSchemas[schema.ID]
+	d.mtx.Unlock()
+	if !ok {
+		parsed, err := avro.Parse(schema.Schema)
+		if err != nil {
+			return nil, err
+		}
+		writerSchema = parsed
+
+		d.mtx.Lock()
+		d.avroSchemas[schema.ID] = writerSchema
+		d.mtx.Unlock()
+	}
+
+	var doc any
+	if err := avro.Unmarshal(writerSchema, payload, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// decodeProtobuf only accepts schemas registered from orders.proto; the message index path
+// is resolved against the compiled descriptor and has to point at orders.v1.Order.
+func decodeProtobuf(schema registry.Schema, payload []byte) (any, error) {
+	fd := ordersv1.File_orders_v1_orders_proto
+	if !strings.Contains(schema.Schema, "package "+string(fd.Package())+";") {
+		return nil, fmt.Errorf("schema is not a %s protobuf schema", fd.Package())
+	}
+
+	indexes, payload, err := registry.ParseMessageIndexes(payload)
+	if err != nil {
+		return nil, err
+	}
+	messages := fd.Messages()
+	var md protoreflect.MessageDescriptor
+	for _, index := range indexes {
+		if index >= messages.Len() {
+			return nil, fmt.Errorf("message index %v is out of range", indexes)
+		}
+		md = messages.Get(index)
+		messages = md.Messages()
+	}
+	order := &ordersv1.Order{}
+	if md.FullName() != order.ProtoReflect().Descriptor().FullName() {
+		return nil, fmt.Errorf("message %s is not an order", md.FullName())
+	}
+
+	if err := proto.Unmarshal(payload, order); err != nil {
+		return nil, err
+	}
+	return protoToDocument(order.ProtoReflect()), nil
+}
+
+// protoToDocument keeps exact field values, so the JSON schema sees the same numbers the
+// producer sent. Unset message fields are omitted, scalars are always present.
+func protoToDocument(msg protoreflect.Message) map[string]any {
+	doc := make(map[string]any)
+	fields := msg.Descriptor().Fields()
+	for i := range fields.Len() {
+		field := fields.Get(i)
+		name := string(field.Name())
+
+		switch {
+		case field.IsList():
+			list := msg.Get(field).List()
+			values := make([]any, 0, list.Len())
+			for j := range list.Len() {
+				values = append(values, protoValue(field, list.Get(j)))
+			}
+			doc[name] = values
+		case field.Message() != nil && !msg.Has(field):
+		default:
+			doc[name] = protoValue(field, msg.Get(field))
+		}
+	}
+	return doc
+}
+
+func protoValue(field protoreflect.FieldDescriptor, value protoreflect.Value) any {
+	if field.Message() == nil {
+		return value.Interface()
+	}
+	if ts, ok := value.Message().Interface().(*timestamppb.Timestamp); ok {
+		return ts.AsTime().Format(time.RFC3339Nano)
+	}
+	return protoToDocument(value.Message())
+}
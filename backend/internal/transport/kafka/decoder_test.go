@@ -0,0 +1,206 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/hamba/avro/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	ordersv1 "wbts/api/orders/v1"
+	"wbts/internal/registry"
+	"wbts/internal/registry/registrytest"
+)
+
+// orderMessageIndex is the position of orders.v1.Order among the messages of orders.proto.
+const orderMessageIndex = 3
+
+func newTestDecoder(t *testing.T) (*Decoder, *registrytest.Server) {
+	t.Helper()
+	server := registrytest.NewServer()
+	t.Cleanup(server.Close)
+	return NewDecoder(registry.NewClient(server.URL, &http.Client{Timeout: time.Second})), server
+}
+
+func binaryMessage(contentType string, value []byte) *sarama.ConsumerMessage {
+	msg := &sarama.ConsumerMessage{Value: value}
+	if contentType != "" {
+		msg.Headers = []*sarama.RecordHeader{{Key: []byte(contentTypeHeader), Value: []byte(contentType)}}
+	}
+	return msg
+}
+
+func avroOrder(t *testing.T, schemaID int) []byte {
+	t.Helper()
+	schema, err := avro.Parse(ordersv1.AvroSchema)
+	if err != nil {
+		t.Fatalf("parse avro schema: %v", err)
+	}
+	payload, err := avro.Marshal(schema, map[string]any{
+		"order_uid":    "b563feb7b2b84b6test",
+		"track_number": "WBILMTESTTRACK",
+		"entry":        "WBIL",
+		"delivery": map[string]any{
+			"name": "Test Testov", "phone": "+9720000000", "zip": "2639809", "city": "Kiryat Mozkin",
+			"address": "Ploshad Mira 15", "region": "Kraiot", "email": "test@gmail.com",
+		},
+		"payment": map[string]any{
+			"transaction": "b563feb7b2b84b6test", "request_id": "", "currency": "USD", "provider": "wbpay",
+			"amount": int64(1817), "payment_dt": int64(1637907727), "bank": "alpha",
+			"delivery_cost": int64(1500), "goods_total": int64(317), "custom_fee": int64(0),
+		},
+		"items": []any{map[string]any{
+			"chrt_id": int64(9934930), "track_number": "WBILMTESTTRACK", "price": int64(453),
+			"rid": "ab4219087a764ae0btest", "name": "Mascaras", "sale": 30, "size": "0",
+			"total_price": int64(317), "nm_id": int64(2389212), "brand": "Vivienne Sabo", "status": int64(202),
+		}},
+		"locale":             "en",
+		"internal_signature": "",
+		"customer_id":        "test",
+		"delivery_service":   "meest",
+		"shardkey":           "9",
+		"sm_id":              int64(99),
+		"date_created":       time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		"oof_shard":          "1",
+	})
+	if err != nil {
+		t.Fatalf("encode avro order: %v", err)
+	}
+	return registry.AppendWireFormat(nil, schemaID, payload)
+}
+
+func protobufOrder(t *testing.T, schemaID int, indexes []byte) []byte {
+	t.Helper()
+	payload, err := proto.Marshal(&ordersv1.Order{
+		OrderUid:    "b563feb7b2b84b6test",
+		Entry:       "WBIL",
+		Delivery:    &ordersv1.Delivery{Name: "Test Testov", City: "Kiryat Mozkin"},
+		Items:       []*ordersv1.Item{{ChrtId: 9934930, Price: 453}},
+		CustomerId:  "test",
+		SmId:        99,
+		DateCreated: timestamppb.New(time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)),
+	})
+	if err != nil {
+		t.Fatalf("encode protobuf order: %v", err)
+	}
+	return registry.AppendWireFormat(nil, schemaID, append(indexes, payload...))
+}
+
+func decodeDocument(t *testing.T, decoder *Decoder, msg *sarama.ConsumerMessage, wantFormat string) map[string]any {
+	t.Helper()
+	decoded, format, err := decoder.Decode(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if format != wantFormat {
+		t.Errorf("format = %s, want %s", format, wantFormat)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(decoded, &doc); err != nil {
+		t.Fatalf("decoded document is not JSON: %v", err)
+	}
+	return doc
+}
+
+func TestDecodeAvro(t *testing.T) {
+	decoder, server := newTestDecoder(t)
+	schemaID := server.Add("orders-value", registry.Schema{SchemaType: registry.TypeAvro, Schema: ordersv1.AvroSchema})
+
+	for _, contentType := range []string{"application/avro", ""} {
+		doc := decodeDocument(t, decoder, binaryMessage(contentType, avroOrder(t, schemaID)), registry.TypeAvro)
+		if doc["order_uid"] != "b563feb7b2b84b6test" {
+			t.Errorf("order_uid = %v", doc["order_uid"])
+		}
+		if delivery, _ := doc["delivery"].(map[string]any); delivery["city"] != "Kiryat Mozkin" {
+			t.Errorf("delivery = %v", doc["delivery"])
+		}
+	}
+}
+
+func TestDecodeProtobuf(t *testing.T) {
+	decoder, server := newTestDecoder(t)
+	schemaID := server.Add("orders-value", registry.Schema{SchemaType: registry.TypeProtobuf, Schema: ordersv1.ProtoSchema})
+
+	indexes := registry.AppendMessageIndexes(nil, []int{orderMessageIndex})
+	doc := decodeDocument(t, decoder, binaryMessage("application/x-protobuf", protobufOrder(t, schemaID, indexes)), registry.TypeProtobuf)
+	if doc["order_uid"] != "b563feb7b2b84b6test" || doc["customer_id"] != "test" {
+		t.Errorf("decoded order = %v", doc)
+	}
+	if items, _ := doc["items"].([]any); len(items) != 1 {
+		t.Errorf("items = %v", doc["items"])
+	}
+}
+
+func TestDecodeRejectsMalformedMessages(t *testing.T) {
+	decoder, server := newTestDecoder(t)
+	avroID := server.Add("orders-value", registry.Schema{SchemaType: registry.TypeAvro, Schema: ordersv1.AvroSchema})
+	protoID := server.Add("orders-proto-value", registry.Schema{SchemaType: registry.TypeProtobuf, Schema: ordersv1.ProtoSchema})
+
+	orderIndex := registry.AppendMessageIndexes(nil, []int{orderMessageIndex})
+	tests := []struct {
+		name        string
+		contentType string
+		value       []byte
+		wantErr     error
+	}{
+		{"truncated header", "application/avro", []byte{registry.MagicByte, 0, 0}, registry.ErrInvalidWireFormat},
+		{"wrong magic byte", "application/avro", []byte{7, 0, 0, 0, 1, 2, 3}, registry.ErrInvalidWireFormat},
+		{"unknown schema id", "application/avro", avroOrder(t, 999), registry.ErrSchemaNotFound},
+		{"garbage avro body", "application/avro", registry.AppendWireFormat(nil, avroID, []byte{0xff, 0xff, 0xff, 0xff, 0x0f}), nil},
+		{"truncated avro body", "application/avro", avroOrder(t, avroID)[:20], nil},
+		{"format mismatch", "application/avro", protobufOrder(t, protoID, orderIndex), nil},
+		{
+			"oversized index count", "application/x-protobuf",
+			registry.AppendWireFormat(nil, protoID, binary.AppendVarint(nil, 1<<40)), registry.ErrInvalidWireFormat,
+		},
+		{
+			"index count beyond payload", "application/x-protobuf",
+			registry.AppendWireFormat(nil, protoID, append(binary.AppendVarint(nil, 100), 6, 6)), registry.ErrInvalidWireFormat,
+		},
+		{
+			"message index out of range", "application/x-protobuf",
+			protobufOrder(t, protoID, registry.AppendMessageIndexes(nil, []int{99})), nil,
+		},
+		{
+			"index of another message", "application/x-protobuf",
+			protobufOrder(t, protoID, registry.AppendMessageIndexes(nil, []int{1})), nil,
+		},
+		{
+			"garbage protobuf body", "application/x-protobuf",
+			registry.AppendWireFormat(nil, protoID, append(orderIndex, 0xff, 0xff, 0xff)), nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := decoder.Decode(context.Background(), binaryMessage(tt.contentType, tt.value))
+			if err == nil {
+				t.Fatal("Decode succeeded, want an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeWithoutRegistry(t *testing.T) {
+	decoder := NewDecoder(nil)
+
+	msg := binaryMessage("", []byte(`{"order_uid":"a"}`))
+	decoded, format, err := decoder.Decode(context.Background(), msg)
+	if err != nil || format != registry.TypeJSON || string(decoded) != string(msg.Value) {
+		t.Errorf("JSON message = %q, %s, %v", decoded, format, err)
+	}
+
+	if _, _, err := decoder.Decode(context.Background(), binaryMessage("application/avro", avroOrder(t, 1))); !errors.Is(err, ErrRegistryDisabled) {
+		t.Errorf("error = %v, want ErrRegistryDisabled", err)
+	}
+}
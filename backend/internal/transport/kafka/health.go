@@ -0,0 +1,75 @@
+package kafka
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+const (
+	reconnectBackoffMin = time.Second
+	reconnectBackoffMax = 30 * time.Second
+)
+
+var consumerStats = expvar.NewMap("kafka_consumer")
+
+// consumerStatus tracks whether the consumer currently has a working group session.
+// The consumer starts degraded and becomes healthy once the first session is set up.
+type consumerStatus struct {
+	degraded bool
+	lastErr  error
+	since    time.Time
+	mtx      sync.Mutex
+}
+
+func newConsumerStatus() *consumerStatus {
+	consumerStats.Set("degraded", expvarInt(1))
+	return &consumerStatus{degraded: true, lastErr: errors.New("not connected yet"), since: time.Now()}
+}
+
+func (s *consumerStatus) setHealthy() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.degraded {
+		s.degraded, s.lastErr, s.since = false, nil, time.Now()
+		consumerStats.Set("degraded", expvarInt(0))
+	}
+}
+
+func (s *consumerStatus) setDegraded(err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if !s.degraded {
+		s.since = time.Now()
+	}
+	s.degraded, s.lastErr = true, err
+	consumerStats.Set("degraded", expvarInt(1))
+}
+
+func (s *consumerStatus) err() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if !s.degraded {
+		return nil
+	}
+	return fmt.Errorf("degraded since %s: %w", s.since.Format(time.RFC3339), s.lastErr)
+}
+
+func expvarInt(v int64) *expvar.Int {
+	i := new(expvar.Int)
+	i.Set(v)
+	return i
+}
+
+// nextBackoff doubles the delay up to reconnectBackoffMax and returns a sleep with jitter
+// in [backoff/2, backoff], so replicas do not reconnect in lockstep.
+func nextBackoff(backoff time.Duration) (time.Duration, time.Duration) {
+	sleep := backoff/2 + rand.N(backoff/2+1)
+	return sleep, min(backoff*2, reconnectBackoffMax)
+}
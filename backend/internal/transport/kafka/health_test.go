@@ -0,0 +1,139 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"strings"
+	"testing"
+	"time"
+)
+
+func degradedStat() string {
+	if v := consumerStats.Get("degraded"); v != nil {
+		return v.String()
+	}
+	return ""
+}
+
+func TestConsumerStatus(t *testing.T) {
+	status := newConsumerStatus()
+	if err := status.err(); err == nil || !strings.Contains(err.Error(), "not connected yet") {
+		t.Fatalf("new status err = %v, want not connected yet", err)
+	}
+	if got := degradedStat(); got != "1" {
+		t.Errorf("degraded stat = %s, want 1", got)
+	}
+
+	status.setHealthy()
+	if err := status.err(); err != nil {
+		t.Fatalf("err after setHealthy = %v", err)
+	}
+	if got := degradedStat(); got != "0" {
+		t.Errorf("degraded stat = %s, want 0", got)
+	}
+
+	// Repeated errors replace the reported error but keep the time the consumer degraded.
+	first, second := errors.New("broker down"), errors.New("still down")
+	status.setDegraded(first)
+	since := status.since
+	time.Sleep(10 * time.Millisecond)
+	status.setDegraded(second)
+	err := status.err()
+	if !errors.Is(err, second) || errors.Is(err, first) {
+		t.Errorf("err = %v, want it to wrap the last error only", err)
+	}
+	if !status.since.Equal(since) {
+		t.Errorf("since moved from %s to %s on a repeated error", since, status.since)
+	}
+	if got := degradedStat(); got != "1" {
+		t.Errorf("degraded stat = %s, want 1", got)
+	}
+
+	status.setHealthy()
+	status.setDegraded(first)
+	if !status.since.After(since) {
+		t.Error("since was not reset after the consumer recovered")
+	}
+}
+
+func TestNextBackoff(t *testing.T) {
+	backoff := reconnectBackoffMin
+	for range 10 {
+		var sleep time.Duration
+		want := min(backoff*2, reconnectBackoffMax)
+		prev := backoff
+		sleep, backoff = nextBackoff(backoff)
+		if sleep < prev/2 || sleep > prev {
+			t.Errorf("sleep for %s = %s, want within [%s, %s]", prev, sleep, prev/2, prev)
+		}
+		if backoff != want {
+			t.Errorf("backoff after %s = %s, want %s", prev, backoff, want)
+		}
+	}
+	if backoff != reconnectBackoffMax {
+		t.Errorf("backoff = %s, want it capped at %s", backoff, reconnectBackoffMax)
+	}
+}
+
+func TestConsumerHealth(t *testing.T) {
+	consumer := newTestConsumer(t, newFakeOrderService(), WorkerPoolConfig{Workers: 1, QueueSize: 1})
+	if err := consumer.Healthy(context.Background()); err == nil {
+		t.Fatal("consumer is healthy before its first session")
+	}
+
+	if err := consumer.Setup(newFakeSession(context.Background(), 0)); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := consumer.Healthy(context.Background()); err != nil {
+		t.Fatalf("Healthy after Setup = %v", err)
+	}
+
+	// A group error degrades the consumer until messages are dispatched again.
+	consumer.status.setDegraded(errors.New("fetch failed"))
+	if err := consumer.Healthy(context.Background()); err == nil {
+		t.Fatal("consumer is healthy after a group error")
+	}
+	claim := newFakeClaim(0, orderMessage(t, 0, 1, testOrder("a")))
+	waitDone(t, runPartition(consumer, newFakeSession(context.Background(), 0), claim))
+	if err := consumer.Healthy(context.Background()); err != nil {
+		t.Errorf("Healthy after dispatching a message = %v", err)
+	}
+}
+
+func TestRunReconnects(t *testing.T) {
+	consumer := newTestConsumer(t, newFakeOrderService(), WorkerPoolConfig{Workers: 1, QueueSize: 1})
+	consumer.config = validClientConfig()
+	reconnects := func() int64 {
+		if v, ok := consumerStats.Get("reconnects").(*expvar.Int); ok {
+			return v.Value()
+		}
+		return 0
+	}
+	before := reconnects()
+
+	// Without brokers every attempt fails: the consumer stays up, degraded, and retries.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consumer.Run(ctx)
+	}()
+	deadline := time.Now().Add(10 * time.Second)
+	for reconnects() == before {
+		if time.Now().After(deadline) {
+			t.Fatal("Run did not reconnect after a failed attempt")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := consumer.Healthy(ctx); err == nil || !strings.Contains(err.Error(), "create client") {
+		t.Errorf("Healthy = %v, want the client error", err)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+}
@@ -0,0 +1,183 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"wbts/internal/domain/dto"
+	"wbts/internal/domain/entity"
+)
+
+type WorkerPoolConfig struct {
+	Workers   int
+	QueueSize int
+}
+
+type job struct {
+	msg   *sarama.ConsumerMessage
+	order dto.OrderDTO
+}
+
+// offsetTracker marks offsets in the session only up to the lowest offset that is not yet
+// processed, so a crash never commits past a message that was still in flight.
+type offsetTracker struct {
+	session   sarama.ConsumerGroupSession
+	topic     string
+	partition int32
+	inflight  []int64
+	completed map[int64]bool
+	mtx       sync.Mutex
+}
+
+func newOffsetTracker(session sarama.ConsumerGroupSession, topic string, partition int32) *offsetTracker {
+	return &offsetTracker{session: session, topic: topic, partition: partition, completed: make(map[int64]bool)}
+}
+
+func (t *offsetTracker) add(offset int64) {
+	t.mtx.Lock()
+	t.inflight = append(t.inflight, offset)
+	t.mtx.Unlock()
+}
+
+func (t *offsetTracker) complete(offset int64) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.completed[offset] = true
+	advanced := false
+	for len(t.inflight) > 0 && t.completed[t.inflight[0]] {
+		delete(t.completed, t.inflight[0])
+		offset, t.inflight = t.inflight[0], t.inflight[1:]
+		advanced = true
+	}
+	if advanced {
+		t.session.MarkOffset(t.topic, t.partition, offset+1, "")
+	}
+}
+
+// consumePartition processes a claim with a pool of workers. Orders are routed to workers by
+// order_uid, so updates of one order are applied in partition order, and each worker queue
+// is bounded, which blocks the claim instead of buffering the whole partition in memory.
+func (c *Consumer) consumePartition(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) {
+	ctx := session.Context()
+	tracker := newOffsetTracker(session, claim.Topic(), claim.Partition())
+
+	queues := make([]chan job, c.pool.Workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan job, c.pool.QueueSize)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.work(ctx, queues[i], tracker)
+		}()
+	}
+
+	dispatch := func(msg *sarama.ConsumerMessage) bool {
+		log.Printf(
+			"Received message: Topic=%s, Partition=%d, Offset=%d, Key=%s, Value=%s\n",
+			msg.Topic,
+			msg.Partition,
+			msg.Offset,
+			string(msg.Key),
+			string(msg.Value),
+		)
+		c.status.setHealthy()
+		tracker.add(msg.Offset)
+
+		order, err := c.decodeOrder(ctx, msg)
+		if err != nil {
+			logProcessingError(err)
+			tracker.complete(msg.Offset)
+			return true
+		}
+
+		select {
+		case queues[workerIndex(order.OrderUID, len(queues))] <- job{msg, order}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for msg := range claim.Messages() {
+		if !dispatch(msg) {
+			break
+		}
+	}
+
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+	session.Commit()
+}
+
+func (c *Consumer) work(ctx context.Context, queue <-chan job, tracker *offsetTracker) {
+	for j := range queue {
+		// After a rebalance the partition may belong to another member; leave the rest
+		// uncommitted so it is redelivered there.
+		if ctx.Err() != nil {
+			continue
+		}
+
+		if c.save(ctx, j) {
+			tracker.complete(j.msg.Offset)
+		}
+	}
+}
+
+// save retries failed saves with backoff until the order is stored or the session ends, and
+// reports whether the offset may be marked: only stored, duplicate and permanently invalid
+// messages are done. A message left unmarked holds the watermark back and is redelivered.
+func (c *Consumer) save(ctx context.Context, j job) bool {
+	backoff := reconnectBackoffMin
+	for {
+		err := c.orderService.Save(j.order, messageKey(j.msg))
+		switch {
+		case err == nil:
+			return true
+		case errors.Is(err, entity.ErrMessageProcessed):
+			consumerStats.Add("duplicates", 1)
+			log.Printf("Skipped already processed message: Topic=%s, Partition=%d, Offset=%d", j.msg.Topic, j.msg.Partition, j.msg.Offset)
+			return true
+		case errors.Is(err, entity.ErrInvalidOrder):
+			consumerStats.Add("rejected", 1)
+			log.Printf("Message can't be processed. Order with uid=%s was rejected: %v", j.order.OrderUID, err)
+			return true
+		}
+
+		var sleep time.Duration
+		sleep, backoff = nextBackoff(backoff)
+		consumerStats.Add("save_retries", 1)
+		log.Printf("Error saving order with uid=%s, retrying in %s: %v", j.order.OrderUID, sleep, err)
+
+		timer := time.NewTimer(sleep)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		}
+	}
+}
+
+func workerIndex(key string, workers int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(workers))
+}
+
+func (c WorkerPoolConfig) Validate() error {
+	if c.Workers < 1 || c.QueueSize < 1 {
+		return fmt.Errorf("worker pool needs at least one worker and a queue size of at least 1, got %d and %d", c.Workers, c.QueueSize)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"wbts/internal/domain/dto"
+)
+
+func TestOffsetTrackerWatermark(t *testing.T) {
+	tests := []struct {
+		name      string
+		offsets   []int64
+		completed []int64
+		// watermark is the marked offset after each completion, -1 while nothing is marked.
+		watermark []int64
+	}{
+		{"in order", []int64{0, 1, 2}, []int64{0, 1, 2}, []int64{1, 2, 3}},
+		{"out of order", []int64{0, 1, 2}, []int64{2, 0, 1}, []int64{-1, 1, 3}},
+		{"reverse", []int64{5, 6, 7}, []int64{7, 6, 5}, []int64{-1, -1, 8}},
+		{"gaps between offsets", []int64{3, 7, 9}, []int64{7, 3, 9}, []int64{-1, 8, 10}},
+		{"lowest still in flight", []int64{0, 1, 2}, []int64{1, 2}, []int64{-1, -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := newFakeSession(context.Background(), 0)
+			tracker := newOffsetTracker(session, testTopic, 0)
+			for _, offset := range tt.offsets {
+				tracker.add(offset)
+			}
+			for i, offset := range tt.completed {
+				tracker.complete(offset)
+				if got := session.offset(0); got != tt.watermark[i] {
+					t.Fatalf("after completing %d the watermark is %d, want %d", offset, got, tt.watermark[i])
+				}
+			}
+		})
+	}
+}
+
+// runPartition consumes the claim in the background and reports when consumePartition returns.
+func runPartition(consumer *Consumer, session *fakeSession, claim *fakeClaim) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consumer.consumePartition(session, claim)
+	}()
+	return done
+}
+
+func waitDone(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("consumePartition did not return")
+	}
+}
+
+func TestPoolKeepsPerKeyOrder(t *testing.T) {
+	var mtx sync.Mutex
+	saved := make(map[string][]int64)
+	service := newFakeOrderService()
+	service.save = func(order dto.OrderDTO) error {
+		// Give other workers a chance to overtake.
+		time.Sleep(time.Millisecond)
+		offset, _ := strconv.ParseInt(order.TrackNumber, 10, 64)
+		mtx.Lock()
+		saved[order.OrderUID] = append(saved[order.OrderUID], offset)
+		mtx.Unlock()
+		return nil
+	}
+	consumer := newTestConsumer(t, service, WorkerPoolConfig{Workers: 4, QueueSize: 2})
+
+	// Five orders are updated in turn; the track number carries the offset of the update.
+	var msgs []*sarama.ConsumerMessage
+	for offset := int64(0); offset < 40; offset++ {
+		order := testOrder(fmt.Sprintf("order-%d", offset%5))
+		order.TrackNumber = strconv.FormatInt(offset, 10)
+		if offset == 17 {
+			order.Entry = ""
+		}
+		msgs = append(msgs, orderMessage(t, 0, offset, order))
+	}
+	session := newFakeSession(context.Background(), 0)
+	waitDone(t, runPartition(consumer, session, newFakeClaim(0, msgs...)))
+
+	for uid, offsets := range saved {
+		if !slices.IsSorted(offsets) {
+			t.Errorf("%s saved out of partition order: %v", uid, offsets)
+		}
+	}
+	if got := len(service.savedUIDs()); got != 39 {
+		t.Errorf("saved %d orders, want 39 and the invalid one skipped", got)
+	}
+	if got := session.offset(0); got != 40 || session.commits != 1 {
+		t.Errorf("watermark = %d after %d commits, want 40 after 1", got, session.commits)
+	}
+}
+
+func TestPoolQueueIsBounded(t *testing.T) {
+	release := make(chan struct{})
+	service := newFakeOrderService()
+	service.save = func(dto.OrderDTO) error {
+		<-release
+		return nil
+	}
+	consumer := newTestConsumer(t, service, WorkerPoolConfig{Workers: 1, QueueSize: 1})
+
+	claim := &fakeClaim{partition: 0, messages: make(chan *sarama.ConsumerMessage)}
+	session := newFakeSession(context.Background(), 0)
+	done := runPartition(consumer, session, claim)
+
+	var sent atomic.Int32
+	go func() {
+		for offset := int64(0); offset < 10; offset++ {
+			claim.messages <- orderMessage(t, 0, offset, testOrder(fmt.Sprintf("order-%d", offset)))
+			sent.Add(1)
+		}
+		close(claim.messages)
+	}()
+
+	// One order is being saved, one waits in the queue and one waits to be queued.
+	time.Sleep(100 * time.Millisecond)
+	if got := sent.Load(); got != 3 {
+		t.Errorf("%d messages taken from the claim while the worker is blocked, want 3", got)
+	}
+	if got := session.offset(0); got != -1 {
+		t.Errorf("watermark = %d before anything was saved", got)
+	}
+
+	close(release)
+	waitDone(t, done)
+	if got := session.offset(0); got != 10 || len(service.savedUIDs()) != 10 {
+		t.Errorf("watermark = %d, saved %d orders, want all 10", got, len(service.savedUIDs()))
+	}
+}
+
+func TestPoolStopsOnCancellation(t *testing.T) {
+	var attempts atomic.Int32
+	service := newFakeOrderService()
+	service.save = func(dto.OrderDTO) error {
+		attempts.Add(1)
+		return errors.New("connection refused")
+	}
+	consumer := newTestConsumer(t, service, WorkerPoolConfig{Workers: 1, QueueSize: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	claim := &fakeClaim{partition: 0, messages: make(chan *sarama.ConsumerMessage)}
+	session := newFakeSession(ctx, 0)
+	done := runPartition(consumer, session, claim)
+
+	// The first order is retried by the worker, the second is queued and the third blocks
+	// the claim; the claim channel stays open, as it does until sarama ends the session.
+	for offset := int64(0); offset < 3; offset++ {
+		claim.messages <- orderMessage(t, 0, offset, testOrder(fmt.Sprintf("order-%d", offset)))
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	waitDone(t, done)
+
+	if got := session.offset(0); got != -1 {
+		t.Errorf("watermark = %d, failed and skipped orders must stay uncommitted", got)
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("%d save attempts, want the queued order skipped after cancellation", got)
+	}
+	if session.commits != 1 {
+		t.Errorf("commits = %d, want the final commit", session.commits)
+	}
+}
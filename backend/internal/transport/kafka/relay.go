@@ -0,0 +1,180 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"wbts/internal/domain/entity"
+)
+
+const outboxCleanupInterval = time.Minute
+
+type OutboxRepo interface {
+	PublishPending(
+		ctx context.Context,
+		limit int,
+		publish func(ctx context.Context, events []entity.OutboxEvent) error,
+	) (int, error)
+	DeletePublished(ctx context.Context, olderThan time.Duration) (int64, error)
+	Listen(ctx context.Context) <-chan struct{}
+}
+
+type OutboxRelay struct {
+	brokers      []string
+	topic        string
+	outboxRepo   OutboxRepo
+	pollInterval time.Duration
+	batchSize    int
+	retention    time.Duration
+	config       ClientConfig
+}
+
+type orderEvent struct {
+	EventID    int64           `json:"event_id"`
+	EventType  string          `json:"event_type"`
+	OrderUID   string          `json:"order_uid"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Order      json.RawMessage `json:"order"`
+}
+
+func NewOutboxRelay(
+	brokers []string,
+	topic string,
+	outboxRepo OutboxRepo,
+	pollInterval time.Duration,
+	batchSize int,
+	retention time.Duration,
+	config ClientConfig,
+) *OutboxRelay {
+	return &OutboxRelay{brokers, topic, outboxRepo, pollInterval, batchSize, retention, config}
+}
+
+func (r *OutboxRelay) Run(ctx context.Context) {
+	config, err := r.config.Build()
+	if err != nil {
+		log.Printf("Error building outbox producer config, relay is disabled: %v", err)
+		return
+	}
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Idempotent = true
+	config.Producer.Retry.Max = 5
+	config.Producer.Return.Successes = true
+	config.Net.MaxOpenRequests = 1
+
+	if err := config.Validate(); err != nil {
+		log.Printf("Invalid outbox producer config, relay is disabled: %v", err)
+		return
+	}
+
+	producer, err := r.connect(ctx, config)
+	if err != nil {
+		return
+	}
+	defer producer.Close()
+
+	log.Printf("Start relaying outbox events to topic: %s", r.topic)
+	notifications := r.outboxRepo.Listen(ctx)
+	poll := time.NewTicker(r.pollInterval)
+	defer poll.Stop()
+	cleanup := time.NewTicker(outboxCleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		r.relayPending(ctx, producer)
+
+		select {
+		case <-ctx.Done():
+			log.Printf("Finish relaying outbox events to topic: %s", r.topic)
+			return
+		case <-notifications:
+		case <-poll.C:
+		case <-cleanup.C:
+			deleted, err := r.outboxRepo.DeletePublished(ctx, r.retention)
+			if err != nil {
+				log.Printf("Error cleaning up outbox: %v", err)
+			} else if deleted > 0 {
+				log.Printf("Deleted %d published outbox events", deleted)
+			}
+		}
+	}
+}
+
+// connect creates the producer, retrying with the consumer's backoff while Kafka is
+// unavailable. It only fails once ctx is done.
+func (r *OutboxRelay) connect(ctx context.Context, config *sarama.Config) (sarama.SyncProducer, error) {
+	backoff := reconnectBackoffMin
+	for {
+		producer, err := sarama.NewSyncProducer(r.brokers, config)
+		if err == nil {
+			return producer, nil
+		}
+
+		var sleep time.Duration
+		sleep, backoff = nextBackoff(backoff)
+		log.Printf("Error creating outbox producer: %v. Retrying in %s", err, sleep)
+		select {
+		case <-time.After(sleep):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (r *OutboxRelay) relayPending(ctx context.Context, producer sarama.SyncProducer) {
+	for ctx.Err() == nil {
+		published, err := r.outboxRepo.PublishPending(ctx, r.batchSize, func(ctx context.Context, events []entity.OutboxEvent) error {
+			return r.publish(producer, events)
+		})
+		if err != nil {
+			log.Printf("Error relaying outbox events: %v", err)
+			return
+		}
+		if published > 0 {
+			log.Printf("Published %d outbox events to topic: %s", published, r.topic)
+		}
+		if published < r.batchSize {
+			return
+		}
+	}
+}
+
+func (r *OutboxRelay) publish(producer sarama.SyncProducer, events []entity.OutboxEvent) error {
+	msgs := make([]*sarama.ProducerMessage, 0, len(events))
+	for _, event := range events {
+		value, err := json.Marshal(orderEvent{
+			EventID:    event.ID,
+			EventType:  event.EventType,
+			OrderUID:   event.OrderUID,
+			OccurredAt: event.CreatedAt,
+			Order:      event.Payload,
+		})
+		if err != nil {
+			return err
+		}
+
+		msgs = append(msgs, &sarama.ProducerMessage{
+			Topic: r.topic,
+			Key:   sarama.StringEncoder(event.OrderUID),
+			Value: sarama.ByteEncoder(value),
+			Headers: []sarama.RecordHeader{
+				{Key: []byte("event_type"), Value: []byte(event.EventType)},
+				{Key: []byte("event_id"), Value: []byte(strconv.FormatInt(event.ID, 10))},
+			},
+		})
+	}
+
+	if err := producer.SendMessages(msgs); err != nil {
+		var producerErrs sarama.ProducerErrors
+		if errors.As(err, &producerErrs) && len(producerErrs) > 0 {
+			return producerErrs[0].Err
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"wbts/internal/domain/dto"
+	"wbts/internal/domain/entity"
+)
+
+// AllPartitions keys an offset that applies to every partition without its own entry.
+const AllPartitions int32 = -1
+
+const (
+	ReplayInvalid   = "invalid"
+	ReplaySaved     = "saved"
+	ReplayFailed    = "failed"
+	ReplayNew       = "new"
+	ReplayChanged   = "changed"
+	ReplayUnchanged = "unchanged"
+)
+
+// ReplayBound is a position in the topic given either by offsets per partition or by time.
+// A zero bound means "oldest" for the start and "newest at replay start" for the end.
+type ReplayBound struct {
+	Offsets map[int32]int64
+	Time    time.Time
+}
+
+type ReplayOptions struct {
+	Topic   string
+	GroupID string
+	From    ReplayBound
+	To      ReplayBound
+	DryRun  bool
+}
+
+type PartitionRange struct {
+	Partition int32
+	From      int64
+	To        int64
+}
+
+type ReplayReport struct {
+	Ranges   []PartitionRange
+	Outcomes map[string]int
+}
+
+// ParseReplayBound accepts "", an RFC 3339 time, a single offset for all partitions,
+// or a list of partition:offset pairs.
+func ParseReplayBound(spec string) (ReplayBound, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return ReplayBound{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, spec); err == nil {
+		return ReplayBound{Time: t}, nil
+	}
+
+	offsets := make(map[int32]int64)
+	for _, entry := range strings.Split(spec, ",") {
+		partition, offset := AllPartitions, strings.TrimSpace(entry)
+		if p, o, ok := strings.Cut(offset, ":"); ok {
+			parsed, err := strconv.ParseInt(p, 10, 32)
+			if err != nil || parsed < 0 {
+				return ReplayBound{}, fmt.Errorf("invalid partition in %q", entry)
+			}
+			partition, offset = int32(parsed), o
+		}
+
+		parsed, err := strconv.ParseInt(offset, 10, 64)
+		if err != nil || parsed < 0 {
+			return ReplayBound{}, fmt.Errorf("invalid offset in %q", entry)
+		}
+		offsets[partition] = parsed
+	}
+	return ReplayBound{Offsets: offsets}, nil
+}
+
+func (b ReplayBound) resolve(client sarama.Client, topic string, partition int32, fallback int64) (int64, error) {
+	if !b.Time.IsZero() {
+		offset, err := client.GetOffset(topic, partition, b.Time.UnixMilli())
+		if err != nil {
+			return 0, err
+		}
+		if offset == sarama.OffsetNewest {
+			return client.GetOffset(topic, partition, sarama.OffsetNewest)
+		}
+		return offset, nil
+	}
+
+	if offset, ok := b.Offsets[partition]; ok {
+		return offset, nil
+	}
+	if offset, ok := b.Offsets[AllPartitions]; ok {
+		return offset, nil
+	}
+	return client.GetOffset(topic, partition, fallback)
+}
+
+// Replay reprocesses opts.Topic between opts.From and opts.To under a separate consumer group,
+// so the live group's offsets are never touched. In dry-run mode orders are only validated
+// and compared with the stored version, and no offsets are committed.
+func (c *Consumer) Replay(ctx context.Context, opts ReplayOptions) (ReplayReport, error) {
+	if opts.GroupID == "" || opts.GroupID == c.groupID {
+		return ReplayReport{}, errors.New("replay needs a consumer group different from the live one")
+	}
+	if _, ok := c.router.Route(opts.Topic); !ok {
+		return ReplayReport{}, fmt.Errorf("topic %q is not routed to any tenant", opts.Topic)
+	}
+
+	config, err := c.config.Build()
+	if err != nil {
+		return ReplayReport{}, err
+	}
+	config.ClientID += "-replay"
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	config.Consumer.Offsets.AutoCommit.Enable = !opts.DryRun
+
+	client, err := sarama.NewClient(c.brokers, config)
+	if err != nil {
+		return ReplayReport{}, fmt.Errorf("create client: %w", err)
+	}
+	defer client.Close()
+
+	partitions, err := client.Partitions(opts.Topic)
+	if err != nil {
+		return ReplayReport{}, fmt.Errorf("list partitions: %w", err)
+	}
+	report := ReplayReport{Outcomes: make(map[string]int)}
+	pending := make(map[int32]PartitionRange, len(partitions))
+	for _, partition := range partitions {
+		from, err := opts.From.resolve(client, opts.Topic, partition, sarama.OffsetOldest)
+		if err != nil {
+			return report, fmt.Errorf("resolve start of partition %d: %w", partition, err)
+		}
+		to, err := opts.To.resolve(client, opts.Topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return report, fmt.Errorf("resolve end of partition %d: %w", partition, err)
+		}
+
+		r := PartitionRange{partition, from, to}
+		report.Ranges = append(report.Ranges, r)
+		if from < to {
+			pending[partition] = r
+		}
+	}
+	if len(pending) == 0 {
+		return report, nil
+	}
+
+	group, err := sarama.NewConsumerGroupFromClient(opts.GroupID, client)
+	if err != nil {
+		return report, fmt.Errorf("create consumer group: %w", err)
+	}
+	defer group.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	handler := &replayHandler{consumer: c, topic: opts.Topic, dryRun: opts.DryRun, pending: pending, report: &report, done: cancel}
+
+	for ctx.Err() == nil {
+		if err := group.Consume(ctx, []string{opts.Topic}, handler); err != nil && ctx.Err() == nil {
+			return report, err
+		}
+	}
+	if len(handler.remaining()) > 0 {
+		return report, fmt.Errorf("replay interrupted, partitions %v are incomplete", handler.remaining())
+	}
+	return report, nil
+}
+
+type replayHandler struct {
+	consumer *Consumer
+	topic    string
+	dryRun   bool
+	pending  map[int32]PartitionRange
+	report   *ReplayReport
+	done     context.CancelFunc
+	mtx      sync.Mutex
+}
+
+// Setup moves every claimed partition to the next offset to replay, so neither offsets
+// committed by an earlier run nor a rebalance make the replay skip or repeat messages.
+// ResetOffset only moves an offset back and MarkOffset only moves it forward, so calling
+// both positions the partition whichever side of r.From the group currently is.
+func (h *replayHandler) Setup(session sarama.ConsumerGroupSession) error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	for _, partition := range session.Claims()[h.topic] {
+		if r, ok := h.pending[partition]; ok {
+			session.ResetOffset(h.topic, partition, r.From, "")
+			session.MarkOffset(h.topic, partition, r.From, "")
+		}
+	}
+	return nil
+}
+
+func (h *replayHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *replayHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	h.mtx.Lock()
+	r, ok := h.pending[claim.Partition()]
+	h.mtx.Unlock()
+	if !ok {
+		return nil
+	}
+
+	finished := claim.InitialOffset() >= r.To
+	for msg := range claim.Messages() {
+		if finished = msg.Offset >= r.To; finished {
+			break
+		}
+		// Messages before the range are skipped should the claim start earlier than Setup
+		// positioned it.
+		if msg.Offset < r.From {
+			continue
+		}
+
+		outcome := h.process(session.Context(), msg)
+		h.mtx.Lock()
+		h.report.Outcomes[outcome]++
+		r.From = msg.Offset + 1
+		h.pending[claim.Partition()] = r
+		h.mtx.Unlock()
+
+		if !h.dryRun {
+			session.MarkMessage(msg, "")
+		}
+		if finished = r.From >= r.To; finished {
+			break
+		}
+	}
+	if !finished {
+		return nil
+	}
+
+	h.mtx.Lock()
+	delete(h.pending, claim.Partition())
+	if len(h.pending) == 0 {
+		h.done()
+	}
+	h.mtx.Unlock()
+	return nil
+}
+
+func (h *replayHandler) process(ctx context.Context, msg *sarama.ConsumerMessage) string {
+	order, err := h.consumer.decodeOrder(ctx, msg)
+	if err != nil {
+		log.Printf("Replay: partition=%d offset=%d is invalid", msg.Partition, msg.Offset)
+		logProcessingError(err)
+		return ReplayInvalid
+	}
+
+	if !h.dryRun {
+		// Replays are deliberate, so they bypass the processed message ledger.
+		if err := h.consumer.orderService.Save(order, ""); err != nil {
+			log.Printf("Replay: error saving order with uid=%s: %v", order.OrderUID, err)
+			return ReplayFailed
+		}
+		return ReplaySaved
+	}
+
+	stored, err := h.consumer.orderService.Get(order.OrderUID)
+	if errors.Is(err, entity.ErrOrderNotFound) {
+		log.Printf("Replay: order with uid=%s would be created", order.OrderUID)
+		return ReplayNew
+	}
+	if err != nil {
+		log.Printf("Replay: error getting order with uid=%s: %v", order.OrderUID, err)
+		return ReplayFailed
+	}
+
+	if sameOrder(order, stored) {
+		return ReplayUnchanged
+	}
+	log.Printf("Replay: order with uid=%s would be updated", order.OrderUID)
+	return ReplayChanged
+}
+
+// sameOrder compares a replayed order with the stored one after normalising what storing
+// changes: timestamps come back from the database in UTC with microsecond precision, and
+// an order without items is read back with an empty rather than a nil list.
+func sameOrder(replayed dto.OrderDTO, stored dto.OrderDTO) bool {
+	normalize := func(order dto.OrderDTO) dto.OrderDTO {
+		order.DateCreated = order.DateCreated.UTC().Truncate(time.Microsecond)
+		if len(order.Items) == 0 {
+			order.Items = nil
+		}
+		return order
+	}
+	return reflect.DeepEqual(normalize(replayed), normalize(stored))
+}
+
+func (h *replayHandler) remaining() []int32 {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	partitions := make([]int32, 0, len(h.pending))
+	for partition := range h.pending {
+		partitions = append(partitions, partition)
+	}
+	return partitions
+}
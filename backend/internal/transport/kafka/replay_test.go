@@ -0,0 +1,331 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/go-playground/validator/v10"
+
+	"wbts/internal/domain/dto"
+	"wbts/internal/domain/entity"
+	"wbts/internal/schema"
+)
+
+const testTopic = "orders"
+
+// fakeSession records offsets the way sarama's offset manager does: ResetOffset only moves
+// an offset back and MarkOffset only moves it forward. A partition without a committed
+// offset starts at -1.
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx     context.Context
+	claims  map[string][]int32
+	mtx     sync.Mutex
+	offsets map[int32]int64
+	marked  []int64
+	commits int
+}
+
+func newFakeSession(ctx context.Context, partitions ...int32) *fakeSession {
+	return &fakeSession{ctx: ctx, claims: map[string][]int32{testTopic: partitions}, offsets: make(map[int32]int64)}
+}
+
+func (s *fakeSession) Context() context.Context   { return s.ctx }
+func (s *fakeSession) Claims() map[string][]int32 { return s.claims }
+
+func (s *fakeSession) offset(partition int32) int64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if offset, ok := s.offsets[partition]; ok {
+		return offset
+	}
+	return -1
+}
+
+func (s *fakeSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {
+	if offset <= s.offset(partition) {
+		s.mtx.Lock()
+		s.offsets[partition] = offset
+		s.mtx.Unlock()
+	}
+}
+
+func (s *fakeSession) MarkOffset(topic string, partition int32, offset int64, metadata string) {
+	if offset > s.offset(partition) {
+		s.mtx.Lock()
+		s.offsets[partition] = offset
+		s.mtx.Unlock()
+	}
+}
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.mtx.Lock()
+	s.marked = append(s.marked, msg.Offset)
+	s.mtx.Unlock()
+	s.MarkOffset(msg.Topic, msg.Partition, msg.Offset+1, metadata)
+}
+
+func (s *fakeSession) Commit() {
+	s.mtx.Lock()
+	s.commits++
+	s.mtx.Unlock()
+}
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	partition int32
+	initial   int64
+	messages  chan *sarama.ConsumerMessage
+}
+
+func newFakeClaim(partition int32, msgs ...*sarama.ConsumerMessage) *fakeClaim {
+	claim := &fakeClaim{partition: partition, messages: make(chan *sarama.ConsumerMessage, len(msgs))}
+	if len(msgs) > 0 {
+		claim.initial = msgs[0].Offset
+	}
+	for _, msg := range msgs {
+		claim.messages <- msg
+	}
+	close(claim.messages)
+	return claim
+}
+
+func (c *fakeClaim) Topic() string                            { return testTopic }
+func (c *fakeClaim) Partition() int32                         { return c.partition }
+func (c *fakeClaim) InitialOffset() int64                     { return c.initial }
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+// fakeOrderService stores orders in memory and records the order in which they were saved.
+type fakeOrderService struct {
+	mtx    sync.Mutex
+	orders map[string]dto.OrderDTO
+	saved  []string
+	save   func(order dto.OrderDTO) error
+}
+
+func newFakeOrderService() *fakeOrderService {
+	return &fakeOrderService{orders: make(map[string]dto.OrderDTO)}
+}
+
+func (s *fakeOrderService) Save(order dto.OrderDTO, messageKey string) error {
+	if s.save != nil {
+		if err := s.save(order); err != nil {
+			return err
+		}
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.orders[order.OrderUID] = order
+	s.saved = append(s.saved, order.OrderUID)
+	return nil
+}
+
+func (s *fakeOrderService) Get(order_uid string) (dto.OrderDTO, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	order, ok := s.orders[order_uid]
+	if !ok {
+		return dto.OrderDTO{}, entity.ErrOrderNotFound
+	}
+	return order, nil
+}
+
+func (s *fakeOrderService) savedUIDs() []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]string(nil), s.saved...)
+}
+
+func newTestConsumer(t *testing.T, service OrderService, pool WorkerPoolConfig) *Consumer {
+	t.Helper()
+	profile, _ := LookupValidationProfile("")
+	router, err := NewTopicRouter([]TopicRoute{{Topic: testTopic, Tenant: "acme", Profile: profile}})
+	if err != nil {
+		t.Fatalf("NewTopicRouter: %v", err)
+	}
+	schemaValidator, err := schema.NewOrderValidator()
+	if err != nil {
+		t.Fatalf("NewOrderValidator: %v", err)
+	}
+	return NewConsumer(nil, router, "orders-group", service, validator.New(), schemaValidator, NewDecoder(nil), pool, ClientConfig{})
+}
+
+func testOrder(uid string) dto.OrderDTO {
+	return dto.OrderDTO{
+		OrderUID:    uid,
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: dto.DeliveryDTO{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: dto.PaymentDTO{
+			Transaction: uid, Currency: "USD", Provider: "wbpay", Amount: 1817, PaymentDt: 1637907727,
+			Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Items: []dto.ItemDTO{{
+			ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, Rid: "ab4219087a764ae0btest", Name: "Mascaras",
+			Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		}},
+		Locale:            "en",
+		InternalSignature: "sig",
+		CustomerID:        "test",
+		DeliveryService:   "meest",
+		Shardkey:          "9",
+		SmID:              99,
+		DateCreated:       time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:          "1",
+	}
+}
+
+func orderMessage(t *testing.T, partition int32, offset int64, order dto.OrderDTO) *sarama.ConsumerMessage {
+	t.Helper()
+	value, err := json.Marshal(order)
+	if err != nil {
+		t.Fatalf("marshal order: %v", err)
+	}
+	return &sarama.ConsumerMessage{Topic: testTopic, Partition: partition, Offset: offset, Value: value}
+}
+
+func TestReplaySetupPositionsPartitions(t *testing.T) {
+	tests := []struct {
+		name      string
+		committed int64
+		from      int64
+	}{
+		{"fresh group", -1, 5},
+		{"rewind", 10, 5},
+		{"skip ahead", 3, 5},
+		{"already there", 5, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := newFakeSession(context.Background(), 0, 1)
+			if tt.committed >= 0 {
+				session.offsets[0] = tt.committed
+			}
+			handler := &replayHandler{topic: testTopic, pending: map[int32]PartitionRange{0: {0, tt.from, 20}}}
+			if err := handler.Setup(session); err != nil {
+				t.Fatalf("Setup: %v", err)
+			}
+			if got := session.offset(0); got != tt.from {
+				t.Errorf("partition 0 positioned at %d, want %d", got, tt.from)
+			}
+			if got := session.offset(1); got != -1 {
+				t.Errorf("partition 1 without a pending range was moved to %d", got)
+			}
+		})
+	}
+}
+
+func TestReplayConsumesOnlyTheRange(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		service := newFakeOrderService()
+		consumer := newTestConsumer(t, service, WorkerPoolConfig{})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		report := ReplayReport{Outcomes: make(map[string]int)}
+		handler := &replayHandler{
+			consumer: consumer,
+			topic:    testTopic,
+			dryRun:   dryRun,
+			pending:  map[int32]PartitionRange{0: {0, 2, 5}},
+			report:   &report,
+			done:     cancel,
+		}
+
+		// The claim starts at the oldest offset and runs past the end of the range.
+		var msgs []*sarama.ConsumerMessage
+		for offset := int64(0); offset < 8; offset++ {
+			msgs = append(msgs, orderMessage(t, 0, offset, testOrder(fmt.Sprintf("order-%d", offset))))
+		}
+		session := newFakeSession(ctx, 0)
+		if err := handler.ConsumeClaim(session, newFakeClaim(0, msgs...)); err != nil {
+			t.Fatalf("ConsumeClaim: %v", err)
+		}
+
+		if dryRun {
+			if report.Outcomes[ReplayNew] != 3 || len(service.savedUIDs()) != 0 || len(session.marked) != 0 {
+				t.Errorf("dry run: outcomes %v, saved %v, marked %v", report.Outcomes, service.savedUIDs(), session.marked)
+			}
+		} else {
+			if got := service.savedUIDs(); len(got) != 3 || got[0] != "order-2" || got[2] != "order-4" {
+				t.Errorf("saved %v, want order-2..order-4", got)
+			}
+			if len(session.marked) != 3 || session.marked[0] != 2 || session.marked[2] != 4 {
+				t.Errorf("marked offsets %v, want 2..4", session.marked)
+			}
+		}
+		if len(handler.remaining()) != 0 || ctx.Err() == nil {
+			t.Errorf("dry run %t: partition not finished, remaining %v", dryRun, handler.remaining())
+		}
+	}
+}
+
+func TestReplayResumesAfterRebalance(t *testing.T) {
+	service := newFakeOrderService()
+	consumer := newTestConsumer(t, service, WorkerPoolConfig{})
+	report := ReplayReport{Outcomes: make(map[string]int)}
+	handler := &replayHandler{
+		consumer: consumer,
+		topic:    testTopic,
+		pending:  map[int32]PartitionRange{0: {0, 0, 4}},
+		report:   &report,
+		done:     func() {},
+	}
+
+	// The first generation is revoked after two messages.
+	first := newFakeClaim(0, orderMessage(t, 0, 0, testOrder("a")), orderMessage(t, 0, 1, testOrder("b")))
+	handler.ConsumeClaim(newFakeSession(context.Background(), 0), first)
+	if r := handler.pending[0]; r.From != 2 {
+		t.Fatalf("range after revocation = %+v, want to resume at 2", r)
+	}
+
+	// The next generation redelivers offset 1 before continuing.
+	second := newFakeClaim(0,
+		orderMessage(t, 0, 1, testOrder("b")),
+		orderMessage(t, 0, 2, testOrder("c")),
+		orderMessage(t, 0, 3, testOrder("d")),
+	)
+	handler.ConsumeClaim(newFakeSession(context.Background(), 0), second)
+	if got := service.savedUIDs(); len(got) != 4 {
+		t.Errorf("saved %v, want a..d exactly once", got)
+	}
+	if len(handler.remaining()) != 0 {
+		t.Errorf("remaining partitions %v", handler.remaining())
+	}
+}
+
+func TestReplayDryRunComparesNormalisedOrders(t *testing.T) {
+	service := newFakeOrderService()
+	consumer := newTestConsumer(t, service, WorkerPoolConfig{})
+	handler := &replayHandler{consumer: consumer, topic: testTopic, dryRun: true}
+
+	// The stored copy comes back from the database in local time with the route's tenant.
+	stored := testOrder("a")
+	stored.Tenant = "acme"
+	stored.DateCreated = stored.DateCreated.In(time.FixedZone("MSK", 3*60*60))
+	service.orders["a"] = stored
+	if outcome := handler.process(context.Background(), orderMessage(t, 0, 0, testOrder("a"))); outcome != ReplayUnchanged {
+		t.Errorf("unchanged order reported as %s", outcome)
+	}
+
+	changed := testOrder("a")
+	changed.Payment.Amount++
+	if outcome := handler.process(context.Background(), orderMessage(t, 0, 1, changed)); outcome != ReplayChanged {
+		t.Errorf("changed order reported as %s", outcome)
+	}
+
+	invalid := testOrder("b")
+	invalid.Entry = ""
+	if outcome := handler.process(context.Background(), orderMessage(t, 0, 2, invalid)); outcome != ReplayInvalid {
+		t.Errorf("invalid order reported as %s", outcome)
+	}
+}
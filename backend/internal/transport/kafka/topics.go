@@ -0,0 +1,217 @@
+package kafka
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"wbts/internal/domain/dto"
+	"wbts/internal/pkg"
+)
+
+// ValidationProfile controls how strictly messages of a topic are checked before saving.
+type ValidationProfile struct {
+	Name             string
+	MinSchemaVersion int
+	StructValidation bool
+}
+
+var validationProfiles = map[string]ValidationProfile{
+	"default":     {Name: "default", MinSchemaVersion: 1, StructValidation: true},
+	"strict":      {Name: "strict", MinSchemaVersion: 2, StructValidation: true},
+	"schema-only": {Name: "schema-only", MinSchemaVersion: 1, StructValidation: false},
+}
+
+func LookupValidationProfile(name string) (ValidationProfile, error) {
+	if name == "" {
+		name = "default"
+	}
+	profile, ok := validationProfiles[name]
+	if !ok {
+		return ValidationProfile{}, fmt.Errorf("unknown validation profile %q", name)
+	}
+	return profile, nil
+}
+
+// TopicRoute ties a topic to the tenant its orders are stored for.
+type TopicRoute struct {
+	Topic   string
+	Tenant  string
+	Profile ValidationProfile
+}
+
+// TopicRouter resolves the topics to subscribe to and the route of every consumed message.
+// Topics are either listed explicitly or matched by a regular expression against the
+// cluster metadata, in which case the tenant is expanded from the match.
+type TopicRouter struct {
+	routes         map[string]TopicRoute
+	pattern        *regexp.Regexp
+	tenantTemplate string
+	profile        ValidationProfile
+	refresh        time.Duration
+}
+
+func NewTopicRouter(routes []TopicRoute) (*TopicRouter, error) {
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("at least one topic is required")
+	}
+	r := &TopicRouter{routes: make(map[string]TopicRoute, len(routes))}
+	for _, route := range routes {
+		if route.Topic == "" || route.Tenant == "" {
+			return nil, fmt.Errorf("topic route %q=%q needs both a topic and a tenant", route.Topic, route.Tenant)
+		}
+		if _, ok := r.routes[route.Topic]; ok {
+			return nil, fmt.Errorf("topic %q is listed twice", route.Topic)
+		}
+		r.routes[route.Topic] = route
+	}
+	return r, nil
+}
+
+// NewPatternRouter subscribes to every topic matching pattern. The tenant is tenantTemplate
+// expanded with the submatches ($1, ${region}), or the topic name if the template is empty.
+// Matching topics are re-resolved every refresh interval.
+func NewPatternRouter(pattern string, tenantTemplate string, profile ValidationProfile, refresh time.Duration) (*TopicRouter, error) {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid topic pattern: %w", err)
+	}
+	if refresh <= 0 {
+		return nil, fmt.Errorf("topic refresh interval must be positive, got %s", refresh)
+	}
+	return &TopicRouter{
+		routes:         make(map[string]TopicRoute),
+		pattern:        re,
+		tenantTemplate: tenantTemplate,
+		profile:        profile,
+		refresh:        refresh,
+	}, nil
+}
+
+// ParseTopicRoutes parses a comma-separated list of topic=tenant[:profile] entries.
+// A bare topic is routed to the default tenant.
+func ParseTopicRoutes(spec string) ([]TopicRoute, error) {
+	var routes []TopicRoute
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		topic, target, _ := strings.Cut(entry, "=")
+		tenant, profileName, _ := strings.Cut(target, ":")
+		if tenant == "" {
+			tenant = dto.DefaultTenant
+		}
+		profile, err := LookupValidationProfile(profileName)
+		if err != nil {
+			return nil, fmt.Errorf("topic %q: %w", topic, err)
+		}
+		routes = append(routes, TopicRoute{Topic: strings.TrimSpace(topic), Tenant: strings.TrimSpace(tenant), Profile: profile})
+	}
+	return routes, nil
+}
+
+// TopicRouterFromEnv builds a router from KAFKA_TOPIC_PATTERN, KAFKA_TOPICS or, for a single
+// topic setup, KAFKA_ORDERS_TOPIC, in that order of precedence.
+func TopicRouterFromEnv() (*TopicRouter, error) {
+	if pattern := os.Getenv("KAFKA_TOPIC_PATTERN"); pattern != "" {
+		profile, err := LookupValidationProfile(os.Getenv("KAFKA_TOPIC_PATTERN_PROFILE"))
+		if err != nil {
+			return nil, fmt.Errorf("KAFKA_TOPIC_PATTERN_PROFILE: %w", err)
+		}
+		return NewPatternRouter(
+			pattern,
+			os.Getenv("KAFKA_TOPIC_PATTERN_TENANT"),
+			profile,
+			pkg.GetEnvDuration("KAFKA_TOPIC_REFRESH_INTERVAL", time.Minute),
+		)
+	}
+
+	if spec := os.Getenv("KAFKA_TOPICS"); spec != "" {
+		routes, err := ParseTopicRoutes(spec)
+		if err != nil {
+			return nil, fmt.Errorf("KAFKA_TOPICS: %w", err)
+		}
+		return NewTopicRouter(routes)
+	}
+
+	profile, _ := LookupValidationProfile("")
+	return NewTopicRouter([]TopicRoute{{
+		Topic:   pkg.GetEnv("KAFKA_ORDERS_TOPIC", "orders"),
+		Tenant:  dto.DefaultTenant,
+		Profile: profile,
+	}})
+}
+
+// SetupTopics reads the topic routing from the environment and stops the process if it is invalid.
+func SetupTopics() *TopicRouter {
+	router, err := TopicRouterFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid Kafka topic configuration: %v", err)
+	}
+	return router
+}
+
+// Route returns the route of a topic. Topics matching the pattern are routed on first use.
+func (r *TopicRouter) Route(topic string) (TopicRoute, bool) {
+	if route, ok := r.routes[topic]; ok || r.pattern == nil {
+		return route, ok
+	}
+
+	match := r.pattern.FindStringSubmatchIndex(topic)
+	if match == nil {
+		return TopicRoute{}, false
+	}
+	tenant := topic
+	if r.tenantTemplate != "" {
+		tenant = string(r.pattern.ExpandString(nil, r.tenantTemplate, topic, match))
+	}
+	if tenant == "" {
+		return TopicRoute{}, false
+	}
+	return TopicRoute{Topic: topic, Tenant: tenant, Profile: r.profile}, true
+}
+
+// Topics returns the sorted list of topics to subscribe to. For a pattern router the cluster
+// metadata is refreshed first, so topics created since the last call are picked up.
+func (r *TopicRouter) Topics(client sarama.Client) ([]string, error) {
+	if r.pattern == nil {
+		topics := make([]string, 0, len(r.routes))
+		for topic := range r.routes {
+			topics = append(topics, topic)
+		}
+		slices.Sort(topics)
+		return topics, nil
+	}
+
+	if err := client.RefreshMetadata(); err != nil {
+		return nil, fmt.Errorf("refresh metadata: %w", err)
+	}
+	all, err := client.Topics()
+	if err != nil {
+		return nil, fmt.Errorf("list topics: %w", err)
+	}
+	var topics []string
+	for _, topic := range all {
+		if _, ok := r.Route(topic); ok {
+			topics = append(topics, topic)
+		}
+	}
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("no topic matches %s", r.pattern)
+	}
+	slices.Sort(topics)
+	return topics, nil
+}
+
+// Dynamic reports whether the subscription has to be re-resolved periodically.
+func (r *TopicRouter) Dynamic() bool {
+	return r.pattern != nil
+}
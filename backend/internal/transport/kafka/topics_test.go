@@ -0,0 +1,166 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"wbts/internal/domain/dto"
+)
+
+func TestParseTopicRoutes(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []TopicRoute
+		wantErr bool
+	}{
+		{spec: "", want: nil},
+		{spec: "orders", want: []TopicRoute{{"orders", dto.DefaultTenant, validationProfiles["default"]}}},
+		{
+			spec: " orders-ru = ru , orders-kz=kz:strict,,orders-raw=raw:schema-only ",
+			want: []TopicRoute{
+				{"orders-ru", "ru", validationProfiles["default"]},
+				{"orders-kz", "kz", validationProfiles["strict"]},
+				{"orders-raw", "raw", validationProfiles["schema-only"]},
+			},
+		},
+		{spec: "orders=:strict", want: []TopicRoute{{"orders", dto.DefaultTenant, validationProfiles["strict"]}}},
+		{spec: "orders=ru:lenient", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			routes, err := ParseTopicRoutes(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseTopicRoutes(%q) = %v, want an error", tt.spec, routes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTopicRoutes(%q): %v", tt.spec, err)
+			}
+			if len(routes) != len(tt.want) {
+				t.Fatalf("routes = %+v, want %+v", routes, tt.want)
+			}
+			for i := range routes {
+				if routes[i] != tt.want[i] {
+					t.Errorf("route %d = %+v, want %+v", i, routes[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewTopicRouterRejectsInvalidRoutes(t *testing.T) {
+	profile := validationProfiles["default"]
+	tests := map[string][]TopicRoute{
+		"no routes":       nil,
+		"missing topic":   {{Topic: "", Tenant: "ru", Profile: profile}},
+		"missing tenant":  {{Topic: "orders", Tenant: "", Profile: profile}},
+		"duplicate topic": {{"orders", "ru", profile}, {"orders", "kz", profile}},
+	}
+	for name, routes := range tests {
+		if _, err := NewTopicRouter(routes); err == nil {
+			t.Errorf("%s: NewTopicRouter succeeded", name)
+		}
+	}
+}
+
+func TestPatternRouter(t *testing.T) {
+	strict := validationProfiles["strict"]
+	tests := []struct {
+		name       string
+		pattern    string
+		template   string
+		topic      string
+		wantTenant string
+		wantOK     bool
+	}{
+		{"named group", `orders-(?P<region>[a-z]{2})`, "${region}", "orders-ru", "ru", true},
+		{"numbered group", `orders\.(\w+)\.v1`, "market-$1", "orders.kz.v1", "market-kz", true},
+		{"topic name without template", `orders-.*`, "", "orders-by", "orders-by", true},
+		{"whole name must match", `orders-[a-z]{2}`, "$0", "orders-ru-dlq", "", false},
+		{"no match", `orders-[a-z]{2}`, "$0", "payments-ru", "", false},
+		{"empty expansion", `orders-([a-z]*)`, "$1", "orders-", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, err := NewPatternRouter(tt.pattern, tt.template, strict, time.Minute)
+			if err != nil {
+				t.Fatalf("NewPatternRouter: %v", err)
+			}
+			route, ok := router.Route(tt.topic)
+			if ok != tt.wantOK || route.Tenant != tt.wantTenant {
+				t.Fatalf("Route(%s) = %+v, %t, want tenant %q, %t", tt.topic, route, ok, tt.wantTenant, tt.wantOK)
+			}
+			if ok && (route.Topic != tt.topic || route.Profile != strict) {
+				t.Errorf("Route(%s) = %+v", tt.topic, route)
+			}
+		})
+	}
+
+	if _, err := NewPatternRouter(`orders-(`, "", strict, time.Minute); err == nil {
+		t.Error("invalid pattern was accepted")
+	}
+	if _, err := NewPatternRouter(`orders-.*`, "", strict, 0); err == nil {
+		t.Error("zero refresh interval was accepted")
+	}
+}
+
+// TestValidationProfiles checks that every message is validated with the profile of its topic
+// and stored for the topic's tenant.
+func TestValidationProfiles(t *testing.T) {
+	consumer := newTestConsumer(t, newFakeOrderService(), WorkerPoolConfig{})
+	routes, err := ParseTopicRoutes("orders-ru=ru,orders-kz=kz:strict,orders-raw=raw:schema-only")
+	if err != nil {
+		t.Fatalf("ParseTopicRoutes: %v", err)
+	}
+	if consumer.router, err = NewTopicRouter(routes); err != nil {
+		t.Fatalf("NewTopicRouter: %v", err)
+	}
+
+	v1 := testOrder("a")
+	v1.Tenant = "spoofed"
+	// An address the schema's email format accepts but struct validation does not.
+	loose := testOrder("b")
+	loose.Delivery.Email = "test@localhost"
+
+	tests := []struct {
+		topic      string
+		order      dto.OrderDTO
+		version    int
+		wantTenant string
+	}{
+		{"orders-ru", v1, 1, "ru"},
+		{"orders-ru", v1, 2, "ru"},
+		{"orders-ru", loose, 1, ""},
+		{"orders-kz", v1, 1, ""},
+		{"orders-kz", v1, 2, "kz"},
+		{"orders-raw", loose, 1, "raw"},
+		{"orders-unrouted", v1, 1, ""},
+	}
+
+	for _, tt := range tests {
+		msg := orderMessage(t, 0, 0, tt.order)
+		msg.Topic = tt.topic
+		if tt.version == 2 {
+			var doc map[string]any
+			json.Unmarshal(msg.Value, &doc)
+			doc["schema_version"] = 2
+			msg.Value, _ = json.Marshal(doc)
+		}
+
+		order, err := consumer.decodeOrder(context.Background(), msg)
+		switch {
+		case tt.wantTenant == "" && err == nil:
+			t.Errorf("%s: %s v%d was accepted", tt.topic, tt.order.OrderUID, tt.version)
+		case tt.wantTenant != "" && err != nil:
+			t.Errorf("%s: %s v%d was rejected: %v", tt.topic, tt.order.OrderUID, tt.version, err)
+		case err == nil && order.Tenant != tt.wantTenant:
+			t.Errorf("%s: tenant = %q, want %q", tt.topic, order.Tenant, tt.wantTenant)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package rest
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+
+	"wbts/internal/auth"
+	"wbts/internal/domain/dto"
+)
+
+type CustomerService interface {
+	ExportCustomer(customerID string, actor string) (dto.CustomerExportDTO, error)
+	AnonymizeCustomer(customerID string, actor string) (dto.CustomerAnonymizationDTO, error)
+}
+
+type AdminHandler struct {
+	customerService CustomerService
+}
+
+func NewAdminHandler(customerService CustomerService) *AdminHandler {
+	return &AdminHandler{customerService}
+}
+
+func (h *AdminHandler) ExportCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	customerID := r.PathValue("customer_id")
+	principal, _ := auth.PrincipalFrom(r.Context())
+
+	export, err := h.customerService.ExportCustomer(customerID, principal.Subject)
+	if err != nil {
+		log.Printf("Error exporting customer data: %v", err)
+		http.Error(w, "Error exporting customer data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Content-Disposition", `attachment; filename="customer-`+url.PathEscape(customerID)+`-export"`)
+	writeValue(w, r, export)
+}
+
+func (h *AdminHandler) AnonymizeCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	customerID := r.PathValue("customer_id")
+	principal, _ := auth.PrincipalFrom(r.Context())
+
+	result, err := h.customerService.AnonymizeCustomer(customerID, principal.Subject)
+	if err != nil {
+		log.Printf("Error anonymizing customer data: %v", err)
+		http.Error(w, "Error anonymizing customer data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeValue(w, r, result)
+}
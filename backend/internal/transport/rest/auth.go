@@ -0,0 +1,56 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"wbts/internal/auth"
+)
+
+// Authenticate attaches the caller's principal to the request. Without an authenticator every
+// caller is anonymous and has no scopes, so scoped routes are denied.
+func Authenticate(authenticator auth.Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal := auth.Principal{Subject: "anonymous"}
+		if authenticator != nil {
+			p, err := authenticator.Authenticate(r)
+			switch {
+			case err == nil:
+				principal = p
+			case errors.Is(err, auth.ErrNoCredentials):
+			default:
+				auth.AuditDenied(r, "", err.Error())
+				w.Header().Set("WWW-Authenticate", `Bearer realm="wbts"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		w.Header().Add("Vary", "Authorization")
+		w.Header().Add("Vary", "X-API-Key")
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+func RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := auth.PrincipalFrom(r.Context())
+		if principal.HasScope(scope) {
+			next(w, r)
+			return
+		}
+
+		auth.AuditDenied(r, principal.Subject, "missing scope "+scope)
+		if principal.Method == "" && len(principal.Scopes) == 0 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="wbts"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	}
+}
+
+func orderView(r *http.Request) string {
+	principal, _ := auth.PrincipalFrom(r.Context())
+	return principal.OrderView()
+}
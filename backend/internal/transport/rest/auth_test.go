@@ -0,0 +1,80 @@
+package rest
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"wbts/internal/auth"
+	"wbts/internal/domain/dto"
+)
+
+// newAuthServer serves a few routes behind the development API keys from docker-compose.
+func newAuthServer(t *testing.T) http.Handler {
+	t.Helper()
+
+	authenticator, err := auth.NewAPIKeyAuthenticatorFromFile("../../../../dev/api-keys.json")
+	if err != nil {
+		t.Fatalf("load the development API keys: %v", err)
+	}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(orderView(r)))
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /order/{order_uid}", RequireScope(auth.ScopeOrdersRead, ok))
+	mux.HandleFunc("POST /admin/customers/{customer_id}/anonymize", RequireScope(auth.ScopeOrdersAdmin, ok))
+	mux.HandleFunc("GET /debug/vars", RequireScope(auth.ScopeOrdersAdmin, expvar.Handler().ServeHTTP))
+	mux.HandleFunc("GET /healthz", ok)
+	return Authenticate(authenticator, mux)
+}
+
+func TestAuthentication(t *testing.T) {
+	server := newAuthServer(t)
+	tests := []struct {
+		name   string
+		method string
+		target string
+		header string
+		value  string
+		want   int
+		// view is the order view the handler sees, checked for successful requests.
+		view string
+	}{
+		{"no credentials", http.MethodGet, "/order/test", "", "", http.StatusUnauthorized, ""},
+		{"unknown key", http.MethodGet, "/order/test", "X-API-Key", "guess", http.StatusUnauthorized, ""},
+		{"unknown key on an open route", http.MethodGet, "/healthz", "X-API-Key", "guess", http.StatusUnauthorized, ""},
+		{"no credentials on an open route", http.MethodGet, "/healthz", "", "", http.StatusOK, ""},
+		{"reader", http.MethodGet, "/order/test", "X-API-Key", "dev-frontend-key", http.StatusOK, dto.ViewMasked},
+		{"reader in the Authorization header", http.MethodGet, "/order/test", "Authorization", "ApiKey dev-frontend-key", http.StatusOK, dto.ViewMasked},
+		{"admin", http.MethodGet, "/order/test", "X-API-Key", "dev-admin-key", http.StatusOK, dto.ViewFull},
+		{"reader calls an admin route", http.MethodPost, "/admin/customers/test/anonymize", "X-API-Key", "dev-frontend-key", http.StatusForbidden, ""},
+		{"admin calls an admin route", http.MethodPost, "/admin/customers/test/anonymize", "X-API-Key", "dev-admin-key", http.StatusOK, dto.ViewFull},
+		{"anonymous debug vars", http.MethodGet, "/debug/vars", "", "", http.StatusUnauthorized, ""},
+		{"reader debug vars", http.MethodGet, "/debug/vars", "X-API-Key", "dev-frontend-key", http.StatusForbidden, ""},
+		{"admin debug vars", http.MethodGet, "/debug/vars", "X-API-Key", "dev-admin-key", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("%s %s = %d, want %d: %s", tt.method, tt.target, rec.Code, tt.want, rec.Body)
+			}
+			challenge := rec.Header().Get("WWW-Authenticate")
+			if (rec.Code == http.StatusUnauthorized) != (challenge != "") {
+				t.Errorf("status %d with WWW-Authenticate %q", rec.Code, challenge)
+			}
+			if tt.view != "" && rec.Body.String() != tt.view {
+				t.Errorf("view = %q, want %q", rec.Body, tt.view)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package rest
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"wbts/internal/domain/dto"
+	"wbts/internal/pkg"
+)
+
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	cw          io.WriteCloser
+	wroteHeader bool
+}
+
+func (w *compressResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	h := w.Header()
+	passthrough := w.encoding == dto.EncodingIdentity ||
+		h.Get("Content-Encoding") != "" ||
+		status < http.StatusOK ||
+		status == http.StatusNoContent ||
+		status == http.StatusNotModified ||
+		strings.HasPrefix(h.Get("Content-Type"), "text/event-stream")
+
+	if !passthrough {
+		cw, err := pkg.NewEncodingWriter(w.ResponseWriter, w.encoding)
+		if err != nil {
+			log.Printf("Error creating %s response writer: %v", w.encoding, err)
+		} else {
+			w.cw = cw
+			h.Set("Content-Encoding", w.encoding)
+			h.Del("Content-Length")
+			if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("ETag", "W/"+etag)
+			}
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.cw != nil {
+		return w.cw.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressResponseWriter) Flush() {
+	if f, ok := w.cw.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			log.Printf("Error flushing %s response writer: %v", w.encoding, err)
+		}
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressResponseWriter) Close() {
+	if w.cw == nil {
+		return
+	}
+	if err := w.cw.Close(); err != nil {
+		log.Printf("Error closing %s response writer: %v", w.encoding, err)
+	}
+}
+
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
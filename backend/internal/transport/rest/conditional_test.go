@@ -0,0 +1,99 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wbts/internal/auth"
+)
+
+func TestIsNotModified(t *testing.T) {
+	lastModified := time.Date(2021, 11, 26, 6, 22, 19, 500_000_000, time.UTC)
+	tests := []struct {
+		name            string
+		ifNoneMatch     string
+		ifModifiedSince string
+		etag            string
+		want            bool
+	}{
+		{name: "no conditions", etag: `"a"`},
+		{name: "matching etag", ifNoneMatch: `"a"`, etag: `"a"`, want: true},
+		{name: "one of several", ifNoneMatch: `"b", "a"`, etag: `"a"`, want: true},
+		{name: "wildcard", ifNoneMatch: "*", etag: `"a"`, want: true},
+		{name: "other etag", ifNoneMatch: `"b"`, etag: `"a"`},
+		{name: "weak comparison", ifNoneMatch: `W/"a"`, etag: `"a"`, want: true},
+		{name: "weak etag", ifNoneMatch: `"a"`, etag: `W/"a"`, want: true},
+		{name: "not modified since", ifModifiedSince: "Fri, 26 Nov 2021 06:22:19 GMT", etag: `"a"`, want: true},
+		{name: "modified since", ifModifiedSince: "Fri, 26 Nov 2021 06:22:18 GMT", etag: `"a"`},
+		{name: "invalid date", ifModifiedSince: "yesterday", etag: `"a"`},
+		{
+			name:            "If-None-Match wins over If-Modified-Since",
+			ifNoneMatch:     `"b"`,
+			ifModifiedSince: "Fri, 26 Nov 2021 06:22:19 GMT",
+			etag:            `"a"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/order/test", nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			if tt.ifModifiedSince != "" {
+				req.Header.Set("If-Modified-Since", tt.ifModifiedSince)
+			}
+			if got := isNotModified(req, tt.etag, lastModified); got != tt.want {
+				t.Errorf("isNotModified = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRevalidateAcrossEncodings checks that a validator from one content coding revalidates
+// the same representation fetched with another one.
+func TestRevalidateAcrossEncodings(t *testing.T) {
+	server := newContractServer(t)
+	get := func(target string, acceptEncoding string, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-Scopes", auth.ScopeOrdersRead)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	identity := get("/order/full", "identity", "")
+	gzip := get("/order/full", "gzip", "")
+	if identity.Code != http.StatusOK || gzip.Code != http.StatusOK {
+		t.Fatalf("status = %d, %d", identity.Code, gzip.Code)
+	}
+	strong, weak := identity.Header().Get("ETag"), gzip.Header().Get("ETag")
+	if weak != "W/"+strong {
+		t.Fatalf("gzip ETag = %s, want the weak form of %s", weak, strong)
+	}
+
+	for _, encoding := range []string{"identity", "gzip", "br", "zstd"} {
+		for _, etag := range []string{strong, weak} {
+			rec := get("/order/full", encoding, etag)
+			if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+				t.Errorf("%s with If-None-Match %s = %d, %d bytes, want an empty 304", encoding, etag, rec.Code, rec.Body.Len())
+			}
+			if rec.Header().Get("Content-Encoding") != "" {
+				t.Errorf("%s: 304 has Content-Encoding %s", encoding, rec.Header().Get("Content-Encoding"))
+			}
+		}
+	}
+
+	if rec := get("/order/anonymized", "gzip", weak); rec.Code != http.StatusOK {
+		t.Errorf("other order with the same validator = %d, want 200", rec.Code)
+	}
+	if rec := get("/order/full?fields=order_uid", "gzip", weak); rec.Code != http.StatusOK {
+		t.Errorf("projection with the full order's validator = %d, want 200", rec.Code)
+	}
+}
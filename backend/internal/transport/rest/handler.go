@@ -1,14 +1,30 @@
 package rest
 
 import (
-	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"wbts/internal/domain/dto"
+	"wbts/internal/domain/entity"
+	"wbts/internal/pkg"
+)
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 500
 )
 
 type OrderService interface {
-	Get(order_uid string) (dto.OrderDTO, error)
+	GetEncoded(order_uid string, view string, format string, encoding string) (dto.EncodedOrderDTO, error)
+	GetEncodedProjection(
+		order_uid string,
+		view string,
+		fields []string,
+		format string,
+		encoding string,
+	) (dto.EncodedOrderDTO, error)
+	Search(search dto.OrderSearchDTO, view string, limit int) ([]dto.OrderDTO, error)
 }
 
 type OrderHandler struct {
@@ -20,26 +36,74 @@ func NewOrderHandler(orderService OrderService) *OrderHandler {
 }
 
 func (h *OrderHandler) GetOrderHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		w.Header().Set("Allow", http.MethodGet)
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-	}
 	order_uid := r.PathValue("order_uid")
 
-	order, err := h.orderService.Get(order_uid)
+	format, encoding := negotiatedFrom(r.Context())
+
+	var order dto.EncodedOrderDTO
+	var err error
+	if fields := pkg.ParseFields(r.URL.Query().Get("fields")); len(fields) > 0 {
+		order, err = h.orderService.GetEncodedProjection(order_uid, orderView(r), fields, format, encoding)
+	} else {
+		order, err = h.orderService.GetEncoded(order_uid, orderView(r), format, encoding)
+	}
+	if errors.Is(err, pkg.ErrInvalidField) {
+		http.Error(w, "Invalid fields parameter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, entity.ErrOrderNotFound) {
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Error getting order by uid: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	body, err := json.MarshalIndent(order, "", "    ")
-	if err != nil {
-		http.Error(w, "Failed to marshal JSON", http.StatusInternalServerError)
+	setCacheHeaders(w, order.ETag, order.LastModified)
+	if isNotModified(r, order.ETag, order.LastModified) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
-	if _, err := w.Write([]byte(body)); err != nil {
+	if encoding != dto.EncodingIdentity {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	if _, err := w.Write(order.Body); err != nil {
 		http.Error(w, "Failed to write response", http.StatusInternalServerError)
 		return
 	}
 }
+
+func (h *OrderHandler) SearchOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	search := dto.OrderSearchDTO{
+		CustomerID: query.Get("customer_id"),
+		Phone:      query.Get("phone"),
+		Email:      query.Get("email"),
+		Tenant:     query.Get("tenant"),
+	}
+	if search.IsEmpty() {
+		http.Error(w, "At least one of customer_id, phone or email is required, tenant only narrows the search", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultSearchLimit
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(parsed, maxSearchLimit)
+	}
+
+	orders, err := h.orderService.Search(search, orderView(r), limit)
+	if err != nil {
+		http.Error(w, "Error searching orders: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	writeValue(w, r, orders)
+}
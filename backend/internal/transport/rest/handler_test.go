@@ -0,0 +1,38 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"wbts/internal/auth"
+)
+
+func TestSearchNeedsACriterion(t *testing.T) {
+	server := newContractServer(t)
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{"/orders", http.StatusBadRequest},
+		{"/orders?tenant=default", http.StatusBadRequest},
+		{"/orders?customer_id=test&tenant=default", http.StatusOK},
+		{"/orders?email=test@gmail.com", http.StatusOK},
+		{"/orders?phone=%2B9720000000&limit=0", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		req.Header.Set("X-Scopes", auth.ScopeOrdersRead)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("GET %s = %d, want %d: %s", tt.target, rec.Code, tt.want, rec.Body)
+		}
+		if strings.Contains(tt.target, "tenant") && rec.Code == http.StatusBadRequest &&
+			!strings.Contains(rec.Body.String(), "tenant only narrows the search") {
+			t.Errorf("GET %s: error does not explain the tenant filter: %s", tt.target, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+type HealthCheck struct {
+	Name string
+	// Critical checks make the instance not ready; others only report a degraded state,
+	// e.g. Kafka being down does not stop the API from serving orders from cache and DB.
+	Critical bool
+	Probe    func(ctx context.Context) error
+}
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type HealthHandler struct {
+	checks []HealthCheck
+}
+
+func NewHealthHandler(checks ...HealthCheck) *HealthHandler {
+	return &HealthHandler{checks}
+}
+
+func (h *HealthHandler) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeValue(w, r, healthResponse{Status: "ok"})
+}
+
+func (h *HealthHandler) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	response := h.check(r.Context())
+	status := http.StatusOK
+	if response.Status == "unavailable" {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	writeValue(w, r, response)
+}
+
+// Ready returns an error when a critical check fails, the condition /readyz reports with 503.
+func (h *HealthHandler) Ready(ctx context.Context) error {
+	response := h.check(ctx)
+	if response.Status != "unavailable" {
+		return nil
+	}
+
+	var errs []error
+	for _, check := range h.checks {
+		if result := response.Checks[check.Name]; check.Critical && result != "ok" {
+			errs = append(errs, fmt.Errorf("%s: %s", check.Name, result))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *HealthHandler) check(ctx context.Context) healthResponse {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	response := healthResponse{Status: "ok", Checks: make(map[string]string, len(h.checks))}
+	for _, check := range h.checks {
+		err := check.Probe(ctx)
+		if err == nil {
+			response.Checks[check.Name] = "ok"
+			continue
+		}
+
+		response.Checks[check.Name] = err.Error()
+		if check.Critical {
+			response.Status = "unavailable"
+		} else if response.Status == "ok" {
+			response.Status = "degraded"
+		}
+	}
+	return response
+}
@@ -0,0 +1,74 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func probe(err error) func(context.Context) error {
+	return func(context.Context) error { return err }
+}
+
+func TestReadiness(t *testing.T) {
+	down := errors.New("connection refused")
+	tests := []struct {
+		name       string
+		checks     []HealthCheck
+		wantCode   int
+		wantStatus string
+	}{
+		{"all ok", []HealthCheck{
+			{Name: "postgres", Critical: true, Probe: probe(nil)},
+			{Name: "kafka", Probe: probe(nil)},
+		}, http.StatusOK, "ok"},
+		{"kafka down", []HealthCheck{
+			{Name: "postgres", Critical: true, Probe: probe(nil)},
+			{Name: "kafka", Probe: probe(down)},
+		}, http.StatusOK, "degraded"},
+		{"postgres down", []HealthCheck{
+			{Name: "postgres", Critical: true, Probe: probe(down)},
+			{Name: "kafka", Probe: probe(down)},
+		}, http.StatusServiceUnavailable, "unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := NewHealthHandler(tt.checks...)
+			rec := httptest.NewRecorder()
+			Negotiate(http.HandlerFunc(health.ReadinessHandler)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			var body healthResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode %q: %v", rec.Body.String(), err)
+			}
+			if rec.Code != tt.wantCode || body.Status != tt.wantStatus {
+				t.Errorf("readyz = %d %s, want %d %s", rec.Code, body.Status, tt.wantCode, tt.wantStatus)
+			}
+			if rec.Header().Get("Cache-Control") != "no-store" {
+				t.Errorf("Cache-Control = %q, want no-store", rec.Header().Get("Cache-Control"))
+			}
+			for _, check := range tt.checks {
+				want := "ok"
+				if check.Probe(context.Background()) != nil {
+					want = down.Error()
+				}
+				if body.Checks[check.Name] != want {
+					t.Errorf("check %s = %q, want %q", check.Name, body.Checks[check.Name], want)
+				}
+			}
+
+			// Ready fails for exactly the conditions /readyz reports with 503, naming the critical checks.
+			err := health.Ready(context.Background())
+			if (err != nil) != (tt.wantCode == http.StatusServiceUnavailable) {
+				t.Errorf("Ready = %v", err)
+			}
+			if err != nil && (!strings.Contains(err.Error(), "postgres") || strings.Contains(err.Error(), "kafka")) {
+				t.Errorf("Ready = %v, want only the critical check", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package rest
+
+import (
+	"context"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"wbts/internal/domain/dto"
+	"wbts/internal/pkg"
+)
+
+type negotiationKey struct{}
+
+type negotiated struct {
+	format   string
+	encoding string
+}
+
+type mediaOffer struct {
+	mediaType string
+	params    map[string]string
+	format    string
+}
+
+var mediaOffers = []mediaOffer{
+	{"application/json", nil, dto.FormatJSON},
+	{"application/json", map[string]string{"indent": "none"}, dto.FormatCompactJSON},
+	{"application/msgpack", nil, dto.FormatMsgPack},
+	{"application/x-msgpack", nil, dto.FormatMsgPack},
+	{"application/vnd.msgpack", nil, dto.FormatMsgPack},
+	{"application/cbor", nil, dto.FormatCBOR},
+	{"text/event-stream", nil, dto.FormatCompactJSON},
+}
+
+var encodingOffers = []string{dto.EncodingZstd, dto.EncodingBrotli, dto.EncodingGzip, dto.EncodingIdentity}
+
+type qualityValue struct {
+	value  string
+	params map[string]string
+	q      float64
+}
+
+func Negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		format, ok := negotiateFormat(r.Header.Get("Accept"))
+		if !ok {
+			http.Error(w, "Not Acceptable", http.StatusNotAcceptable)
+			return
+		}
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+
+		w.Header().Set("Content-Type", pkg.ContentType(format))
+		ctx := context.WithValue(r.Context(), negotiationKey{}, negotiated{format, encoding})
+
+		cw := &compressResponseWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+		next.ServeHTTP(cw, r.WithContext(ctx))
+	})
+}
+
+func negotiatedFrom(ctx context.Context) (string, string) {
+	if n, ok := ctx.Value(negotiationKey{}).(negotiated); ok {
+		return n.format, n.encoding
+	}
+	return dto.FormatJSON, dto.EncodingIdentity
+}
+
+func writeValue(w http.ResponseWriter, r *http.Request, v any) {
+	format, _ := negotiatedFrom(r.Context())
+	body, err := pkg.Marshal(format, v)
+	if err != nil {
+		http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+func negotiateFormat(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return dto.FormatJSON, true
+	}
+
+	ranges := parseQualityList(accept)
+	best, bestQ := "", 0.0
+	for _, offer := range mediaOffers {
+		q, specificity := 0.0, -1
+		for _, rng := range ranges {
+			s, ok := matchMediaRange(rng, offer)
+			if ok && s > specificity {
+				q, specificity = rng.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer.format, q
+		}
+	}
+	return best, best != ""
+}
+
+func matchMediaRange(rng qualityValue, offer mediaOffer) (int, bool) {
+	rngType, rngSubtype, _ := strings.Cut(rng.value, "/")
+	offerType, offerSubtype, _ := strings.Cut(offer.mediaType, "/")
+
+	switch {
+	case rngType == "*" && rngSubtype == "*":
+	case rngType == offerType && rngSubtype == "*":
+	case rngType == offerType && rngSubtype == offerSubtype:
+	default:
+		return 0, false
+	}
+
+	for k, v := range rng.params {
+		if offer.params[k] != v {
+			return 0, false
+		}
+	}
+
+	specificity := len(rng.params)
+	if rngType != "*" {
+		specificity += 10
+	}
+	if rngSubtype != "*" {
+		specificity += 100
+	}
+	return specificity, true
+}
+
+func negotiateEncoding(acceptEncoding string) string {
+	if strings.TrimSpace(acceptEncoding) == "" {
+		return dto.EncodingIdentity
+	}
+
+	codings := parseQualityList(acceptEncoding)
+	best, bestQ := dto.EncodingIdentity, 0.0
+	for _, offer := range encodingOffers {
+		q, explicit := 0.0, false
+		for _, c := range codings {
+			if c.value == offer {
+				q, explicit = c.q, true
+				break
+			}
+		}
+		if !explicit {
+			for _, c := range codings {
+				if c.value == "*" {
+					q, explicit = c.q, true
+				}
+			}
+		}
+		if !explicit && offer == dto.EncodingIdentity {
+			q = 0.001
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+func parseQualityList(header string) []qualityValue {
+	var values []qualityValue
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		value, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			value, _, _ = strings.Cut(part, ";")
+			value = strings.ToLower(strings.TrimSpace(value))
+			params = map[string]string{}
+		}
+
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(raw, 64); err == nil {
+				q = parsed
+			}
+			delete(params, "q")
+		}
+		values = append(values, qualityValue{value, params, q})
+	}
+
+	sort.SliceStable(values, func(i, j int) bool { return values[i].q > values[j].q })
+	return values
+}
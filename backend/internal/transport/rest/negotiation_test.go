@@ -0,0 +1,134 @@
+package rest
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"wbts/internal/domain/dto"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+		wantOK bool
+	}{
+		{"", dto.FormatJSON, true},
+		{"*/*", dto.FormatJSON, true},
+		{"application/json", dto.FormatJSON, true},
+		{"application/json; indent=none", dto.FormatCompactJSON, true},
+		{"application/json; indent=tabs", "", false},
+		{"application/msgpack", dto.FormatMsgPack, true},
+		{"application/x-msgpack", dto.FormatMsgPack, true},
+		{"application/cbor;q=0.9, application/msgpack;q=0.5", dto.FormatCBOR, true},
+		{"application/json;q=0.1, application/cbor", dto.FormatCBOR, true},
+		{"application/*;q=0.2, application/json;q=0", dto.FormatMsgPack, true},
+		{"*/*;q=0.1, application/json;q=0", dto.FormatMsgPack, true},
+		{"text/event-stream", dto.FormatCompactJSON, true},
+		{"text/html", "", false},
+		{"application/json;q=0", "", false},
+		{"application/xml, text/*;q=0", "", false},
+	}
+
+	for _, tt := range tests {
+		format, ok := negotiateFormat(tt.accept)
+		if format != tt.want || ok != tt.wantOK {
+			t.Errorf("negotiateFormat(%q) = %q, %t, want %q, %t", tt.accept, format, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"", dto.EncodingIdentity},
+		{"identity", dto.EncodingIdentity},
+		{"gzip", dto.EncodingGzip},
+		{"gzip, deflate, br, zstd", dto.EncodingZstd},
+		{"gzip, deflate, br", dto.EncodingBrotli},
+		{"gzip;q=1.0, br;q=0.5", dto.EncodingGzip},
+		{"zstd;q=0, br;q=0.1", dto.EncodingBrotli},
+		{"*", dto.EncodingZstd},
+		{"*;q=0.5, gzip", dto.EncodingGzip},
+		{"deflate", dto.EncodingIdentity},
+		{"identity;q=0, gzip", dto.EncodingGzip},
+		{"identity;q=0, *;q=0.2, zstd;q=0", dto.EncodingBrotli},
+		// Nothing acceptable is offered: the body is sent unencoded rather than refused.
+		{"identity;q=0", dto.EncodingIdentity},
+		{"*;q=0", dto.EncodingIdentity},
+	}
+
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.acceptEncoding); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.acceptEncoding, got, tt.want)
+		}
+	}
+}
+
+func TestNegotiateMiddleware(t *testing.T) {
+	body := strings.Repeat(`{"order_uid":"b563feb7b2b84b6test"}`, 50)
+	handler := Negotiate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/not-modified":
+			w.WriteHeader(http.StatusNotModified)
+		case "/stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {}\n\n")
+		default:
+			w.Header().Set("ETag", `"a"`)
+			io.WriteString(w, body)
+		}
+	}))
+	serve := func(target string, accept string, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept", accept)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("/order/test", "text/html", "gzip")
+	if rec.Code != http.StatusNotAcceptable {
+		t.Errorf("unsupported Accept = %d, want 406", rec.Code)
+	}
+
+	rec = serve("/order/test", "application/cbor", "gzip")
+	if got := rec.Header().Get("Content-Type"); got != "application/cbor" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if rec.Header().Get("Content-Encoding") != dto.EncodingGzip || rec.Header().Get("ETag") != `W/"a"` {
+		t.Fatalf("headers = %v, want gzip with a weak ETag", rec.Header())
+	}
+	gr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	if decoded, err := io.ReadAll(gr); err != nil || string(decoded) != body {
+		t.Errorf("decoded body = %q, %v", decoded, err)
+	}
+	if vary := rec.Header().Values("Vary"); !strings.Contains(strings.Join(vary, ","), "Accept-Encoding") {
+		t.Errorf("Vary = %v", vary)
+	}
+
+	rec = serve("/order/test", "", "identity;q=0, br")
+	if rec.Header().Get("Content-Encoding") != dto.EncodingBrotli {
+		t.Errorf("Content-Encoding = %q, want br", rec.Header().Get("Content-Encoding"))
+	}
+
+	for _, target := range []string{"/not-modified", "/stream"} {
+		rec = serve(target, "", "gzip")
+		if got := rec.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("%s: Content-Encoding = %q, want none", target, got)
+		}
+	}
+	if rec.Body.String() != "data: {}\n\n" {
+		t.Errorf("event stream body = %q", rec.Body)
+	}
+}
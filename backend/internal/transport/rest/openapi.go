@@ -0,0 +1,338 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"wbts/internal/auth"
+	"wbts/internal/domain/dto"
+)
+
+type openAPIOperation struct {
+	method   string
+	path     string
+	id       string
+	summary  string
+	scope    string
+	params   []map[string]any
+	response reflect.Type
+	produces []string
+	// projection is set for operations whose fields parameter narrows the response to a
+	// subset of properties.
+	projection bool
+}
+
+var openAPIOperations = []openAPIOperation{
+	{
+		method:  http.MethodGet,
+		path:    "/order/{order_uid}",
+		id:      "getOrder",
+		summary: "Get order by uid",
+		scope:   auth.ScopeOrdersRead,
+		params: []map[string]any{
+			pathParam("order_uid"),
+			queryParam("fields", "Comma-separated list of top-level or nested fields, e.g. order_uid,delivery.city", false),
+		},
+		response:   reflect.TypeFor[dto.OrderDTO](),
+		projection: true,
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/orders",
+		id:      "searchOrders",
+		summary: "Search orders by customer, phone or email",
+		scope:   auth.ScopeOrdersRead,
+		params: []map[string]any{
+			queryParam("customer_id", "Customer identifier", false),
+			queryParam("phone", "Recipient phone", false),
+			queryParam("email", "Recipient email", false),
+			queryParam("tenant", "Narrows the search to one tenant; not a search criterion on its own", false),
+			queryParam("limit", "Maximum number of orders (default 50, max 500)", false),
+		},
+		response: reflect.TypeFor[[]dto.OrderDTO](),
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/orders/stream",
+		id:      "streamOrders",
+		summary: "Subscribe to order updates (Server-Sent Events)",
+		scope:   auth.ScopeOrdersRead,
+		params: []map[string]any{
+			queryParam("order_uid", "Order uid, may be repeated", false),
+			queryParam("customer_id", "Customer identifier", false),
+			queryParam("delivery_service", "Delivery service", false),
+			queryParam("tenant", "Tenant the orders were consumed for", false),
+		},
+		response: reflect.TypeFor[dto.OrderDTO](),
+		produces: []string{"text/event-stream"},
+	},
+	{
+		method:   http.MethodGet,
+		path:     "/admin/customers/{customer_id}/export",
+		id:       "exportCustomer",
+		summary:  "Export all orders of a customer",
+		scope:    auth.ScopeOrdersAdmin,
+		params:   []map[string]any{pathParam("customer_id")},
+		response: reflect.TypeFor[dto.CustomerExportDTO](),
+	},
+	{
+		method:   http.MethodPost,
+		path:     "/admin/customers/{customer_id}/anonymize",
+		id:       "anonymizeCustomer",
+		summary:  "Anonymize personal data of a customer",
+		scope:    auth.ScopeOrdersAdmin,
+		params:   []map[string]any{pathParam("customer_id")},
+		response: reflect.TypeFor[dto.CustomerAnonymizationDTO](),
+	},
+}
+
+var negotiatedMediaTypes = []string{"application/json", "application/msgpack", "application/cbor"}
+
+// rewrittenTypes are masked or anonymized after validation, so the input bounds of their
+// validate tags do not hold for responses.
+var rewrittenTypes = map[reflect.Type]bool{
+	reflect.TypeFor[dto.DeliveryDTO](): true,
+}
+
+func pathParam(name string) map[string]any {
+	return map[string]any{"name": name, "in": "path", "required": true, "schema": map[string]any{"type": "string"}}
+}
+
+func queryParam(name string, description string, required bool) map[string]any {
+	return map[string]any{
+		"name":        name,
+		"in":          "query",
+		"required":    required,
+		"description": description,
+		"schema":      map[string]any{"type": "string"},
+	}
+}
+
+// BuildOpenAPI describes the REST API. Schemas are derived from the dto structs, so field
+// names and validation bounds cannot diverge from what the handlers serialize.
+func BuildOpenAPI() map[string]any {
+	schemas := make(map[string]any)
+	paths := make(map[string]any)
+
+	for _, op := range openAPIOperations {
+		produces := op.produces
+		if produces == nil {
+			produces = negotiatedMediaTypes
+		}
+		schema := schemaFor(op.response, schemas, false)
+		if op.projection {
+			schema = map[string]any{"anyOf": []any{schema, schemaFor(op.response, schemas, true)}}
+		}
+		content := make(map[string]any)
+		for _, mediaType := range produces {
+			content[mediaType] = map[string]any{"schema": schema}
+		}
+
+		item, _ := paths[op.path].(map[string]any)
+		if item == nil {
+			item = make(map[string]any)
+			paths[op.path] = item
+		}
+		item[strings.ToLower(op.method)] = map[string]any{
+			"operationId": op.id,
+			"summary":     op.summary,
+			"parameters":  op.params,
+			"security":    []map[string][]string{{"bearer": {op.scope}}, {"apiKey": {op.scope}}},
+			"responses": map[string]any{
+				"200": map[string]any{"description": "OK", "content": content},
+				"400": map[string]any{"description": "Invalid request"},
+				"401": map[string]any{"description": "Missing or invalid credentials"},
+				"403": map[string]any{"description": "Missing scope " + op.scope},
+				"404": map[string]any{"description": "Not found"},
+				"406": map[string]any{"description": "Unsupported Accept header"},
+				"429": map[string]any{"description": "Rate limit exceeded"},
+				"500": map[string]any{"description": "Internal error"},
+			},
+		}
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "WBTS orders API",
+			"version":     "1.0.0",
+			"description": "Delivery fields are masked or redacted unless the caller has the orders:read:pii scope.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+	}
+}
+
+// schemaFor returns the schema of t, registering struct schemas in schemas. Partial schemas
+// describe projections: the same properties, none of them required.
+func schemaFor(t reflect.Type, schemas map[string]any, partial bool) map[string]any {
+	if t == reflect.TypeFor[time.Time]() {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int, reflect.Uint8, reflect.Uint16:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem(), schemas, partial)}
+	case reflect.Pointer:
+		return schemaFor(t.Elem(), schemas, partial)
+	case reflect.Struct:
+		name := strings.TrimSuffix(t.Name(), "DTO")
+		if partial {
+			name += "Projection"
+		}
+		if _, ok := schemas[name]; !ok {
+			schemas[name] = nil
+			schemas[name] = structSchema(t, schemas, partial)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+func structSchema(t reflect.Type, schemas map[string]any, partial bool) map[string]any {
+	properties := make(map[string]any)
+	required := []string{}
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := schemaFor(field.Type, schemas, partial)
+		if _, isRef := schema["$ref"]; !isRef && !rewrittenTypes[t] {
+			applyValidateTag(schema, field.Tag.Get("validate"))
+		}
+		properties[name] = schema
+		if !partial && hasRule(field.Tag.Get("validate"), "required") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func applyValidateTag(schema map[string]any, tag string) {
+	for _, rule := range strings.Split(tag, ",") {
+		key, value, ok := strings.Cut(rule, "=")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case schema["type"] == "string" && key == "min":
+			schema["minLength"] = n
+		case schema["type"] == "string" && key == "max":
+			schema["maxLength"] = n
+		case key == "gte" || key == "min":
+			schema["minimum"] = n
+		case key == "lte" || key == "max":
+			schema["maximum"] = n
+		case key == "gt":
+			schema["exclusiveMinimum"] = n
+		case key == "lt":
+			schema["exclusiveMaximum"] = n
+		}
+	}
+}
+
+func hasRule(tag string, rule string) bool {
+	for _, r := range strings.Split(tag, ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRoutes verifies that every documented operation is routed by the router to a pattern
+// with the same method and path, so a renamed or re-methoded route fails at startup.
+func CheckRoutes(router Router) error {
+	for _, op := range openAPIOperations {
+		target := op.path
+		for _, param := range op.params {
+			if param["in"] == "path" {
+				target = strings.ReplaceAll(target, "{"+param["name"].(string)+"}", "x")
+			}
+		}
+
+		req, err := http.NewRequest(op.method, target, nil)
+		if err != nil {
+			return fmt.Errorf("operation %s: %w", op.id, err)
+		}
+		_, pattern := router.Handler(req)
+		if pattern != op.method+" "+op.path {
+			return fmt.Errorf("operation %s documents %s %s, but it is routed to %q", op.id, op.method, op.path, pattern)
+		}
+	}
+	return nil
+}
+
+func OpenAPIHandler() http.HandlerFunc {
+	spec, err := json.MarshalIndent(BuildOpenAPI(), "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.Write(spec)
+	}
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>WBTS orders API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => { window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" }); };
+  </script>
+</body>
+</html>
+`
+
+func SwaggerUIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUIPage))
+}
@@ -0,0 +1,215 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+
+	"wbts/internal/auth"
+	"wbts/internal/domain/dto"
+	"wbts/internal/domain/entity"
+	"wbts/internal/pkg"
+	"wbts/internal/service"
+)
+
+// fakeOrderRepo keeps orders in memory and implements just enough of service.OrderRepo for
+// the read handlers, so the contract tests exercise the real service, masking and encoding.
+type fakeOrderRepo struct {
+	service.OrderRepo
+	orders map[string]entity.OrderInfo
+}
+
+func (r *fakeOrderRepo) GetByUID(ctx context.Context, order_uid string) (*entity.OrderInfo, error) {
+	info, ok := r.orders[order_uid]
+	if !ok {
+		return nil, entity.ErrOrderNotFound
+	}
+	return &info, nil
+}
+
+func (r *fakeOrderRepo) GetEncoded(
+	ctx context.Context,
+	order_uid string,
+	variant string,
+	encode func(entity.OrderInfo) (dto.EncodedOrderDTO, error),
+) (dto.EncodedOrderDTO, error) {
+	info, err := r.GetByUID(ctx, order_uid)
+	if err != nil {
+		return dto.EncodedOrderDTO{}, err
+	}
+	return encode(*info)
+}
+
+func (r *fakeOrderRepo) FindUIDs(ctx context.Context, lookup entity.OrderLookup, limit int) ([]string, error) {
+	var uids []string
+	for uid := range r.orders {
+		uids = append(uids, uid)
+	}
+	return uids, nil
+}
+
+// testAuthenticator grants the scopes listed in the X-Scopes header.
+type testAuthenticator struct{}
+
+func (testAuthenticator) Authenticate(r *http.Request) (auth.Principal, error) {
+	scopes := r.Header.Get("X-Scopes")
+	if scopes == "" {
+		return auth.Principal{}, auth.ErrNoCredentials
+	}
+	return auth.Principal{Subject: "test", Method: "test", Scopes: strings.Split(scopes, " ")}, nil
+}
+
+func newContractServer(t *testing.T) http.Handler {
+	t.Helper()
+
+	converter := &pkg.OrderConverter{}
+	repo := &fakeOrderRepo{orders: make(map[string]entity.OrderInfo)}
+	for _, order := range []dto.OrderDTO{testOrder("full"), anonymizedOrder(testOrder("anonymized"))} {
+		info, err := converter.OrderDTOToOrderInfo(order)
+		if err != nil {
+			t.Fatalf("convert %s: %v", order.OrderUID, err)
+		}
+		info.Order.UpdatedAt = order.DateCreated
+		repo.orders[order.OrderUID] = info
+	}
+
+	orderHandler := NewOrderHandler(service.NewOrderService(repo, converter, false))
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /order/{order_uid}", RequireScope(auth.ScopeOrdersRead, orderHandler.GetOrderHandler))
+	mux.HandleFunc("GET /orders", RequireScope(auth.ScopeOrdersRead, orderHandler.SearchOrdersHandler))
+	return Authenticate(testAuthenticator{}, Negotiate(mux))
+}
+
+func testOrder(uid string) dto.OrderDTO {
+	return dto.OrderDTO{
+		OrderUID:    uid,
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: dto.DeliveryDTO{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: dto.PaymentDTO{
+			Transaction:  uid,
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []dto.ItemDTO{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			Rid:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+		Locale:            "en",
+		InternalSignature: "sig",
+		CustomerID:        "test",
+		DeliveryService:   "meest",
+		Shardkey:          "9",
+		SmID:              99,
+		DateCreated:       time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:          "1",
+		Tenant:            dto.DefaultTenant,
+	}
+}
+
+// anonymizedOrder mirrors what CustomerService.AnonymizeCustomer stores.
+func anonymizedOrder(order dto.OrderDTO) dto.OrderDTO {
+	order.Delivery = dto.DeliveryDTO{Name: "anonymized", City: order.Delivery.City, Region: order.Delivery.Region}
+	return order
+}
+
+// responseSchema compiles the documented 200 response of an operation.
+func responseSchema(t *testing.T, method string, path string) *jsonschema.Schema {
+	t.Helper()
+
+	spec, err := json.Marshal(BuildOpenAPI())
+	if err != nil {
+		t.Fatalf("marshal spec: %v", err)
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(spec))
+	if err != nil {
+		t.Fatalf("unmarshal spec: %v", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft2020)
+	if err := compiler.AddResource("openapi.json", doc); err != nil {
+		t.Fatalf("add spec: %v", err)
+	}
+	escaped := strings.NewReplacer("~", "~0", "/", "~1", "{", "%7B", "}", "%7D").Replace(path)
+	schema, err := compiler.Compile(
+		"openapi.json#/paths/" + escaped + "/" + strings.ToLower(method) + "/responses/200/content/application~1json/schema",
+	)
+	if err != nil {
+		t.Fatalf("compile %s %s response schema: %v", method, path, err)
+	}
+	return schema
+}
+
+func TestResponsesMatchOpenAPI(t *testing.T) {
+	server := newContractServer(t)
+	getOrder := responseSchema(t, http.MethodGet, "/order/{order_uid}")
+	searchOrders := responseSchema(t, http.MethodGet, "/orders")
+
+	scopes := map[string]string{
+		dto.ViewFull:     auth.ScopeOrdersRead + " " + auth.ScopeOrdersReadPII,
+		dto.ViewMasked:   auth.ScopeOrdersRead + " " + auth.ScopeOrdersReadMasked,
+		dto.ViewRedacted: auth.ScopeOrdersRead,
+	}
+	tests := []struct {
+		target string
+		schema *jsonschema.Schema
+	}{
+		{"/order/full", getOrder},
+		{"/order/anonymized", getOrder},
+		{"/order/full?fields=order_uid,delivery.city,items.price", getOrder},
+		{"/order/anonymized?fields=delivery", getOrder},
+		{"/orders?customer_id=test", searchOrders},
+	}
+
+	for view, scope := range scopes {
+		for _, tt := range tests {
+			t.Run(view+" "+tt.target, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+				req.Header.Set("X-Scopes", scope)
+				rec := httptest.NewRecorder()
+				server.ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("status = %d, body: %s", rec.Code, rec.Body)
+				}
+
+				body, err := jsonschema.UnmarshalJSON(rec.Body)
+				if err != nil {
+					t.Fatalf("decode body: %v", err)
+				}
+				if err := tt.schema.Validate(body); err != nil {
+					t.Errorf("response does not match the OpenAPI document: %v", err)
+				}
+			})
+		}
+	}
+}
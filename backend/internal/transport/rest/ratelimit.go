@@ -0,0 +1,303 @@
+package rest
+
+import (
+	"errors"
+	"expvar"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"wbts/internal/auth"
+)
+
+const (
+	defaultRoute        = "*"
+	bucketIdleTimeout   = 10 * time.Minute
+	bucketSweepInterval = time.Minute
+)
+
+var (
+	rateLimitAllowed   = expvar.NewMap("rate_limit_allowed")
+	rateLimitRejected  = expvar.NewMap("rate_limit_rejected")
+	rateLimitPenalties = expvar.NewMap("rate_limit_penalties")
+)
+
+type RateLimit struct {
+	Rate  rate.Limit
+	Burst int
+}
+
+type Router interface {
+	Handler(r *http.Request) (http.Handler, string)
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+type RateLimiter struct {
+	router         Router
+	ipLimits       map[string]RateLimit
+	keyLimits      map[string]RateLimit
+	penalty        int
+	trustedProxies []*net.IPNet
+	buckets        map[string]*bucket
+	lastSweep      time.Time
+	mtx            sync.Mutex
+}
+
+func NewRateLimiter(
+	router Router,
+	ipLimits map[string]RateLimit,
+	keyLimits map[string]RateLimit,
+	penalty int,
+	trustedProxies []*net.IPNet,
+) *RateLimiter {
+	return &RateLimiter{
+		router:         router,
+		ipLimits:       ipLimits,
+		keyLimits:      keyLimits,
+		penalty:        penalty,
+		trustedProxies: trustedProxies,
+		buckets:        make(map[string]*bucket),
+		lastSweep:      time.Now(),
+	}
+}
+
+func ParseRateLimits(spec string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, value, ok := strings.Cut(entry, "=")
+		rps, burst, ok2 := strings.Cut(value, ":")
+		if !ok || !ok2 {
+			return nil, errors.New("rate limit must look like <route>=<rps>:<burst>, got " + entry)
+		}
+		r, err := strconv.ParseFloat(rps, 64)
+		if err != nil || r <= 0 {
+			return nil, errors.New("invalid rate in " + entry)
+		}
+		b, err := strconv.Atoi(burst)
+		if err != nil || b <= 0 {
+			return nil, errors.New("invalid burst in " + entry)
+		}
+		limits[strings.TrimSpace(route)] = RateLimit{rate.Limit(r), b}
+	}
+	return limits, nil
+}
+
+// Limit applies the per-IP limits. It runs before authentication, so guessing credentials is
+// throttled as well.
+func (l *RateLimiter) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := l.route(r)
+		var limiters []*rate.Limiter
+		if limit, ok := lookupLimit(l.ipLimits, route); ok {
+			limiters = append(limiters, l.limiter("ip|"+route+"|"+l.clientIP(r), limit))
+		}
+		l.serve(w, r, next, route, limiters)
+	})
+}
+
+// LimitPrincipal applies the per-key limits and must run after Authenticate: buckets are only
+// created for callers whose credentials were accepted, so random keys cannot grow the bucket map.
+func (l *RateLimiter) LimitPrincipal(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := l.route(r)
+		var limiters []*rate.Limiter
+		principal, _ := auth.PrincipalFrom(r.Context())
+		if principal.Method != "" && clientCredential(r) {
+			if limit, ok := lookupLimit(l.keyLimits, route); ok {
+				limiters = append(limiters, l.limiter("key|"+route+"|"+principal.Method+":"+principal.Subject, limit))
+			}
+		}
+		l.serve(w, r, next, route, limiters)
+	})
+}
+
+func (l *RateLimiter) route(r *http.Request) string {
+	_, route := l.router.Handler(r)
+	if route == "" {
+		route = defaultRoute
+	}
+	return route
+}
+
+func (l *RateLimiter) serve(w http.ResponseWriter, r *http.Request, next http.Handler, route string, limiters []*rate.Limiter) {
+	if len(limiters) == 0 {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	now := time.Now()
+	reservations := make([]*rate.Reservation, 0, len(limiters))
+	for _, limiter := range limiters {
+		reservation := limiter.ReserveN(now, 1)
+		reservations = append(reservations, reservation)
+		if delay := reservation.DelayFrom(now); !reservation.OK() || delay > 0 {
+			for _, reservation := range reservations {
+				reservation.CancelAt(now)
+			}
+			rateLimitRejected.Add(route, 1)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(max(delay.Seconds(), 1)))))
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+	}
+	rateLimitAllowed.Add(route, 1)
+
+	sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(sw, r)
+
+	if sw.status == http.StatusNotFound && l.penalty > 0 {
+		rateLimitPenalties.Add(route, 1)
+		for _, limiter := range limiters {
+			limiter.ReserveN(time.Now(), min(l.penalty, limiter.Burst()))
+		}
+	}
+}
+
+func (l *RateLimiter) limiter(key string, limit RateLimit) *rate.Limiter {
+	now := time.Now()
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if now.Sub(l.lastSweep) > bucketSweepInterval {
+		for k, b := range l.buckets {
+			if now.Sub(b.lastSeen) > bucketIdleTimeout {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(limit.Rate, limit.Burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+	return b.limiter
+}
+
+// clientIP is the peer address, unless the peer is a trusted proxy. Then X-Forwarded-For is
+// walked from the right and the first hop that is not a trusted proxy is the client: entries
+// to its left were supplied by the client and can be forged.
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !l.trusted(host) {
+		return host
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !l.trusted(hop) {
+			return hop
+		}
+		host = hop
+	}
+	return host
+}
+
+func (l *RateLimiter) trusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range l.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTrustedProxies parses a comma-separated list of proxy networks in CIDR notation;
+// a bare IP address stands for a single host.
+func ParseTrustedProxies(spec string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, errors.New("invalid proxy address " + entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, errors.New("invalid proxy network " + entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func clientCredential(r *http.Request) bool {
+	return r.Header.Get("X-API-Key") != "" || r.Header.Get("Authorization") != ""
+}
+
+func lookupLimit(limits map[string]RateLimit, route string) (RateLimit, bool) {
+	if limit, ok := limits[route]; ok {
+		return limit, true
+	}
+	limit, ok := limits[defaultRoute]
+	return limit, ok
+}
+
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,245 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/time/rate"
+
+	"wbts/internal/auth"
+)
+
+// keyAuthenticator accepts the API keys alice and bob.
+type keyAuthenticator struct{}
+
+func (keyAuthenticator) Authenticate(r *http.Request) (auth.Principal, error) {
+	switch key := r.Header.Get("X-API-Key"); key {
+	case "":
+		return auth.Principal{}, auth.ErrNoCredentials
+	case "alice", "bob":
+		return auth.Principal{Subject: key, Method: "api_key", Scopes: []string{auth.ScopeOrdersRead}}, nil
+	default:
+		return auth.Principal{}, auth.ErrInvalidCredentials
+	}
+}
+
+// newLimitedServer wires the limiter the way cmd/api does. Rates are low enough that no
+// token is refilled while a test runs.
+func newLimitedServer(t *testing.T, ipSpec string, keySpec string, penalty int, proxies string) (http.Handler, *RateLimiter) {
+	t.Helper()
+
+	ipLimits, err := ParseRateLimits(ipSpec)
+	if err != nil {
+		t.Fatalf("ParseRateLimits(%q): %v", ipSpec, err)
+	}
+	keyLimits, err := ParseRateLimits(keySpec)
+	if err != nil {
+		t.Fatalf("ParseRateLimits(%q): %v", keySpec, err)
+	}
+	trustedProxies, err := ParseTrustedProxies(proxies)
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies(%q): %v", proxies, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /order/{order_uid}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("order_uid") == "missing" {
+			http.Error(w, "Order not found", http.StatusNotFound)
+		}
+	})
+	mux.HandleFunc("GET /orders", func(w http.ResponseWriter, r *http.Request) {})
+
+	limiter := NewRateLimiter(mux, ipLimits, keyLimits, penalty, trustedProxies)
+	return limiter.Limit(Authenticate(keyAuthenticator{}, limiter.LimitPrincipal(mux))), limiter
+}
+
+func limitedGet(server http.Handler, target string, remoteAddr string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.RemoteAddr = remoteAddr
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestParseRateLimits(t *testing.T) {
+	limits, err := ParseRateLimits("*=10:20, GET /orders=0.5:1,")
+	if err != nil {
+		t.Fatalf("ParseRateLimits: %v", err)
+	}
+	want := map[string]RateLimit{"*": {10, 20}, "GET /orders": {rate.Limit(0.5), 1}}
+	if len(limits) != len(want) {
+		t.Fatalf("limits = %v, want %v", limits, want)
+	}
+	for route, limit := range want {
+		if limits[route] != limit {
+			t.Errorf("%s = %v, want %v", route, limits[route], limit)
+		}
+	}
+
+	for _, spec := range []string{"*", "*=10", "*=fast:20", "*=0:20", "*=10:0", "*=10:1.5"} {
+		if _, err := ParseRateLimits(spec); err == nil {
+			t.Errorf("ParseRateLimits(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.1, ::1")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+	limiter := NewRateLimiter(http.NewServeMux(), nil, nil, 0, proxies)
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		want         string
+	}{
+		{"direct client", "203.0.113.7:4000", "", "203.0.113.7"},
+		{"spoofed header from an untrusted peer", "203.0.113.7:4000", "198.51.100.1", "203.0.113.7"},
+		{"trusted proxy", "10.1.2.3:4000", "198.51.100.1", "198.51.100.1"},
+		{"single trusted host", "192.168.1.1:4000", "198.51.100.1", "198.51.100.1"},
+		{"host next to the trusted one", "192.168.1.2:4000", "198.51.100.1", "192.168.1.2"},
+		{"ipv6 proxy", "[::1]:4000", "2001:db8::1", "2001:db8::1"},
+		{"chain of trusted proxies", "10.1.2.3:4000", "198.51.100.1, 10.9.9.9", "198.51.100.1"},
+		{"forged hops left of the client", "10.1.2.3:4000", "1.1.1.1, 2.2.2.2, 198.51.100.1", "198.51.100.1"},
+		{"only trusted hops", "10.1.2.3:4000", "10.4.4.4, 10.9.9.9", "10.4.4.4"},
+		{"trusted proxy without header", "10.1.2.3:4000", "", "10.1.2.3"},
+		{"empty hops", "10.1.2.3:4000", " , 198.51.100.1,", "198.51.100.1"},
+		{"peer without port", "203.0.113.7", "198.51.100.1", "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			if got := limiter.clientIP(req); got != tt.want {
+				t.Errorf("clientIP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	for _, spec := range []string{"10.0.0.0/33", "proxy.local"} {
+		if _, err := ParseTrustedProxies(spec); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestIPBuckets(t *testing.T) {
+	server, _ := newLimitedServer(t, "*=0.001:2,GET /orders=0.001:1", "", 0, "10.0.0.0/8")
+	const client = "203.0.113.7:4000"
+
+	for i := range 2 {
+		if rec := limitedGet(server, "/order/a", client); rec.Code != http.StatusOK {
+			t.Fatalf("request %d = %d", i, rec.Code)
+		}
+	}
+	rec := limitedGet(server, "/order/a", client)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("over the burst = %d, Retry-After %q, want 429 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	if rec := limitedGet(server, "/order/a", "203.0.113.8:4000"); rec.Code != http.StatusOK {
+		t.Errorf("another client = %d, want its own bucket", rec.Code)
+	}
+	if rec := limitedGet(server, "/orders", client); rec.Code != http.StatusOK {
+		t.Errorf("another route = %d, want its own bucket", rec.Code)
+	}
+	if rec := limitedGet(server, "/orders", client); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("route limit = %d, want the route's burst of 1", rec.Code)
+	}
+
+	// A client cannot get a fresh bucket by claiming another address.
+	if rec := limitedGet(server, "/order/a", client, "X-Forwarded-For", "198.51.100.1"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("spoofed X-Forwarded-For = %d, want the peer's bucket", rec.Code)
+	}
+	// Behind a trusted proxy every forwarded client has its own bucket.
+	for _, forwarded := range []string{"198.51.100.1", "198.51.100.2"} {
+		for i := range 2 {
+			if rec := limitedGet(server, "/order/a", "10.0.0.1:4000", "X-Forwarded-For", forwarded); rec.Code != http.StatusOK {
+				t.Errorf("%s via the proxy, request %d = %d", forwarded, i, rec.Code)
+			}
+		}
+	}
+}
+
+func TestPrincipalBuckets(t *testing.T) {
+	server, limiter := newLimitedServer(t, "*=0.001:100", "*=0.001:2", 0, "")
+
+	// The key bucket follows the principal across addresses.
+	for _, addr := range []string{"203.0.113.1:4000", "203.0.113.2:4000"} {
+		if rec := limitedGet(server, "/order/a", addr, "X-API-Key", "alice"); rec.Code != http.StatusOK {
+			t.Fatalf("alice from %s = %d", addr, rec.Code)
+		}
+	}
+	if rec := limitedGet(server, "/order/a", "203.0.113.3:4000", "X-API-Key", "alice"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("alice over the burst = %d, want 429", rec.Code)
+	}
+	if rec := limitedGet(server, "/order/a", "203.0.113.3:4000", "X-API-Key", "bob"); rec.Code != http.StatusOK {
+		t.Errorf("bob = %d, want a separate bucket", rec.Code)
+	}
+	// Anonymous callers are only limited per IP.
+	for i := range 3 {
+		if rec := limitedGet(server, "/order/a", "203.0.113.3:4000"); rec.Code != http.StatusOK {
+			t.Errorf("anonymous request %d = %d", i, rec.Code)
+		}
+	}
+
+	// Unknown keys are rejected without a key bucket, so they cannot grow the bucket map.
+	for _, key := range []string{"mallory-1", "mallory-2", "mallory-3"} {
+		if rec := limitedGet(server, "/order/a", "203.0.113.4:4000", "X-API-Key", key); rec.Code != http.StatusUnauthorized {
+			t.Errorf("key %s = %d, want 401", key, rec.Code)
+		}
+	}
+	limiter.mtx.Lock()
+	defer limiter.mtx.Unlock()
+	for key := range limiter.buckets {
+		if strings.HasPrefix(key, "key|") && !strings.HasSuffix(key, ":alice") && !strings.HasSuffix(key, ":bob") {
+			t.Errorf("bucket %s was created for a rejected key", key)
+		}
+	}
+}
+
+func TestNotFoundPenalty(t *testing.T) {
+	tests := []struct {
+		name    string
+		penalty int
+		// allowed is the number of successful requests left after one 404 with a burst of 5.
+		allowed int
+	}{
+		{"no penalty", 0, 4},
+		{"penalty", 3, 1},
+		{"penalty capped by the burst", 10, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := newLimitedServer(t, "*=0.001:5", "", tt.penalty, "")
+			const client = "203.0.113.7:4000"
+
+			if rec := limitedGet(server, "/order/missing", client); rec.Code != http.StatusNotFound {
+				t.Fatalf("missing order = %d, want 404", rec.Code)
+			}
+			allowed := 0
+			for range 6 {
+				if limitedGet(server, "/order/a", client).Code != http.StatusOK {
+					break
+				}
+				allowed++
+			}
+			if allowed != tt.allowed {
+				t.Errorf("%d requests allowed after a 404, want %d", allowed, tt.allowed)
+			}
+		})
+	}
+}
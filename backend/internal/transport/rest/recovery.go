@@ -0,0 +1,43 @@
+package rest
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"runtime/debug"
+)
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			log.Printf("Panic while handling %s %s: %v\n%s", r.Method, r.URL.Path, rec, debug.Stack())
+			writeJSONError(w, http.StatusInternalServerError, "internal server error")
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	h := w.Header()
+	h.Del("Content-Encoding")
+	h.Del("ETag")
+	h.Set("Content-Type", "application/json; charset=utf-8")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(errorResponse{message}); err != nil {
+		log.Printf("Error writing error response: %v", err)
+	}
+}
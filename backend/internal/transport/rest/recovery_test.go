@@ -0,0 +1,52 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	handler := Recover(Negotiate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"a"`)
+		panic("secret connection string")
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/order/test", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	h := rec.Header()
+	if h.Get("Content-Type") != "application/json; charset=utf-8" || h.Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("headers = %v", h)
+	}
+	if h.Get("Content-Encoding") != "" || h.Get("ETag") != "" {
+		t.Errorf("headers of the failed response leaked: %v", h)
+	}
+	if strings.Contains(rec.Body.String(), "secret") {
+		t.Errorf("body reveals the panic: %s", rec.Body)
+	}
+	var body errorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error != "internal server error" {
+		t.Errorf("body = %s, %v", rec.Body, err)
+	}
+}
+
+func TestRecoverKeepsAbortHandler(t *testing.T) {
+	handler := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler to reach the server", rec)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/order/test", nil))
+}
@@ -0,0 +1,66 @@
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type ServerConfig struct {
+	Addr              string
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	MaxBodyBytes      int64
+	TLSCertFile       string
+	TLSKeyFile        string
+}
+
+type Server struct {
+	httpServer *http.Server
+	certFile   string
+	keyFile    string
+}
+
+func NewServer(cfg ServerConfig, handler http.Handler) (*Server, error) {
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, errors.New("both TLS certificate and key files must be set")
+	}
+
+	httpServer := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           Recover(http.MaxBytesHandler(handler, cfg.MaxBodyBytes)),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		TLSConfig:         &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+	return &Server{httpServer, cfg.TLSCertFile, cfg.TLSKeyFile}, nil
+}
+
+func (s *Server) TLSEnabled() bool {
+	return s.certFile != ""
+}
+
+func (s *Server) ListenAndServe() error {
+	var err error
+	if s.TLSEnabled() {
+		err = s.httpServer.ListenAndServeTLS(s.certFile, s.keyFile)
+	} else {
+		err = s.httpServer.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
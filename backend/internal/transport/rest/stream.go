@@ -0,0 +1,88 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"wbts/internal/domain/dto"
+)
+
+const streamHeartbeatInterval = 15 * time.Second
+
+type OrderSubscriber interface {
+	Subscribe(filter func(dto.OrderDTO) bool) (<-chan dto.OrderDTO, func())
+	Mask(order dto.OrderDTO, view string) dto.OrderDTO
+}
+
+type StreamHandler struct {
+	orderSubscriber OrderSubscriber
+}
+
+func NewStreamHandler(orderSubscriber OrderSubscriber) *StreamHandler {
+	return &StreamHandler{orderSubscriber}
+}
+
+func (h *StreamHandler) StreamOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := dto.OrderFilter{
+		OrderUIDs:       query["order_uid"],
+		CustomerID:      query.Get("customer_id"),
+		DeliveryService: query.Get("delivery_service"),
+		Tenant:          query.Get("tenant"),
+	}
+	if filter.IsEmpty() {
+		http.Error(w, "At least one of order_uid, customer_id, delivery_service or tenant is required", http.StatusBadRequest)
+		return
+	}
+
+	view := orderView(r)
+	rc := http.NewResponseController(w)
+	updates, unsubscribe := h.orderSubscriber.Subscribe(filter.Match)
+	defer unsubscribe()
+
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Error disabling write deadline for order stream: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Printf("Streaming is not supported by response writer: %v", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case order, ok := <-updates:
+			if !ok {
+				return
+			}
+			order = h.orderSubscriber.Mask(order, view)
+			body, err := json.Marshal(order)
+			if err != nil {
+				log.Printf("Error marshalling order update: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: order\nid: %s\ndata: %s\n\n", order.OrderUID, body); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
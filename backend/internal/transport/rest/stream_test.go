@@ -0,0 +1,165 @@
+package rest
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"wbts/internal/auth"
+	"wbts/internal/domain/dto"
+	"wbts/internal/pkg"
+	"wbts/internal/service"
+)
+
+// hubSubscriber streams from a real hub and masks with the real converter.
+type hubSubscriber struct {
+	*service.OrderHub
+	converter *pkg.OrderConverter
+}
+
+func (s hubSubscriber) Mask(order dto.OrderDTO, view string) dto.OrderDTO {
+	return s.converter.MaskOrderDTO(order, view)
+}
+
+type streamEvent struct {
+	event string
+	id    string
+	order dto.OrderDTO
+}
+
+// openStream connects to the stream and returns a channel with its events, closed when the
+// server ends the stream. The hub subscription exists once the response headers are received.
+func openStream(t *testing.T, server *httptest.Server, query string, scopes string) <-chan streamEvent {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/orders/stream?"+query, nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("X-Scopes", scopes)
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream = %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan streamEvent)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var ev streamEvent
+		for scanner.Scan() {
+			field, value, _ := strings.Cut(scanner.Text(), ": ")
+			switch field {
+			case "event":
+				ev.event = value
+			case "id":
+				ev.id = value
+			case "data":
+				json.Unmarshal([]byte(value), &ev.order)
+			case "":
+				events <- ev
+				ev = streamEvent{}
+			}
+		}
+	}()
+	return events
+}
+
+func nextEvent(t *testing.T, events <-chan streamEvent) (streamEvent, bool) {
+	t.Helper()
+	select {
+	case ev, ok := <-events:
+		return ev, ok
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+		return streamEvent{}, false
+	}
+}
+
+func newStreamServer(t *testing.T) (*httptest.Server, *service.OrderHub) {
+	t.Helper()
+
+	hub := service.NewOrderHub()
+	streamHandler := NewStreamHandler(hubSubscriber{hub, &pkg.OrderConverter{}})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /orders/stream", RequireScope(auth.ScopeOrdersRead, streamHandler.StreamOrdersHandler))
+	server := httptest.NewServer(Authenticate(testAuthenticator{}, Negotiate(mux)))
+	t.Cleanup(func() {
+		hub.Close()
+		server.Close()
+	})
+	return server, hub
+}
+
+func TestStreamOrders(t *testing.T) {
+	server, hub := newStreamServer(t)
+	masked := openStream(t, server, "order_uid=full", auth.ScopeOrdersRead+" "+auth.ScopeOrdersReadMasked)
+	full := openStream(t, server, "order_uid=full&order_uid=other", auth.ScopeOrdersRead+" "+auth.ScopeOrdersReadPII)
+
+	hub.Publish(testOrder("unwatched"))
+	hub.Publish(testOrder("full"))
+	hub.Publish(testOrder("other"))
+
+	ev, _ := nextEvent(t, masked)
+	if ev.event != "order" || ev.id != "full" || ev.order.OrderUID != "full" {
+		t.Fatalf("event = %+v, want the update of full", ev)
+	}
+	original := testOrder("full").Delivery
+	if ev.order.Delivery.Phone == original.Phone || ev.order.Delivery.Email == original.Email ||
+		ev.order.Delivery.Address == original.Address {
+		t.Errorf("masked stream sent personal data: %+v", ev.order.Delivery)
+	}
+
+	for _, want := range []string{"full", "other"} {
+		ev, _ := nextEvent(t, full)
+		if ev.id != want {
+			t.Fatalf("event %s, want %s", ev.id, want)
+		}
+		if ev.order.Delivery != testOrder(want).Delivery {
+			t.Errorf("full stream masked the delivery: %+v", ev.order.Delivery)
+		}
+	}
+
+	// Closing the hub ends the streams instead of leaving them to the shutdown timeout.
+	hub.Close()
+	for name, events := range map[string]<-chan streamEvent{"masked": masked, "full": full} {
+		if ev, ok := nextEvent(t, events); ok {
+			t.Errorf("%s stream got %+v after the hub was closed", name, ev)
+		}
+	}
+}
+
+func TestStreamRequiresFilterAndScope(t *testing.T) {
+	server, _ := newStreamServer(t)
+	tests := []struct {
+		query  string
+		scopes string
+		want   int
+	}{
+		{"", auth.ScopeOrdersRead, http.StatusBadRequest},
+		{"order_uid=full", "", http.StatusUnauthorized},
+		{"order_uid=full", auth.ScopeOrdersReadPII, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/orders/stream?"+tt.query, nil)
+		if tt.scopes != "" {
+			req.Header.Set("X-Scopes", tt.scopes)
+		}
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", tt.query, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("GET ?%s with %q = %d, want %d", tt.query, tt.scopes, resp.StatusCode, tt.want)
+		}
+	}
+}
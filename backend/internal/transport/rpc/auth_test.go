@@ -0,0 +1,118 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+
+	ordersv1 "wbts/api/orders/v1"
+	"wbts/internal/auth"
+	"wbts/internal/domain/dto"
+)
+
+type fakeAuthenticator map[string][]string
+
+func (a fakeAuthenticator) Authenticate(r *http.Request) (auth.Principal, error) {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		return auth.Principal{}, auth.ErrNoCredentials
+	}
+	scopes, ok := a[key]
+	if !ok {
+		return auth.Principal{}, auth.ErrInvalidCredentials
+	}
+	return auth.Principal{Subject: key, Method: "api_key", Scopes: scopes}, nil
+}
+
+var testKeys = fakeAuthenticator{
+	"pii":     {auth.ScopeOrdersRead, auth.ScopeOrdersReadPII},
+	"reader":  {auth.ScopeOrdersRead},
+	"noscope": {},
+}
+
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+}
+
+func TestAuthScopesAndMasking(t *testing.T) {
+	conn := startServer(t, newFakeOrderService("a"), AuthInterceptors(testKeys)...)
+	client := ordersv1.NewOrderServiceClient(conn)
+	req := &ordersv1.GetOrderRequest{OrderUid: "a"}
+
+	_, err := client.GetOrder(context.Background(), req)
+	assertCode(t, err, codes.Unauthenticated)
+
+	_, err = client.GetOrder(withKey("unknown"), req)
+	assertCode(t, err, codes.Unauthenticated)
+
+	_, err = client.GetOrder(withKey("noscope"), req)
+	assertCode(t, err, codes.PermissionDenied)
+
+	order, err := client.GetOrder(withKey("reader"), req)
+	if err != nil {
+		t.Fatalf("GetOrder without PII scope: %v", err)
+	}
+	if name := order.GetDelivery().GetName(); name != "***" {
+		t.Errorf("delivery name without PII scope = %q, want masked", name)
+	}
+
+	order, err = client.GetOrder(withKey("pii"), req)
+	if err != nil {
+		t.Fatalf("GetOrder with PII scope: %v", err)
+	}
+	if name := order.GetDelivery().GetName(); name != "Ivan Ivanov" {
+		t.Errorf("delivery name with PII scope = %q, want full name", name)
+	}
+
+	list, err := client.ListOrders(withKey("reader"), &ordersv1.ListOrdersRequest{})
+	if err != nil {
+		t.Fatalf("ListOrders: %v", err)
+	}
+	if name := list.GetOrders()[0].GetDelivery().GetName(); name != "***" {
+		t.Errorf("listed delivery name without PII scope = %q, want masked", name)
+	}
+
+	health := healthpb.NewHealthClient(conn)
+	if _, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("health check without credentials: %v", err)
+	}
+}
+
+func TestAuthStream(t *testing.T) {
+	orderService := newFakeOrderService()
+	client := ordersv1.NewOrderServiceClient(startServer(t, orderService, AuthInterceptors(testKeys)...))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchOrders(ctx, &ordersv1.WatchOrdersRequest{CustomerId: "c1"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	assertCode(t, err, codes.Unauthenticated)
+
+	stream, err = client.WatchOrders(metadata.AppendToOutgoingContext(ctx, "x-api-key", "reader"), &ordersv1.WatchOrdersRequest{CustomerId: "c1"})
+	if err != nil {
+		t.Fatalf("WatchOrders: %v", err)
+	}
+	<-orderService.subscribed
+	orderService.hub.Publish(dto.OrderDTO{OrderUID: "x", CustomerID: "c1", Delivery: dto.DeliveryDTO{Name: "Ivan Ivanov"}})
+	order, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	if name := order.GetDelivery().GetName(); name != "***" {
+		t.Errorf("streamed delivery name without PII scope = %q, want masked", name)
+	}
+}
+
+func TestAuthWithoutAuthenticatorDenies(t *testing.T) {
+	client := ordersv1.NewOrderServiceClient(startServer(t, newFakeOrderService("a"), AuthInterceptors(nil)...))
+
+	_, err := client.GetOrder(context.Background(), &ordersv1.GetOrderRequest{OrderUid: "a"})
+	assertCode(t, err, codes.Unauthenticated)
+}
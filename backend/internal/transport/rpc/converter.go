@@ -0,0 +1,63 @@
+package rpc
+
+import (
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	ordersv1 "wbts/api/orders/v1"
+	"wbts/internal/domain/dto"
+)
+
+func orderToProto(order dto.OrderDTO) *ordersv1.Order {
+	items := make([]*ordersv1.Item, 0, len(order.Items))
+	for _, item := range order.Items {
+		items = append(items, &ordersv1.Item{
+			ChrtId:      item.ChrtID,
+			TrackNumber: item.TrackNumber,
+			Price:       item.Price,
+			Rid:         item.Rid,
+			Name:        item.Name,
+			Sale:        int32(item.Sale),
+			Size:        item.Size,
+			TotalPrice:  item.TotalPrice,
+			NmId:        item.NmID,
+			Brand:       item.Brand,
+			Status:      int64(item.Status),
+		})
+	}
+
+	return &ordersv1.Order{
+		OrderUid:    order.OrderUID,
+		TrackNumber: order.TrackNumber,
+		Entry:       order.Entry,
+		Delivery: &ordersv1.Delivery{
+			Name:    order.Delivery.Name,
+			Phone:   order.Delivery.Phone,
+			Zip:     order.Delivery.Zip,
+			City:    order.Delivery.City,
+			Address: order.Delivery.Address,
+			Region:  order.Delivery.Region,
+			Email:   order.Delivery.Email,
+		},
+		Payment: &ordersv1.Payment{
+			Transaction:  order.Payment.Transaction,
+			RequestId:    order.Payment.RequestID,
+			Currency:     order.Payment.Currency,
+			Provider:     order.Payment.Provider,
+			Amount:       order.Payment.Amount,
+			PaymentDt:    order.Payment.PaymentDt,
+			Bank:         order.Payment.Bank,
+			DeliveryCost: order.Payment.DeliveryCost,
+			GoodsTotal:   order.Payment.GoodsTotal,
+			CustomFee:    order.Payment.CustomFee,
+		},
+		Items:             items,
+		Locale:            order.Locale,
+		InternalSignature: order.InternalSignature,
+		CustomerId:        order.CustomerID,
+		DeliveryService:   order.DeliveryService,
+		Shardkey:          order.Shardkey,
+		SmId:              order.SmID,
+		DateCreated:       timestamppb.New(order.DateCreated),
+		OofShard:          order.OofShard,
+	}
+}
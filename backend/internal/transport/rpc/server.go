@@ -0,0 +1,207 @@
+package rpc
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	ordersv1 "wbts/api/orders/v1"
+	"wbts/internal/auth"
+	"wbts/internal/domain/dto"
+	"wbts/internal/domain/entity"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+	maxBatchSize    = 500
+)
+
+type OrderService interface {
+	Get(order_uid string) (dto.OrderDTO, error)
+	List(after string, limit int) ([]dto.OrderDTO, error)
+	Subscribe(filter func(dto.OrderDTO) bool) (<-chan dto.OrderDTO, func())
+	Mask(order dto.OrderDTO, view string) dto.OrderDTO
+}
+
+type OrderServer struct {
+	ordersv1.UnimplementedOrderServiceServer
+	orderService OrderService
+}
+
+func NewOrderServer(orderService OrderService) *OrderServer {
+	return &OrderServer{orderService: orderService}
+}
+
+// Server is the gRPC server with the order, health and reflection services registered.
+type Server struct {
+	*grpc.Server
+	health *health.Server
+}
+
+// NewServer registers the services. The health service reports NOT_SERVING until WatchHealth
+// has seen the instance ready.
+func NewServer(orderServer *OrderServer, opts ...grpc.ServerOption) *Server {
+	server := &Server{grpc.NewServer(opts...), health.NewServer()}
+	ordersv1.RegisterOrderServiceServer(server, orderServer)
+
+	server.setServing(false)
+	healthpb.RegisterHealthServer(server, server.health)
+
+	reflection.Register(server)
+	return server
+}
+
+// WatchHealth reports the result of ready through the health service, re-checking it every
+// interval, so gRPC clients see the same readiness as /readyz. When ctx is done every service
+// is reported NOT_SERVING.
+func (s *Server) WatchHealth(ctx context.Context, ready func(ctx context.Context) error, interval time.Duration) {
+	defer s.health.Shutdown()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	serving := false
+	for {
+		err := ready(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if (err == nil) != serving {
+			serving = err == nil
+			if !serving {
+				log.Printf("gRPC health is NOT_SERVING: %v", err)
+			}
+			s.setServing(serving)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Server) setServing(serving bool) {
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	s.health.SetServingStatus("", status)
+	s.health.SetServingStatus(ordersv1.OrderService_ServiceDesc.ServiceName, status)
+}
+
+func (s *OrderServer) GetOrder(ctx context.Context, req *ordersv1.GetOrderRequest) (*ordersv1.Order, error) {
+	if req.GetOrderUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uid is required")
+	}
+
+	order, err := s.orderService.Get(req.GetOrderUid())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return s.toProto(ctx, order), nil
+}
+
+// toProto converts the order in the view the caller's scopes allow.
+func (s *OrderServer) toProto(ctx context.Context, order dto.OrderDTO) *ordersv1.Order {
+	principal, _ := auth.PrincipalFrom(ctx)
+	return orderToProto(s.orderService.Mask(order, principal.OrderView()))
+}
+
+func (s *OrderServer) BatchGetOrders(ctx context.Context, req *ordersv1.BatchGetOrdersRequest) (*ordersv1.BatchGetOrdersResponse, error) {
+	if len(req.GetOrderUids()) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d order_uids are allowed", maxBatchSize)
+	}
+
+	resp := &ordersv1.BatchGetOrdersResponse{}
+	for _, uid := range req.GetOrderUids() {
+		if err := ctx.Err(); err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+
+		order, err := s.orderService.Get(uid)
+		if errors.Is(err, entity.ErrOrderNotFound) {
+			resp.NotFound = append(resp.NotFound, uid)
+			continue
+		}
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		resp.Orders = append(resp.Orders, s.toProto(ctx, order))
+	}
+	return resp, nil
+}
+
+func (s *OrderServer) ListOrders(ctx context.Context, req *ordersv1.ListOrdersRequest) (*ordersv1.ListOrdersResponse, error) {
+	pageSize := int(req.GetPageSize())
+	switch {
+	case pageSize < 0:
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+
+	after, err := base64.RawURLEncoding.DecodeString(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+
+	orders, err := s.orderService.List(string(after), pageSize)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &ordersv1.ListOrdersResponse{Orders: make([]*ordersv1.Order, 0, len(orders))}
+	for _, order := range orders {
+		resp.Orders = append(resp.Orders, s.toProto(ctx, order))
+	}
+	if len(orders) == pageSize {
+		resp.NextPageToken = base64.RawURLEncoding.EncodeToString([]byte(orders[len(orders)-1].OrderUID))
+	}
+	return resp, nil
+}
+
+func (s *OrderServer) WatchOrders(req *ordersv1.WatchOrdersRequest, stream grpc.ServerStreamingServer[ordersv1.Order]) error {
+	filter := dto.OrderFilter{
+		OrderUIDs:       req.GetOrderUids(),
+		CustomerID:      req.GetCustomerId(),
+		DeliveryService: req.GetDeliveryService(),
+	}
+	updates, unsubscribe := s.orderService.Subscribe(filter.Match)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case order, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(s.toProto(stream.Context(), order)); err != nil {
+				log.Printf("Error sending order update: %v", err)
+				return err
+			}
+		}
+	}
+}
+
+func toStatus(err error) error {
+	if errors.Is(err, entity.ErrOrderNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	log.Printf("Error handling gRPC request: %v", err)
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,273 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	ordersv1 "wbts/api/orders/v1"
+	"wbts/internal/domain/dto"
+	"wbts/internal/domain/entity"
+	"wbts/internal/service"
+)
+
+type fakeOrderService struct {
+	orders     map[string]dto.OrderDTO
+	hub        *service.OrderHub
+	subscribed chan struct{}
+}
+
+func newFakeOrderService(uids ...string) *fakeOrderService {
+	s := &fakeOrderService{
+		orders:     make(map[string]dto.OrderDTO),
+		hub:        service.NewOrderHub(),
+		subscribed: make(chan struct{}, 1),
+	}
+	for _, uid := range uids {
+		s.orders[uid] = dto.OrderDTO{
+			OrderUID:    uid,
+			CustomerID:  "customer-" + uid,
+			Delivery:    dto.DeliveryDTO{Name: "Ivan Ivanov"},
+			DateCreated: time.Unix(0, 0).UTC(),
+		}
+	}
+	return s
+}
+
+func (s *fakeOrderService) Get(order_uid string) (dto.OrderDTO, error) {
+	if order_uid == "broken" {
+		return dto.OrderDTO{}, errors.New("database is down")
+	}
+	order, ok := s.orders[order_uid]
+	if !ok {
+		return dto.OrderDTO{}, entity.ErrOrderNotFound
+	}
+	return order, nil
+}
+
+func (s *fakeOrderService) List(after string, limit int) ([]dto.OrderDTO, error) {
+	uids := make([]string, 0, len(s.orders))
+	for uid := range s.orders {
+		if uid > after {
+			uids = append(uids, uid)
+		}
+	}
+	sort.Strings(uids)
+	if len(uids) > limit {
+		uids = uids[:limit]
+	}
+
+	orders := make([]dto.OrderDTO, 0, len(uids))
+	for _, uid := range uids {
+		orders = append(orders, s.orders[uid])
+	}
+	return orders, nil
+}
+
+func (s *fakeOrderService) Subscribe(filter func(dto.OrderDTO) bool) (<-chan dto.OrderDTO, func()) {
+	updates, unsubscribe := s.hub.Subscribe(filter)
+	s.subscribed <- struct{}{}
+	return updates, unsubscribe
+}
+
+func (s *fakeOrderService) Mask(order dto.OrderDTO, view string) dto.OrderDTO {
+	if view != dto.ViewFull {
+		order.Delivery.Name = "***"
+	}
+	return order
+}
+
+func startServer(t *testing.T, orderService *fakeOrderService, opts ...grpc.ServerOption) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	server := NewServer(NewOrderServer(orderService), opts...)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial bufconn: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func assertCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != code {
+		t.Fatalf("status code = %s, want %s (err: %v)", got, code, err)
+	}
+}
+
+func TestGetOrder(t *testing.T) {
+	client := ordersv1.NewOrderServiceClient(startServer(t, newFakeOrderService("a")))
+	ctx := context.Background()
+
+	order, err := client.GetOrder(ctx, &ordersv1.GetOrderRequest{OrderUid: "a"})
+	if err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if order.GetOrderUid() != "a" || order.GetCustomerId() != "customer-a" {
+		t.Errorf("GetOrder returned %v", order)
+	}
+
+	_, err = client.GetOrder(ctx, &ordersv1.GetOrderRequest{OrderUid: "missing"})
+	assertCode(t, err, codes.NotFound)
+
+	_, err = client.GetOrder(ctx, &ordersv1.GetOrderRequest{})
+	assertCode(t, err, codes.InvalidArgument)
+
+	_, err = client.GetOrder(ctx, &ordersv1.GetOrderRequest{OrderUid: "broken"})
+	assertCode(t, err, codes.Internal)
+}
+
+func TestBatchGetOrders(t *testing.T) {
+	client := ordersv1.NewOrderServiceClient(startServer(t, newFakeOrderService("a", "b")))
+	ctx := context.Background()
+
+	resp, err := client.BatchGetOrders(ctx, &ordersv1.BatchGetOrdersRequest{OrderUids: []string{"a", "missing", "b"}})
+	if err != nil {
+		t.Fatalf("BatchGetOrders: %v", err)
+	}
+	if len(resp.GetOrders()) != 2 || resp.GetOrders()[0].GetOrderUid() != "a" || resp.GetOrders()[1].GetOrderUid() != "b" {
+		t.Errorf("orders = %v, want a and b", resp.GetOrders())
+	}
+	if len(resp.GetNotFound()) != 1 || resp.GetNotFound()[0] != "missing" {
+		t.Errorf("not_found = %v, want [missing]", resp.GetNotFound())
+	}
+
+	_, err = client.BatchGetOrders(ctx, &ordersv1.BatchGetOrdersRequest{OrderUids: make([]string, maxBatchSize+1)})
+	assertCode(t, err, codes.InvalidArgument)
+}
+
+func TestListOrdersPagination(t *testing.T) {
+	client := ordersv1.NewOrderServiceClient(startServer(t, newFakeOrderService("a", "b", "c", "d", "e")))
+	ctx := context.Background()
+
+	var uids []string
+	token := ""
+	pages := 0
+	for {
+		resp, err := client.ListOrders(ctx, &ordersv1.ListOrdersRequest{PageSize: 2, PageToken: token})
+		if err != nil {
+			t.Fatalf("ListOrders: %v", err)
+		}
+		pages++
+		for _, order := range resp.GetOrders() {
+			uids = append(uids, order.GetOrderUid())
+		}
+		if token = resp.GetNextPageToken(); token == "" {
+			break
+		}
+		if pages > 5 {
+			t.Fatal("pagination does not terminate")
+		}
+	}
+
+	if got := len(uids); got != 5 || uids[0] != "a" || uids[4] != "e" {
+		t.Errorf("listed %v, want a..e", uids)
+	}
+	if pages != 3 {
+		t.Errorf("pages = %d, want 3", pages)
+	}
+
+	_, err := client.ListOrders(ctx, &ordersv1.ListOrdersRequest{PageSize: -1})
+	assertCode(t, err, codes.InvalidArgument)
+
+	_, err = client.ListOrders(ctx, &ordersv1.ListOrdersRequest{PageToken: "not base64!"})
+	assertCode(t, err, codes.InvalidArgument)
+}
+
+func TestWatchOrders(t *testing.T) {
+	orderService := newFakeOrderService()
+	client := ordersv1.NewOrderServiceClient(startServer(t, orderService))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchOrders(ctx, &ordersv1.WatchOrdersRequest{CustomerId: "c1"})
+	if err != nil {
+		t.Fatalf("WatchOrders: %v", err)
+	}
+	select {
+	case <-orderService.subscribed:
+	case <-ctx.Done():
+		t.Fatal("server did not subscribe")
+	}
+
+	orderService.hub.Publish(dto.OrderDTO{OrderUID: "other", CustomerID: "c2"})
+	orderService.hub.Publish(dto.OrderDTO{OrderUID: "mine", CustomerID: "c1"})
+
+	order, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	if order.GetOrderUid() != "mine" {
+		t.Errorf("received %s, want only orders of customer c1", order.GetOrderUid())
+	}
+}
+
+func TestHealthFollowsReadiness(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	server := NewServer(NewOrderServer(newFakeOrderService()))
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial bufconn: %v", err)
+	}
+	defer conn.Close()
+	health := healthpb.NewHealthClient(conn)
+
+	ready := make(chan error, 1)
+	ready <- errors.New("postgres: connection refused")
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go server.WatchHealth(watchCtx, func(context.Context) error {
+		select {
+		case err := <-ready:
+			return err
+		default:
+			return nil
+		}
+	}, 10*time.Millisecond)
+
+	waitFor := func(want healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			resp, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{})
+			if err == nil && resp.GetStatus() == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("health status = %v (err: %v), want %v", resp.GetStatus(), err, want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	waitFor(healthpb.HealthCheckResponse_NOT_SERVING)
+	waitFor(healthpb.HealthCheckResponse_SERVING)
+	stopWatch()
+	waitFor(healthpb.HealthCheckResponse_NOT_SERVING)
+}
@@ -1,10 +1,25 @@
-import React, { useState } from "react";
+import React, { useEffect, useState } from "react";
 
 const App = () => {
   const [orderUid, setOrderUid] = useState("");
   const [orderData, setOrderData] = useState(null);
   const [error, setError] = useState("");
 
+  const watchedUid = orderData?.order_uid;
+
+  useEffect(() => {
+    if (!watchedUid) {
+      return undefined;
+    }
+    const source = new EventSource(
+      `/orders/stream?order_uid=${encodeURIComponent(watchedUid)}`
+    );
+    source.addEventListener("order", (event) => {
+      setOrderData(JSON.parse(event.data));
+    });
+    return () => source.close();
+  }, [watchedUid]);
+
   const fetchOrder = async () => {
     setError("");
     setOrderData(null);
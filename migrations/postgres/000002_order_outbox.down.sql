@@ -0,0 +1 @@
+DROP TABLE IF EXISTS order_outbox;
\ No newline at end of file
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS order_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid VARCHAR(128) NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT NOW(),
+    published_at TIMESTAMP WITHOUT TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS order_outbox_unpublished_idx ON order_outbox (id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS order_outbox_published_at_idx ON order_outbox (published_at) WHERE published_at IS NOT NULL;
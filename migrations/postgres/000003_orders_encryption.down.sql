@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS orders_customer_id_idx;
+DROP INDEX IF EXISTS orders_customer_bidx_idx;
+DROP INDEX IF EXISTS orders_email_bidx_idx;
+DROP INDEX IF EXISTS orders_phone_bidx_idx;
+
+ALTER TABLE orders DROP COLUMN IF EXISTS customer_bidx;
+ALTER TABLE orders DROP COLUMN IF EXISTS email_bidx;
+ALTER TABLE orders DROP COLUMN IF EXISTS phone_bidx;
+
+-- customer_id stays TEXT: encrypted customer IDs are longer than the original VARCHAR(128)
+-- and cannot be decrypted in SQL. The rollback is lossy: the blind indexes are dropped and
+-- encrypted deliveries and customer IDs stay encrypted, so a release without encryption
+-- support cannot read them. cmd/rekey with ENCRYPT_CUSTOMER_ID=false decrypts the customer
+-- IDs beforehand.
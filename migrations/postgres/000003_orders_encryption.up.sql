@@ -0,0 +1,10 @@
+ALTER TABLE orders ALTER COLUMN customer_id TYPE TEXT;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS phone_bidx VARCHAR(64);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS email_bidx VARCHAR(64);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS customer_bidx VARCHAR(64);
+
+CREATE INDEX IF NOT EXISTS orders_phone_bidx_idx ON orders (phone_bidx);
+CREATE INDEX IF NOT EXISTS orders_email_bidx_idx ON orders (email_bidx);
+CREATE INDEX IF NOT EXISTS orders_customer_bidx_idx ON orders (customer_bidx);
+CREATE INDEX IF NOT EXISTS orders_customer_id_idx ON orders (customer_id);
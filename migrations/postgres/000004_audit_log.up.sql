@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT NOW(),
+    actor VARCHAR(256) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    subject VARCHAR(256) NOT NULL,
+    details JSONB NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_subject_idx ON audit_log (subject);
@@ -0,0 +1,3 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tenant VARCHAR(64) NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS orders_tenant_idx ON orders (tenant);
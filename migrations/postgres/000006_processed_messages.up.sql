@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS processed_messages (
+    message_key VARCHAR(512) PRIMARY KEY,
+    processed_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS processed_messages_processed_at_idx ON processed_messages (processed_at);
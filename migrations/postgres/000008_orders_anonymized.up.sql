@@ -0,0 +1,6 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS anonymized BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE orders SET anonymized = TRUE
+WHERE order_uid IN (
+    SELECT jsonb_array_elements_text(details->'orders') FROM audit_log WHERE action = 'customer.anonymize'
+);